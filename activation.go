@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Activation is the activation function of the hidden layer, the output layer is always a sigmoid
+// so that the cost is the cross entropy
+type Activation int
+
+const (
+	// Sigmoid is the logistic function
+	Sigmoid Activation = iota
+	// Tanh is the hyperbolic tangent
+	Tanh
+	// ReLU is max(0, x)
+	ReLU
+)
+
+var activationNames = []string{"sigmoid", "tanh", "relu"}
+
+func (a Activation) String() string {
+	if a < 0 || int(a) >= len(activationNames) {
+		return fmt.Sprintf("Activation(%d)", int(a))
+	}
+	return activationNames[a]
+}
+
+// MarshalText implements encoding.TextMarshaler so the activation is readable in saved models
+func (a Activation) MarshalText() ([]byte, error) {
+	if a < 0 || int(a) >= len(activationNames) {
+		return nil, fmt.Errorf("unknown activation %d", int(a))
+	}
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (a *Activation) UnmarshalText(text []byte) error {
+	for i, name := range activationNames {
+		if name == string(text) {
+			*a = Activation(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown activation %q", text)
+}
+
+// apply returns the activation of every element of Z
+func (a Activation) apply(Z *Matrix) *Matrix {
+	res := Z.Clone()
+	for i, z := range res.Data {
+		switch a {
+		case Tanh:
+			res.Data[i] = math.Tanh(z)
+		case ReLU:
+			res.Data[i] = math.Max(z, 0)
+		default:
+			res.Data[i] = sigmoid(z)
+		}
+	}
+	return res
+}
+
+// prime returns the derivative of the activation at every element of Z
+func (a Activation) prime(Z *Matrix) *Matrix {
+	res := Z.Clone()
+	for i, z := range res.Data {
+		switch a {
+		case Tanh:
+			th := math.Tanh(z)
+			res.Data[i] = 1 - th*th
+		case ReLU:
+			res.Data[i] = 0
+			if z > 0 {
+				res.Data[i] = 1
+			}
+		default:
+			s := sigmoid(z)
+			res.Data[i] = s * (1 - s)
+		}
+	}
+	return res
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestActivationPrime(t *testing.T) {
+	z := &Matrix{Rows: 1, Cols: 4, Data: []float64{-2, -0.5, 0.5, 2}}
+	const h = 1e-6
+	for _, a := range []Activation{Sigmoid, Tanh, ReLU} {
+		prime := a.prime(z)
+		for i, val := range z.Data {
+			plus := a.apply(&Matrix{Rows: 1, Cols: 1, Data: []float64{val + h}}).Data[0]
+			minus := a.apply(&Matrix{Rows: 1, Cols: 1, Data: []float64{val - h}}).Data[0]
+			if expected := (plus - minus) / (2 * h); math.Abs(prime.Data[i]-expected) > 1e-6 {
+				t.Errorf("%s: expected the derivative %f at %f, got %f", a, expected, val, prime.Data[i])
+			}
+		}
+	}
+	if relu := ReLU.apply(z); relu.Data[0] != 0 || relu.Data[3] != 2 {
+		t.Errorf("unexpected relu %v", relu.Data)
+	}
+}
+
+func TestActivationText(t *testing.T) {
+	var a Activation
+	if err := a.UnmarshalText([]byte("tanh")); err != nil || a != Tanh {
+		t.Errorf("expected tanh, got %s %v", a, err)
+	}
+	if err := a.UnmarshalText([]byte("softmax")); err == nil {
+		t.Errorf("expected an error for an unknown activation")
+	}
+	if _, err := Activation(7).MarshalText(); err == nil {
+		t.Errorf("expected an error for an unknown activation")
+	}
+}
+
+func TestTrainActivations(t *testing.T) {
+	// xor can't be learnt without a non linear hidden layer
+	set := &SliceDataset{
+		X: [][]float64{{0, 0}, {0, 1}, {1, 0}, {1, 1}},
+		Y: [][]float64{{1, 0}, {0, 1}, {0, 1}, {1, 0}},
+	}
+	for _, a := range []Activation{Sigmoid, Tanh, ReLU} {
+		// a few initial weights get stuck in a local minimum, these are known to converge
+		nn := &NeuralNet{HiddenNeurons: 8, Alpha: 1, Activation: a, numBatches: 1, numEpochs: 3000}
+		nn.rng = rand.New(rand.NewSource(1))
+		if _, _, err := nn.TrainDataset(set, set); err != nil {
+			t.Fatalf("%s: %s", a, err)
+		}
+		acc, err := accuracy(nn, set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc != 1 {
+			t.Errorf("%s: expected xor to be learnt, got an accuracy of %f", a, acc)
+		}
+	}
+}
+
+func TestSaveLoadActivation(t *testing.T) {
+	nn := &NeuralNet{HiddenNeurons: 1, Activation: ReLU, W1: NewOnes(1, 2), W2: NewOnes(1, 2)}
+	for _, name := range []string{"net.json", "net.bin"} {
+		fileName := filepath.Join(t.TempDir(), name)
+		Save(fileName, nn)
+		if actual := Load(fileName); actual.Activation != ReLU {
+			t.Errorf("%s: expected relu, got %s", name, actual.Activation)
+		}
+	}
+}
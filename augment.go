@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Augmenter makes random changes to the training images of every mini-batch, so that the net
+// sees a slightly different version of each image every epoch. It works on the raw pixels in the
+// CHW layout of CIFAR10Image, a Preprocessed training set is augmented before it's preprocessed, and
+// only runs during training. All changes are off until their field is set.
+type Augmenter struct {
+	Channels, Height, Width int
+
+	// FlipProb is the probability of flipping an image horizontally
+	FlipProb float64
+	// Pad is the padding of a random crop, the image is shifted by up to Pad pixels in both
+	// directions and the uncovered pixels are zero
+	Pad int
+	// MaxRotation is the largest rotation in degrees either way
+	MaxRotation float64
+	// MaxTranslate is the largest translation in pixels either way, it's combined with the
+	// rotation and the pixels outside the image repeat the edge
+	MaxTranslate float64
+	// Brightness multiplies the image by a random factor in [1-Brightness, 1+Brightness]
+	Brightness float64
+	// Contrast scales the distance to the mean of the image by a random factor in
+	// [1-Contrast, 1+Contrast]
+	Contrast float64
+	// CutoutSize is the side of a square at a random position that is set to zero
+	CutoutSize int
+
+	rng *rand.Rand
+}
+
+// NewAugmenter returns an Augmenter for channels x height x width images, the seed makes the
+// changes reproducible
+func NewAugmenter(channels, height, width int, seed int64) *Augmenter {
+	return &Augmenter{Channels: channels, Height: height, Width: width, rng: rand.New(rand.NewSource(seed))}
+}
+
+// AugmentBatch changes every row of the batch in place
+func (a *Augmenter) AugmentBatch(x *Matrix) error {
+	if x.Cols != a.Channels*a.Height*a.Width {
+		return fmt.Errorf("augment: %d features can't be a %dx%dx%d image", x.Cols, a.Channels, a.Height, a.Width)
+	}
+	for r := 0; r < x.Rows; r++ {
+		a.Augment(x.Data[r*x.Cols : (r+1)*x.Cols])
+	}
+	return nil
+}
+
+// Augment changes a single image in place
+func (a *Augmenter) Augment(x []float64) {
+	t := &ImageTensor{Channels: a.Channels, Height: a.Height, Width: a.Width, Data: x}
+	if a.FlipProb > 0 && a.rng.Float64() < a.FlipProb {
+		a.flip(t)
+	}
+	if a.Pad > 0 {
+		a.shift(t, a.rng.Intn(2*a.Pad+1)-a.Pad, a.rng.Intn(2*a.Pad+1)-a.Pad)
+	}
+	if a.MaxRotation > 0 || a.MaxTranslate > 0 {
+		angle := (2*a.rng.Float64() - 1) * a.MaxRotation * math.Pi / 180
+		dx := (2*a.rng.Float64() - 1) * a.MaxTranslate
+		dy := (2*a.rng.Float64() - 1) * a.MaxTranslate
+		a.affine(t, angle, dx, dy)
+	}
+	if a.Brightness > 0 {
+		factor := 1 + (2*a.rng.Float64()-1)*a.Brightness
+		for i := range x {
+			x[i] *= factor
+		}
+	}
+	if a.Contrast > 0 {
+		factor := 1 + (2*a.rng.Float64()-1)*a.Contrast
+		var mean float64
+		for _, val := range x {
+			mean += val
+		}
+		mean /= float64(len(x))
+		for i := range x {
+			x[i] = (x[i]-mean)*factor + mean
+		}
+	}
+	if a.CutoutSize > 0 {
+		a.cutout(t, a.rng.Intn(a.Width), a.rng.Intn(a.Height))
+	}
+}
+
+func (a *Augmenter) flip(t *ImageTensor) {
+	for row := 0; row < t.Channels*t.Height; row++ {
+		pixels := t.Data[row*t.Width : (row+1)*t.Width]
+		for i, j := 0, len(pixels)-1; i < j; i, j = i+1, j-1 {
+			pixels[i], pixels[j] = pixels[j], pixels[i]
+		}
+	}
+}
+
+// shift moves the image dx pixels right and dy pixels down, the uncovered pixels are zero
+func (a *Augmenter) shift(t *ImageTensor, dx, dy int) {
+	src := append([]float64(nil), t.Data...)
+	for c := 0; c < t.Channels; c++ {
+		for y := 0; y < t.Height; y++ {
+			for x := 0; x < t.Width; x++ {
+				sx, sy := x-dx, y-dy
+				val := 0.0
+				if sx >= 0 && sx < t.Width && sy >= 0 && sy < t.Height {
+					val = src[(c*t.Height+sy)*t.Width+sx]
+				}
+				t.Data[(c*t.Height+y)*t.Width+x] = val
+			}
+		}
+	}
+}
+
+// affine rotates the image by angle radians around its center and translates it by dx, dy
+func (a *Augmenter) affine(t *ImageTensor, angle, dx, dy float64) {
+	src := &ImageTensor{Channels: t.Channels, Height: t.Height, Width: t.Width, Data: append([]float64(nil), t.Data...)}
+	cx, cy := float64(t.Width-1)/2, float64(t.Height-1)/2
+	sin, cos := math.Sincos(angle)
+	for y := 0; y < t.Height; y++ {
+		for x := 0; x < t.Width; x++ {
+			// the inverse transform finds where each output pixel comes from
+			px, py := float64(x)-cx-dx, float64(y)-cy-dy
+			sx := cos*px + sin*py + cx
+			sy := -sin*px + cos*py + cy
+			for c := 0; c < t.Channels; c++ {
+				t.Data[(c*t.Height+y)*t.Width+x] = Bilinear.sample(src, c, sx, sy)
+			}
+		}
+	}
+}
+
+// cutout zeroes a CutoutSize square centered at x, y, it's clipped by the edges of the image
+func (a *Augmenter) cutout(t *ImageTensor, x, y int) {
+	x0, y0 := x-a.CutoutSize/2, y-a.CutoutSize/2
+	for c := 0; c < t.Channels; c++ {
+		for py := y0; py < y0+a.CutoutSize; py++ {
+			if py < 0 || py >= t.Height {
+				continue
+			}
+			for px := x0; px < x0+a.CutoutSize; px++ {
+				if px >= 0 && px < t.Width {
+					t.Data[(c*t.Height+py)*t.Width+px] = 0
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// testImage returns a 1x2x3 image where each value is its index plus one
+func testImage() []float64 {
+	return []float64{1, 2, 3, 4, 5, 6}
+}
+
+func equalSlices(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAugmenterNoChanges(t *testing.T) {
+	x := testImage()
+	NewAugmenter(1, 2, 3, 1).Augment(x)
+	if !equalSlices(x, testImage()) {
+		t.Errorf("expected no changes without any augmentation set, got %v", x)
+	}
+}
+
+func TestAugmenterFlip(t *testing.T) {
+	a := NewAugmenter(1, 2, 3, 1)
+	a.FlipProb = 1
+	x := testImage()
+	a.Augment(x)
+	if expected := []float64{3, 2, 1, 6, 5, 4}; !equalSlices(x, expected) {
+		t.Errorf("expected %v, got %v", expected, x)
+	}
+}
+
+func TestAugmenterShiftAndCutout(t *testing.T) {
+	a := NewAugmenter(1, 2, 3, 1)
+	x := testImage()
+	a.shift(&ImageTensor{Channels: 1, Height: 2, Width: 3, Data: x}, 1, -1)
+	if expected := []float64{0, 4, 5, 0, 0, 0}; !equalSlices(x, expected) {
+		t.Errorf("expected %v, got %v", expected, x)
+	}
+
+	a.CutoutSize = 2
+	x = testImage()
+	a.cutout(&ImageTensor{Channels: 1, Height: 2, Width: 3, Data: x}, 2, 1)
+	if expected := []float64{1, 0, 0, 4, 0, 0}; !equalSlices(x, expected) {
+		t.Errorf("expected %v, got %v", expected, x)
+	}
+}
+
+func TestAugmenterAffine(t *testing.T) {
+	a := NewAugmenter(1, 3, 3, 1)
+	x := []float64{0, 0, 0, 0, 0, 1, 0, 0, 0}
+	// a quarter turn moves the pixel right of the center to below or above it
+	a.affine(&ImageTensor{Channels: 1, Height: 3, Width: 3, Data: x}, math.Pi/2, 0, 0)
+	if math.Abs(x[7]+x[1]-1) > 1e-9 || x[5] > 1e-9 {
+		t.Errorf("expected the pixel to be rotated, got %v", x)
+	}
+
+	x = []float64{0, 0, 0, 0, 1, 0, 0, 0, 0}
+	a.affine(&ImageTensor{Channels: 1, Height: 3, Width: 3, Data: x}, 0, 1, 0)
+	if math.Abs(x[5]-1) > 1e-9 || x[4] > 1e-9 {
+		t.Errorf("expected the pixel to be moved right, got %v", x)
+	}
+}
+
+func TestAugmenterSeed(t *testing.T) {
+	augmented := func(seed int64) []float64 {
+		a := NewAugmenter(1, 2, 3, seed)
+		a.FlipProb = 0.5
+		a.Pad = 1
+		a.MaxRotation = 15
+		a.MaxTranslate = 1
+		a.Brightness = 0.3
+		a.Contrast = 0.3
+		a.CutoutSize = 1
+		var res []float64
+		for i := 0; i < 5; i++ {
+			x := testImage()
+			a.Augment(x)
+			res = append(res, x...)
+		}
+		return res
+	}
+	if !equalSlices(augmented(7), augmented(7)) {
+		t.Errorf("expected the same seed to give the same changes")
+	}
+	if equalSlices(augmented(7), augmented(8)) {
+		t.Errorf("expected different seeds to give different changes")
+	}
+}
+
+func TestTrainAugmentation(t *testing.T) {
+	set := &SliceDataset{
+		X: [][]float64{testImage(), {6, 5, 4, 3, 2, 1}},
+		Y: [][]float64{{1, 0}, {0, 1}},
+	}
+	neuro := &NeuralNet{
+		HiddenNeurons: 3,
+		Alpha:         1e-2,
+		numBatches:    1,
+		numEpochs:     3,
+		augment:       NewAugmenter(1, 2, 3, 1),
+	}
+	neuro.augment.FlipProb = 1
+	neuro.augment.Brightness = 0.5
+	if _, _, err := neuro.TrainDataset(set, set); err != nil {
+		t.Fatal(err)
+	}
+	if !equalSlices(set.X[0], testImage()) {
+		t.Errorf("expected the training data to be left alone, got %v", set.X[0])
+	}
+
+	if err := NewAugmenter(1, 2, 2, 1).AugmentBatch(MustNewMatrix(set.X)); err == nil {
+		t.Errorf("expected an error when the features aren't the image size")
+	}
+}
+
+func TestAugmentBeforePreprocessing(t *testing.T) {
+	raw := &SliceDataset{X: [][]float64{{1, 2}}, Y: [][]float64{{1}}}
+	// subtracting a different mean per pixel tells the order of flipping and preprocessing apart
+	set, err := Preprocessed(raw, func(x []float64) ([]float64, error) {
+		return []float64{x[0], x[1] - 100}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	neuro := &NeuralNet{numBatches: 1, augment: NewAugmenter(1, 1, 2, 1)}
+	neuro.augment.FlipProb = 1
+	X, _, err := neuro.trainingBatches(set, []int{0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x := X[0].(*Matrix).Data; !equalSlices(x, []float64{2, -99}) {
+		t.Errorf("expected the raw image to be flipped and then preprocessed, got %v", x)
+	}
+	if !equalSlices(raw.X[0], []float64{1, 2}) {
+		t.Errorf("expected the raw data to be left alone, got %v", raw.X[0])
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"text/tabwriter"
+)
+
+// CVOptions configures CrossValidate
+type CVOptions struct {
+	// Folds is the number of folds, each example is used for validation in exactly one of them
+	Folds int
+	// Stratify gives each fold the same class balance as the whole set
+	Stratify bool
+	// LeaveOneOut has a fold per example, Folds and Stratify are ignored
+	LeaveOneOut bool
+	// Seed makes the folds reproducible
+	Seed int64
+	// Parallel is the number of folds that are trained at the same time, defaults to 1
+	Parallel int
+	// Prepare fits the preprocessing on the training examples of a fold and returns the set
+	// transformed with it, so nothing leaks from the examples left out. Without it the set is used
+	// as it is and should not be preprocessed with statistics from every fold, as that makes the
+	// estimate optimistic.
+	Prepare func(set Dataset, train []int) (Dataset, error)
+}
+
+// KFold returns the validation examples of each fold, the training examples of a fold are all the
+// others
+func KFold(labels []int, opts CVOptions) ([][]int, error) {
+	n := len(labels)
+	if opts.LeaveOneOut {
+		folds := make([][]int, n)
+		for i := range folds {
+			folds[i] = []int{i}
+		}
+		return folds, nil
+	}
+	if opts.Folds < 2 || opts.Folds > n {
+		return nil, fmt.Errorf("crossval: %d folds doesn't work for %d examples", opts.Folds, n)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	groups := [][]int{rng.Perm(n)}
+	if opts.Stratify {
+		groups = groupByClass(labels)
+		for _, group := range groups {
+			rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		}
+	}
+
+	// deal the examples round robin, continuing with the next fold for the next class so the
+	// folds end up the same size
+	folds := make([][]int, opts.Folds)
+	f := 0
+	for _, group := range groups {
+		for _, i := range group {
+			folds[f] = append(folds[f], i)
+			f = (f + 1) % opts.Folds
+		}
+	}
+	return folds, nil
+}
+
+// FoldResult is the outcome of training on a single fold
+type FoldResult struct {
+	TrainCost, ValidationCost         float64
+	TrainAccuracy, ValidationAccuracy float64
+}
+
+// CVResult holds the result of every fold and the mean and standard deviation over them
+type CVResult struct {
+	Folds                     []FoldResult
+	MeanCost, StdCost         float64
+	MeanAccuracy, StdAccuracy float64
+}
+
+// CrossValidate trains a net from newNet on every fold of set and validates it on the examples left
+// out. newNet must return a new net for each call since folds may be trained at the same time.
+func CrossValidate(set Dataset, newNet func() *NeuralNet, opts CVOptions) (*CVResult, error) {
+	labels, err := datasetLabels(set)
+	if err != nil {
+		return nil, err
+	}
+	folds, err := KFold(labels, opts)
+	if err != nil {
+		return nil, err
+	}
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	res := &CVResult{Folds: make([]FoldResult, len(folds))}
+	errs := make([]error, len(folds))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for f := range folds {
+		wg.Add(1)
+		go func(f int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res.Folds[f], errs[f] = trainFold(set, newNet(), folds, f, opts.Prepare)
+		}(f)
+	}
+	wg.Wait()
+	for f, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("crossval: fold %d: %s", f+1, err)
+		}
+	}
+
+	costs := make([]float64, len(folds))
+	accuracies := make([]float64, len(folds))
+	for f, fold := range res.Folds {
+		costs[f] = fold.ValidationCost
+		accuracies[f] = fold.ValidationAccuracy
+	}
+	res.MeanCost, res.StdCost = meanStd(costs)
+	res.MeanAccuracy, res.StdAccuracy = meanStd(accuracies)
+	return res, nil
+}
+
+// trainFold trains nn on every fold but f and validates it on f, the set is first prepared on the
+// training examples if prepare isn't nil
+func trainFold(set Dataset, nn *NeuralNet, folds [][]int, f int, prepare func(Dataset, []int) (Dataset, error)) (FoldResult, error) {
+	var train []int
+	for i, fold := range folds {
+		if i != f {
+			train = append(train, fold...)
+		}
+	}
+	var res FoldResult
+	var err error
+	if prepare != nil {
+		if set, err = prepare(set, train); err != nil {
+			return res, err
+		}
+	}
+	trSet, cvSet := Subset(set, train), Subset(set, folds[f])
+
+	if res.TrainCost, res.ValidationCost, err = nn.TrainDataset(trSet, cvSet); err != nil {
+		return res, err
+	}
+	if res.TrainAccuracy, err = accuracy(nn, trSet); err != nil {
+		return res, err
+	}
+	res.ValidationAccuracy, err = accuracy(nn, cvSet)
+	return res, err
+}
+
+// accuracy returns the fraction of correctly predicted examples
+func accuracy(nn *NeuralNet, set Dataset) (float64, error) {
+	predicted, actual, err := nn.PredictDataset(set)
+	if err != nil || len(actual) == 0 {
+		return 0, err
+	}
+	correct := 0
+	for i := range predicted {
+		if predicted[i] == actual[i] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(actual)), nil
+}
+
+// meanStd returns the mean and the sample standard deviation
+func meanStd(x []float64) (mean, std float64) {
+	if len(x) == 0 {
+		return 0, 0
+	}
+	for _, val := range x {
+		mean += val
+	}
+	mean /= float64(len(x))
+	if len(x) < 2 {
+		return mean, 0
+	}
+	for _, val := range x {
+		std += (val - mean) * (val - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(x)-1))
+}
+
+// String returns a table with the result of every fold followed by the mean and std
+func (r *CVResult) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "fold\ttrain cost\tvalidation cost\ttrain accuracy\tvalidation accuracy\t")
+	for f, fold := range r.Folds {
+		fmt.Fprintf(w, "%d\t%.4f\t%.4f\t%.1f%%\t%.1f%%\t\n", f+1, fold.TrainCost, fold.ValidationCost, fold.TrainAccuracy*100, fold.ValidationAccuracy*100)
+	}
+	fmt.Fprintf(w, "mean\t\t%.4f ± %.4f\t\t%.1f%% ± %.1f%%\t\n", r.MeanCost, r.StdCost, r.MeanAccuracy*100, r.StdAccuracy*100)
+	w.Flush()
+	return buf.String()
+}
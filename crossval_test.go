@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestKFold(t *testing.T) {
+	// 90 examples of class 0, 9 of class 1 and 1 of class 2
+	labels := make([]int, 100)
+	for i := 90; i < 99; i++ {
+		labels[i] = 1
+	}
+	labels[99] = 2
+	folds, err := KFold(labels, CVOptions{Folds: 3, Stratify: true, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folds) != 3 {
+		t.Fatalf("expected 3 folds, got %d", len(folds))
+	}
+	var all []int
+	for f, fold := range folds {
+		if len(fold) < 33 || len(fold) > 34 {
+			t.Errorf("fold %d: expected 33 or 34 examples, got %d", f, len(fold))
+		}
+		if counts := ClassCounts(labels, fold, 3); counts[0] != 30 || counts[1] != 3 {
+			t.Errorf("fold %d: expected 30 and 3 examples of the first classes, got %v", f, counts)
+		}
+		all = append(all, fold...)
+	}
+	sort.Ints(all)
+	for i := range all {
+		if all[i] != i {
+			t.Fatalf("expected every example in exactly one fold")
+		}
+	}
+
+	folds, err = KFold(labels, CVOptions{LeaveOneOut: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folds) != 100 || len(folds[42]) != 1 || folds[42][0] != 42 {
+		t.Errorf("expected a fold per example")
+	}
+
+	for _, k := range []int{1, 101} {
+		if _, err := KFold(labels, CVOptions{Folds: k}); err == nil {
+			t.Errorf("expected an error for %d folds", k)
+		}
+	}
+}
+
+func TestMeanStd(t *testing.T) {
+	mean, std := meanStd([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if mean != 5 || math.Abs(std-2.138089935) > 1e-9 {
+		t.Errorf("expected 5 and 2.138, got %f and %f", mean, std)
+	}
+	if mean, std = meanStd([]float64{3}); mean != 3 || std != 0 {
+		t.Errorf("expected 3 and 0, got %f and %f", mean, std)
+	}
+}
+
+func TestCrossValidate(t *testing.T) {
+	// two well separated classes
+	set := &SliceDataset{Classes: []string{"low", "high"}}
+	for i := 0; i < 20; i++ {
+		val := float64(i%10) / 10
+		if i%2 == 0 {
+			set.X = append(set.X, []float64{-1 - val, -1})
+			set.Y = append(set.Y, []float64{1, 0})
+		} else {
+			set.X = append(set.X, []float64{1 + val, 1})
+			set.Y = append(set.Y, []float64{0, 1})
+		}
+	}
+	newNet := func() *NeuralNet {
+		return &NeuralNet{HiddenNeurons: 4, Alpha: 0.5, numBatches: 1, numEpochs: 200}
+	}
+	res, err := CrossValidate(set, newNet, CVOptions{Folds: 4, Stratify: true, Seed: 1, Parallel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Folds) != 4 {
+		t.Fatalf("expected 4 fold results, got %d", len(res.Folds))
+	}
+	if res.MeanAccuracy < 0.9 {
+		t.Errorf("expected a high accuracy on separable data, got %f", res.MeanAccuracy)
+	}
+	costs := make([]float64, len(res.Folds))
+	for f, fold := range res.Folds {
+		costs[f] = fold.ValidationCost
+	}
+	if mean, std := meanStd(costs); mean != res.MeanCost || std != res.StdCost {
+		t.Errorf("expected the cost %f ± %f, got %f ± %f", mean, std, res.MeanCost, res.StdCost)
+	}
+	if report := res.String(); !strings.Contains(report, "mean") || strings.Count(report, "\n") != 6 {
+		t.Errorf("unexpected report\n%s", report)
+	}
+}
+
+func TestCrossValidatePrepare(t *testing.T) {
+	set := &SliceDataset{}
+	for i := 0; i < 8; i++ {
+		set.X = append(set.X, []float64{float64(i % 2)})
+		set.Y = append(set.Y, []float64{float64(i % 2), float64(1 - i%2)})
+	}
+	var mu sync.Mutex
+	left := make([]int, set.Len())
+	prepare := func(s Dataset, train []int) (Dataset, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen := make(map[int]bool)
+		for _, i := range train {
+			seen[i] = true
+		}
+		for i := range left {
+			if !seen[i] {
+				left[i]++
+			}
+		}
+		return s, nil
+	}
+	newNet := func() *NeuralNet {
+		return &NeuralNet{HiddenNeurons: 2, Alpha: 0.5, numBatches: 1, numEpochs: 5}
+	}
+	if _, err := CrossValidate(set, newNet, CVOptions{Folds: 4, Seed: 1, Parallel: 2, Prepare: prepare}); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range left {
+		if n != 1 {
+			t.Errorf("expected example %d to be left out of the preparation of exactly one fold, got %d", i, n)
+		}
+	}
+}
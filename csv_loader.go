@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MissingPolicy decides what LoadCSV does with a missing feature value
+type MissingPolicy int
+
+const (
+	// MissingError stops loading with an error
+	MissingError MissingPolicy = iota
+	// MissingDropRow skips the rows that have missing values
+	MissingDropRow
+	// MissingNaN keeps the row, missing numeric values become NaN and missing categorical values
+	// are encoded like an unseen category
+	MissingNaN
+)
+
+// CSVSchema describes the layout of a delimited text file. Columns are referred to by their name
+// in the header or Names, or by their zero based index where negative indices count from the end.
+type CSVSchema struct {
+	// Delimiter between the fields, defaults to ','
+	Delimiter rune
+	// Comment lines starts with this character, not used if 0
+	Comment rune
+	// Header is true if the first row contains the column names
+	Header bool
+	// Names are the column names for files without a header
+	Names []string
+	// LabelColumn holds the class of each row, defaults to the last column
+	LabelColumn string
+	// Ignore are columns that are neither features or labels
+	Ignore []string
+	// Classes fixes the order of the one-hot labels, if empty the classes are discovered from the
+	// file and sorted, numerically if they are all numbers
+	Classes []string
+	// Missing decides what to do with rows that have missing values
+	Missing MissingPolicy
+	// MissingValues are the strings that count as missing, defaults to "", "?" and "NA"
+	MissingValues []string
+	// Categorical sets the encoding of columns, columns that aren't listed are numeric unless
+	// they contain values that aren't numbers, in which case they are one-hot encoded
+	Categorical map[string]ColumnEncoding
+	// HashBuckets is the number of features of Hashing encoded columns, defaults to 16
+	HashBuckets int
+}
+
+func init() {
+	RegisterLoader("csv", csvLoader{CSVSchema{Header: true}}, ".csv")
+	RegisterLoader("tsv", csvLoader{CSVSchema{Delimiter: '\t', Header: true}}, ".tsv")
+}
+
+// TableLoader is a Loader of tabular files that can also return the rows before they are encoded,
+// with an unfitted encoder for them, so that the encoder can be fitted on the training rows only
+type TableLoader interface {
+	Loader
+	LoadTable(file string) (*Table, *TabularEncoder, error)
+}
+
+// csvLoader loads delimited files with a fixed schema
+type csvLoader struct {
+	schema CSVSchema
+}
+
+func (l csvLoader) Load(file string) (Dataset, error) {
+	return LoadCSV(file, l.schema)
+}
+
+func (l csvLoader) LoadTable(file string) (*Table, *TabularEncoder, error) {
+	table, err := ReadCSV(file, l.schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	return table, NewTabularEncoder(table, l.schema.HashBuckets), nil
+}
+
+// Table is the raw content of a delimited file. The feature columns are kept as strings until
+// they are encoded by a TabularEncoder, so that the encoder can be fitted on the training rows only.
+type Table struct {
+	// Names are the names of the feature columns, nil if the file has no header or Names
+	Names []string
+	// Rows holds the feature fields of each row, missing values are ""
+	Rows [][]string
+	// Labels holds the class of each row
+	Labels []string
+	// Classes are the distinct labels in the order of the one-hot encoding
+	Classes []string
+	// Encodings holds the encoding of each feature column from the schema
+	Encodings []ColumnEncoding
+}
+
+// LoadCSV reads a delimited text file with one example per row according to the schema, the
+// categorical columns are encoded with an encoder fitted on every row. It's for callers that don't
+// split the rows, otherwise use ReadCSV and fit the encoder on the training rows only.
+func LoadCSV(file string, schema CSVSchema) (*SliceDataset, error) {
+	table, err := ReadCSV(file, schema)
+	if err != nil {
+		return nil, err
+	}
+	enc := NewTabularEncoder(table, schema.HashBuckets)
+	if err := enc.Fit(table, allTableRows(table)); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	set, err := EncodeTable(table, enc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return set, nil
+}
+
+// EncodeTable encodes every row of the table with a fitted encoder
+func EncodeTable(table *Table, enc *TabularEncoder) (*SliceDataset, error) {
+	x, err := enc.Transform(table)
+	if err != nil {
+		return nil, err
+	}
+	y, err := oneHotStrings(table.Labels, table.Classes)
+	if err != nil {
+		return nil, err
+	}
+	return &SliceDataset{X: x, Y: y, Classes: table.Classes, Features: enc.FeatureNames(), Encoder: enc}, nil
+}
+
+// ReadCSV reads a delimited text file according to the schema without converting the features
+func ReadCSV(file string, schema CSVSchema) (*Table, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	csvr := csv.NewReader(f)
+	if schema.Delimiter != 0 {
+		csvr.Comma = schema.Delimiter
+	}
+	csvr.Comment = schema.Comment
+	csvr.TrimLeadingSpace = true
+
+	names := schema.Names
+	if schema.Header {
+		if names, err = csvr.Read(); err != nil {
+			return nil, fmt.Errorf("%s: reading header: %s", file, err)
+		}
+	}
+
+	missing := schema.MissingValues
+	if missing == nil {
+		missing = []string{"", "?", "NA"}
+	}
+
+	table := &Table{}
+	var layout *csvLayout
+	for {
+		row, err := csvr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		line, _ := csvr.FieldPos(0)
+
+		if layout == nil {
+			if layout, err = schema.layout(names, len(row)); err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err)
+			}
+		}
+
+		fields, err := layout.fields(row, missing, schema.Missing)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", file, line, err)
+		}
+		if fields == nil {
+			continue
+		}
+		label := strings.TrimSpace(row[layout.label])
+		if label == "" {
+			return nil, fmt.Errorf("%s:%d: missing label", file, line)
+		}
+		table.Rows = append(table.Rows, fields)
+		table.Labels = append(table.Labels, label)
+	}
+	if layout == nil {
+		return nil, fmt.Errorf("%s: no data", file)
+	}
+
+	table.Names = layout.featureNames(names)
+	table.Encodings = layout.encodings
+	table.Classes = schema.Classes
+	if len(table.Classes) == 0 {
+		table.Classes = discoverClasses(table.Labels)
+	}
+	return table, nil
+}
+
+// ClassIndices returns the class index of every row
+func (t *Table) ClassIndices() ([]int, error) {
+	y, err := oneHotStrings(t.Labels, t.Classes)
+	if err != nil {
+		return nil, err
+	}
+	return oneHotLabels(y), nil
+}
+
+// allTableRows returns the indices of every row in the table
+func allTableRows(table *Table) []int {
+	idx := make([]int, len(table.Rows))
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// csvLayout is the schema resolved against the actual columns of a file
+type csvLayout struct {
+	label     int
+	features  []int
+	encodings []ColumnEncoding
+}
+
+func (s CSVSchema) layout(names []string, numColumns int) (*csvLayout, error) {
+	labelSpec := s.LabelColumn
+	if labelSpec == "" {
+		labelSpec = "-1"
+	}
+	label, err := columnIndex(labelSpec, names, numColumns)
+	if err != nil {
+		return nil, fmt.Errorf("label column: %s", err)
+	}
+
+	skip := map[int]bool{label: true}
+	for _, spec := range s.Ignore {
+		idx, err := columnIndex(spec, names, numColumns)
+		if err != nil {
+			return nil, fmt.Errorf("ignored column: %s", err)
+		}
+		skip[idx] = true
+	}
+
+	l := &csvLayout{label: label}
+	for i := 0; i < numColumns; i++ {
+		if !skip[i] {
+			l.features = append(l.features, i)
+		}
+	}
+	if len(l.features) == 0 {
+		return nil, fmt.Errorf("there are no feature columns left")
+	}
+
+	encodings := make(map[int]ColumnEncoding)
+	for spec, encoding := range s.Categorical {
+		idx, err := columnIndex(spec, names, numColumns)
+		if err != nil {
+			return nil, fmt.Errorf("categorical column: %s", err)
+		}
+		encodings[idx] = encoding
+	}
+	l.encodings = make([]ColumnEncoding, len(l.features))
+	for i, col := range l.features {
+		l.encodings[i] = encodings[col]
+	}
+	return l, nil
+}
+
+// fields returns the feature fields of a row with missing values as "", or nil if the row should
+// be skipped
+func (l *csvLayout) fields(row []string, missing []string, policy MissingPolicy) ([]string, error) {
+	fields := make([]string, len(l.features))
+	for i, col := range l.features {
+		field := strings.TrimSpace(row[col])
+		if isMissing(field, missing) {
+			switch policy {
+			case MissingDropRow:
+				return nil, nil
+			case MissingNaN:
+				continue
+			default:
+				return nil, fmt.Errorf("missing value in column %d", col)
+			}
+		}
+		fields[i] = field
+	}
+	return fields, nil
+}
+
+func (l *csvLayout) featureNames(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	res := make([]string, len(l.features))
+	for i, col := range l.features {
+		if col < len(names) {
+			res[i] = names[col]
+		}
+	}
+	return res
+}
+
+// columnIndex resolves a column name or index
+func columnIndex(spec string, names []string, numColumns int) (int, error) {
+	for i, name := range names {
+		if name == spec {
+			return i, nil
+		}
+	}
+	idx, err := strconv.Atoi(spec)
+	if err != nil {
+		return 0, fmt.Errorf("no column named %q", spec)
+	}
+	if idx < 0 {
+		idx += numColumns
+	}
+	if idx < 0 || idx >= numColumns {
+		return 0, fmt.Errorf("column %s is out of range, there are %d columns", spec, numColumns)
+	}
+	return idx, nil
+}
+
+func isMissing(field string, missing []string) bool {
+	for _, m := range missing {
+		if field == m {
+			return true
+		}
+	}
+	return false
+}
+
+// discoverClasses returns the distinct labels sorted numerically if they are all numbers and
+// alphabetically otherwise
+func discoverClasses(labels []string) []string {
+	seen := make(map[string]bool)
+	var classes []string
+	numeric := true
+	for _, label := range labels {
+		if seen[label] {
+			continue
+		}
+		seen[label] = true
+		classes = append(classes, label)
+		if _, err := strconv.ParseFloat(label, 64); err != nil {
+			numeric = false
+		}
+	}
+	if numeric {
+		sort.Slice(classes, func(i, j int) bool {
+			a, _ := strconv.ParseFloat(classes[i], 64)
+			b, _ := strconv.ParseFloat(classes[j], 64)
+			return a < b
+		})
+	} else {
+		sort.Strings(classes)
+	}
+	return classes
+}
+
+// oneHotStrings encodes each label by its position in classes
+func oneHotStrings(labels, classes []string) ([][]float64, error) {
+	classIdx := make(map[string]int, len(classes))
+	for i, class := range classes {
+		classIdx[class] = i
+	}
+	res := make([][]float64, len(labels))
+	for i, label := range labels {
+		idx, ok := classIdx[label]
+		if !ok {
+			return nil, fmt.Errorf("unknown class %q", label)
+		}
+		res[i] = make([]float64, len(classes))
+		res[i][idx] = 1
+	}
+	return res, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadCSVHeader(t *testing.T) {
+	file := writeTestFile(t, "data.tsv", "id\tlength\tspecies\twidth\n1\t5.1\tsetosa\t3.5\n2\t7.0\tversicolor\t3.2\n3\t6.3\tsetosa\t3.3\n")
+
+	set, err := LoadCSV(file, CSVSchema{
+		Delimiter:   '\t',
+		Header:      true,
+		LabelColumn: "species",
+		Ignore:      []string{"id"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 3 {
+		t.Fatalf("expected 3 examples, got %d", set.Len())
+	}
+	if names := set.FeatureNames(); len(names) != 2 || names[0] != "length" || names[1] != "width" {
+		t.Errorf("expected the features length and width, got %v", names)
+	}
+	if classes := set.ClassNames(); len(classes) != 2 || classes[0] != "setosa" || classes[1] != "versicolor" {
+		t.Errorf("expected the classes to be discovered and sorted, got %v", classes)
+	}
+	if set.X[1][0] != 7.0 || set.X[1][1] != 3.2 || set.Y[1][1] != 1 {
+		t.Errorf("unexpected second row %v %v", set.X[1], set.Y[1])
+	}
+}
+
+func TestLoadCSVNumericClassOrder(t *testing.T) {
+	file := writeTestFile(t, "data.csv", "1,10\n2,9\n3,10\n4,-2\n")
+
+	set, err := LoadCSV(file, CSVSchema{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	classes := set.ClassNames()
+	if len(classes) != 3 || classes[0] != "-2" || classes[1] != "9" || classes[2] != "10" {
+		t.Errorf("expected numeric classes to be sorted numerically, got %v", classes)
+	}
+	if names := set.FeatureNames(); len(names) != 1 || names[0] != "column 0" {
+		t.Errorf("expected the features to be named by their position without a header, got %v", names)
+	}
+}
+
+func TestLoadCSVMissing(t *testing.T) {
+	file := writeTestFile(t, "data.csv", "a,b,class\n1,2,x\n?,4,y\n5,,x\n")
+
+	if _, err := LoadCSV(file, CSVSchema{Header: true}); err == nil {
+		t.Errorf("expected an error for missing values by default")
+	}
+
+	set, err := LoadCSV(file, CSVSchema{Header: true, Missing: MissingDropRow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 1 {
+		t.Errorf("expected the rows with missing values to be dropped, got %d rows", set.Len())
+	}
+
+	set, err = LoadCSV(file, CSVSchema{Header: true, Missing: MissingNaN})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 3 || !math.IsNaN(set.X[1][0]) || !math.IsNaN(set.X[2][1]) {
+		t.Errorf("expected missing values to be NaN, got %v", set.X)
+	}
+}
+
+func TestLoadCSVInvalidSchema(t *testing.T) {
+	file := writeTestFile(t, "data.csv", "a,b,class\n1,2,x\n")
+
+	if _, err := LoadCSV(file, CSVSchema{Header: true, LabelColumn: "nope"}); err == nil {
+		t.Errorf("expected an error for an unknown label column")
+	}
+	if _, err := LoadCSV(file, CSVSchema{Header: true, LabelColumn: "5"}); err == nil {
+		t.Errorf("expected an error for an out of range label column")
+	}
+	if _, err := LoadCSV(file, CSVSchema{Header: true, Ignore: []string{"a", "b"}}); err == nil {
+		t.Errorf("expected an error when there are no features")
+	}
+	if _, err := LoadCSV(file, CSVSchema{Header: true, Classes: []string{"y"}}); err == nil {
+		t.Errorf("expected an error for a class that isn't in Classes")
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// ROCPoint is the false and true positive rate when every example scoring at least Threshold is
+// predicted positive
+type ROCPoint struct {
+	Threshold, FPR, TPR float64
+}
+
+// PRPoint is the recall and precision when every example scoring at least Threshold is predicted
+// positive
+type PRPoint struct {
+	Threshold, Recall, Precision float64
+}
+
+// ClassCurves are the one-vs-rest ROC and precision-recall curves of a class. The curves are
+// empty and their areas NaN when the class has no examples, or every example is of the class.
+type ClassCurves struct {
+	Class int
+	Name  string
+	// Positives and Negatives are the number of examples that are and aren't of the class
+	Positives, Negatives int
+	ROC                  []ROCPoint
+	PR                   []PRPoint
+	// AUC is the area under the ROC curve, the chance that a random positive example scores higher
+	// than a random negative one
+	AUC float64
+	// AveragePrecision is the precision averaged over the recall, which unlike the AUC isn't
+	// flattered by a large number of easy negatives
+	AveragePrecision float64
+}
+
+// OneVsRest computes the ROC and precision-recall curves of every class against all the others
+// from the scores of every example, like the output of ScoreDataset. classNames can be nil.
+func OneVsRest(scores [][]float64, actual []int, classNames []string) ([]ClassCurves, error) {
+	if len(scores) == 0 || len(scores) != len(actual) {
+		return nil, fmt.Errorf("curves: expected a score for each of the %d examples, got %d", len(actual), len(scores))
+	}
+	numClasses := len(scores[0])
+	for i := range scores {
+		if len(scores[i]) != numClasses {
+			return nil, fmt.Errorf("curves: expected %d scores for example %d, got %d", numClasses, i, len(scores[i]))
+		}
+		if actual[i] < 0 || actual[i] >= numClasses {
+			return nil, fmt.Errorf("curves: class %d of example %d is out of range [0, %d)", actual[i], i, numClasses)
+		}
+	}
+
+	curves := make([]ClassCurves, numClasses)
+	classScores := make([]float64, len(scores))
+	positive := make([]bool, len(scores))
+	for c := range curves {
+		for i := range scores {
+			classScores[i] = scores[i][c]
+			positive[i] = actual[i] == c
+		}
+		curves[c].Class = c
+		curves[c].Name = fmt.Sprintf("%d", c)
+		if c < len(classNames) {
+			curves[c].Name = classNames[c]
+		}
+		for _, p := range positive {
+			if p {
+				curves[c].Positives++
+			} else {
+				curves[c].Negatives++
+			}
+		}
+		curves[c].ROC, curves[c].AUC = ROCCurve(classScores, positive)
+		curves[c].PR, curves[c].AveragePrecision = PRCurve(classScores, positive)
+	}
+	return curves, nil
+}
+
+// thresholdCounts returns every distinct score, highest first, with the number of positive and
+// negative examples scoring at least that much
+func thresholdCounts(scores []float64, positive []bool) (thresholds []float64, tps, fps []int) {
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+	var tp, fp int
+	for n, i := range idx {
+		if positive[i] {
+			tp++
+		} else {
+			fp++
+		}
+		// tied scores can't be separated by a threshold so they make a single point
+		if n+1 < len(idx) && scores[idx[n+1]] == scores[i] {
+			continue
+		}
+		thresholds = append(thresholds, scores[i])
+		tps = append(tps, tp)
+		fps = append(fps, fp)
+	}
+	return thresholds, tps, fps
+}
+
+// ROCCurve returns the ROC curve, from (0, 0) to (1, 1), and the area under it by the trapezoidal
+// rule. The curve is nil and the area NaN without both positive and negative examples.
+func ROCCurve(scores []float64, positive []bool) ([]ROCPoint, float64) {
+	thresholds, tps, fps := thresholdCounts(scores, positive)
+	if len(thresholds) == 0 || tps[len(tps)-1] == 0 || fps[len(fps)-1] == 0 {
+		return nil, math.NaN()
+	}
+	numPos, numNeg := float64(tps[len(tps)-1]), float64(fps[len(fps)-1])
+	points := []ROCPoint{{Threshold: math.Inf(1)}}
+	var auc float64
+	for i, threshold := range thresholds {
+		p := ROCPoint{Threshold: threshold, FPR: float64(fps[i]) / numNeg, TPR: float64(tps[i]) / numPos}
+		prev := points[len(points)-1]
+		auc += (p.FPR - prev.FPR) * (p.TPR + prev.TPR) / 2
+		points = append(points, p)
+	}
+	return points, auc
+}
+
+// PRCurve returns the precision-recall curve, starting at a recall of 0 and a precision of 1, and
+// the average precision, the sum of the precision at every threshold weighted by the recall it
+// adds. The curve is nil and the average NaN without any positive examples.
+func PRCurve(scores []float64, positive []bool) ([]PRPoint, float64) {
+	thresholds, tps, fps := thresholdCounts(scores, positive)
+	if len(thresholds) == 0 || tps[len(tps)-1] == 0 {
+		return nil, math.NaN()
+	}
+	numPos := float64(tps[len(tps)-1])
+	points := []PRPoint{{Threshold: math.Inf(1), Precision: 1}}
+	var ap float64
+	for i, threshold := range thresholds {
+		p := PRPoint{
+			Threshold: threshold,
+			Recall:    float64(tps[i]) / numPos,
+			Precision: float64(tps[i]) / float64(tps[i]+fps[i]),
+		}
+		ap += (p.Recall - points[len(points)-1].Recall) * p.Precision
+		points = append(points, p)
+	}
+	return points, ap
+}
+
+// MacroAUC returns the mean AUC and average precision of the classes that have them
+func MacroAUC(curves []ClassCurves) (auc, ap float64) {
+	var numAUC, numAP int
+	for _, c := range curves {
+		if !math.IsNaN(c.AUC) {
+			auc += c.AUC
+			numAUC++
+		}
+		if !math.IsNaN(c.AveragePrecision) {
+			ap += c.AveragePrecision
+			numAP++
+		}
+	}
+	if numAUC == 0 {
+		auc = math.NaN()
+	} else {
+		auc /= float64(numAUC)
+	}
+	if numAP == 0 {
+		ap = math.NaN()
+	} else {
+		ap /= float64(numAP)
+	}
+	return auc, ap
+}
+
+// CurvesTable returns the AUC and average precision of every class and their macro average
+func CurvesTable(curves []ClassCurves) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "class\tauc\tavg precision\tpositives\tnegatives\t")
+	for _, c := range curves {
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%d\t%d\t\n", c.Name, c.AUC, c.AveragePrecision, c.Positives, c.Negatives)
+	}
+	auc, ap := MacroAUC(curves)
+	fmt.Fprintf(w, "macro avg\t%.3f\t%.3f\t\t\t\n", auc, ap)
+	w.Flush()
+	return buf.String()
+}
+
+// curveColumns are the columns of the CSV written by WriteCurvesCSV, x and y are the FPR and TPR
+// of the ROC curve and the recall and precision of the precision-recall curve
+var curveColumns = []string{"class", "name", "curve", "threshold", "x", "y"}
+
+// WriteCurvesCSV writes a row for every point of every curve
+func WriteCurvesCSV(out io.Writer, curves []ClassCurves) error {
+	w := csv.NewWriter(out)
+	w.Write(curveColumns)
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	for _, c := range curves {
+		class := strconv.Itoa(c.Class)
+		for _, p := range c.ROC {
+			w.Write([]string{class, c.Name, "roc", format(p.Threshold), format(p.FPR), format(p.TPR)})
+		}
+		for _, p := range c.PR {
+			w.Write([]string{class, c.Name, "pr", format(p.Threshold), format(p.Recall), format(p.Precision)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("curves: %s", err)
+	}
+	return nil
+}
+
+// SaveCurves writes the points of the curves to curves.csv and draws them into roc.png and pr.png
+// in dir
+func SaveCurves(dir string, curves []ClassCurves) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("curves: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCurvesCSV(&buf, curves); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "curves.csv"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("curves: %s", err)
+	}
+	if err := savePNG(filepath.Join(dir, "roc.png"), RenderROC(curves, curveImageSize, curveImageSize)); err != nil {
+		return err
+	}
+	return savePNG(filepath.Join(dir, "pr.png"), RenderPR(curves, curveImageSize, curveImageSize))
+}
+
+func savePNG(location string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("curves: %s", err)
+	}
+	if err := os.WriteFile(location, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("curves: %s", err)
+	}
+	return nil
+}
+
+// curveImageSize is the width and height of the images written by SaveCurves
+const curveImageSize = 512
+
+// curvePalette colours the classes in order, it wraps around when there are more classes. The
+// standard library can't draw text so there's no legend, the order is the same as in CurvesTable
+// and the CSV.
+var curvePalette = []color.RGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+	{227, 119, 194, 255},
+	{127, 127, 127, 255},
+	{188, 189, 34, 255},
+	{23, 190, 207, 255},
+}
+
+var (
+	curveGrid  = color.RGBA{230, 230, 230, 255}
+	curveGuide = color.RGBA{170, 170, 170, 255}
+	curveAxes  = color.RGBA{0, 0, 0, 255}
+)
+
+// RenderROC draws the ROC curve of every class with the diagonal of a random classifier
+func RenderROC(curves []ClassCurves, width, height int) *image.RGBA {
+	lines := make([][][2]float64, len(curves))
+	for c := range curves {
+		for _, p := range curves[c].ROC {
+			lines[c] = append(lines[c], [2]float64{p.FPR, p.TPR})
+		}
+	}
+	return renderCurves(lines, [][2]float64{{0, 0}, {1, 1}}, width, height)
+}
+
+// RenderPR draws the precision-recall curve of every class
+func RenderPR(curves []ClassCurves, width, height int) *image.RGBA {
+	lines := make([][][2]float64, len(curves))
+	for c := range curves {
+		for _, p := range curves[c].PR {
+			lines[c] = append(lines[c], [2]float64{p.Recall, p.Precision})
+		}
+	}
+	return renderCurves(lines, nil, width, height)
+}
+
+// renderCurves draws lines of points in the unit square on a white background with a grid, and a
+// guide line when there is one. The curves go on top so the perfect ones along the frame show.
+func renderCurves(lines [][][2]float64, guide [][2]float64, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	margin := width / 16
+	if m := height / 16; m < margin {
+		margin = m
+	}
+	plot := image.Rect(margin, margin, width-margin, height-margin)
+	// toPixel maps a point of the unit square to the plot, with y going up
+	toPixel := func(p [2]float64) (float64, float64) {
+		return float64(plot.Min.X) + p[0]*float64(plot.Dx()-1), float64(plot.Max.Y-1) - p[1]*float64(plot.Dy()-1)
+	}
+	drawLine := func(from, to [2]float64, c color.RGBA, thickness int) {
+		x0, y0 := toPixel(from)
+		x1, y1 := toPixel(to)
+		steps := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+		for s := 0; s <= steps; s++ {
+			t := 0.0
+			if steps > 0 {
+				t = float64(s) / float64(steps)
+			}
+			x, y := int(math.Round(x0+t*(x1-x0))), int(math.Round(y0+t*(y1-y0)))
+			for dx := 0; dx < thickness; dx++ {
+				for dy := 0; dy < thickness; dy++ {
+					img.SetRGBA(x+dx-thickness/2, y+dy-thickness/2, c)
+				}
+			}
+		}
+	}
+
+	for i := 1; i < 4; i++ {
+		v := float64(i) / 4
+		drawLine([2]float64{v, 0}, [2]float64{v, 1}, curveGrid, 1)
+		drawLine([2]float64{0, v}, [2]float64{1, v}, curveGrid, 1)
+	}
+	corners := [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+	for i := 1; i < len(corners); i++ {
+		drawLine(corners[i-1], corners[i], curveAxes, 1)
+	}
+	for i := 1; i < len(guide); i++ {
+		drawLine(guide[i-1], guide[i], curveGuide, 1)
+	}
+	for c, points := range lines {
+		colour := curvePalette[c%len(curvePalette)]
+		for i := 1; i < len(points); i++ {
+			drawLine(points[i-1], points[i], colour, 2)
+		}
+	}
+	return img
+}
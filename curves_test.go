@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestROCCurve(t *testing.T) {
+	scores := []float64{0.9, 0.8, 0.7, 0.6, 0.55, 0.5}
+	positive := []bool{true, true, false, true, false, false}
+	points, auc := ROCCurve(scores, positive)
+	// 8 of the 9 positive and negative pairs are ordered correctly
+	if !almostEqual(auc, 8.0/9) {
+		t.Errorf("expected an AUC of 8/9, got %f", auc)
+	}
+	if len(points) != 7 {
+		t.Fatalf("expected 7 points, got %d", len(points))
+	}
+	first, last := points[0], points[len(points)-1]
+	if !math.IsInf(first.Threshold, 1) || first.FPR != 0 || first.TPR != 0 {
+		t.Errorf("expected the curve to start at (0, 0), got %+v", first)
+	}
+	if last.FPR != 1 || last.TPR != 1 {
+		t.Errorf("expected the curve to end at (1, 1), got %+v", last)
+	}
+	if p := points[3]; p.Threshold != 0.7 || !almostEqual(p.FPR, 1.0/3) || !almostEqual(p.TPR, 2.0/3) {
+		t.Errorf("expected (1/3, 2/3) at the threshold 0.7, got %+v", p)
+	}
+}
+
+func TestROCCurveTies(t *testing.T) {
+	points, auc := ROCCurve([]float64{0.5, 0.5, 0.5, 0.5}, []bool{true, false, true, false})
+	if len(points) != 2 {
+		t.Errorf("expected tied scores to make a single point after the start, got %v", points)
+	}
+	if !almostEqual(auc, 0.5) {
+		t.Errorf("expected an AUC of 0.5, got %f", auc)
+	}
+}
+
+func TestROCCurveUndefined(t *testing.T) {
+	if points, auc := ROCCurve([]float64{0.2, 0.8}, []bool{false, false}); points != nil || !math.IsNaN(auc) {
+		t.Errorf("expected no curve without positives, got %v and %f", points, auc)
+	}
+	if points, auc := ROCCurve([]float64{0.2, 0.8}, []bool{true, true}); points != nil || !math.IsNaN(auc) {
+		t.Errorf("expected no curve without negatives, got %v and %f", points, auc)
+	}
+}
+
+func TestPRCurve(t *testing.T) {
+	scores := []float64{0.9, 0.8, 0.7, 0.6, 0.55, 0.5}
+	positive := []bool{true, true, false, true, false, false}
+	points, ap := PRCurve(scores, positive)
+	// the recall goes up by 1/3 at the precisions 1, 1 and 3/4
+	if !almostEqual(ap, 11.0/12) {
+		t.Errorf("expected an average precision of 11/12, got %f", ap)
+	}
+	if first := points[0]; first.Recall != 0 || first.Precision != 1 {
+		t.Errorf("expected the curve to start at a recall of 0 and precision of 1, got %+v", first)
+	}
+	if last := points[len(points)-1]; last.Recall != 1 || last.Precision != 0.5 {
+		t.Errorf("expected the curve to end at a recall of 1 and precision of 0.5, got %+v", last)
+	}
+
+	_, ap = PRCurve([]float64{0.9, 0.8, 0.1}, []bool{true, true, false})
+	if ap != 1 {
+		t.Errorf("expected a perfect average precision, got %f", ap)
+	}
+	if points, ap := PRCurve([]float64{0.2, 0.8}, []bool{false, false}); points != nil || !math.IsNaN(ap) {
+		t.Errorf("expected no curve without positives, got %v and %f", points, ap)
+	}
+}
+
+func TestOneVsRest(t *testing.T) {
+	curves, err := OneVsRest(metricsTestScores, metricsTestActual, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(curves) != 3 {
+		t.Fatalf("expected curves for 3 classes, got %d", len(curves))
+	}
+	// class a scores 0.9 and 0.6 for its examples and at most 0.5 for the others
+	if curves[0].Name != "a" || curves[0].AUC != 1 || curves[0].AveragePrecision != 1 {
+		t.Errorf("expected class a to be perfectly separated, got %+v", curves[0])
+	}
+	if curves[2].Positives != 3 || curves[2].Negatives != 3 {
+		t.Errorf("expected 3 positives and 3 negatives for class c, got %d and %d", curves[2].Positives, curves[2].Negatives)
+	}
+	// class c scores 0.4, 0.8 and 0.4 against 0.05, 0.1 and 0.1 for the others
+	if curves[2].AUC != 1 {
+		t.Errorf("expected an AUC of 1 for class c, got %f", curves[2].AUC)
+	}
+
+	table := CurvesTable(curves)
+	for _, want := range []string{"auc", "avg precision", "macro avg"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("expected the table to contain %q:\n%s", want, table)
+		}
+	}
+
+	if _, err := OneVsRest(metricsTestScores, metricsTestActual[:2], nil); err == nil {
+		t.Error("expected an error for a missing class")
+	}
+	if _, err := OneVsRest(metricsTestScores, []int{0, 0, 1, 2, 2, 3}, nil); err == nil {
+		t.Error("expected an error for a class out of range")
+	}
+}
+
+func TestMacroAUCSkipsUndefined(t *testing.T) {
+	curves := []ClassCurves{
+		{AUC: 0.8, AveragePrecision: 0.6},
+		{AUC: math.NaN(), AveragePrecision: math.NaN()},
+		{AUC: 0.6, AveragePrecision: 0.4},
+	}
+	auc, ap := MacroAUC(curves)
+	if !almostEqual(auc, 0.7) || !almostEqual(ap, 0.5) {
+		t.Errorf("expected 0.7 and 0.5, got %f and %f", auc, ap)
+	}
+}
+
+func TestWriteCurvesCSV(t *testing.T) {
+	curves, err := OneVsRest(metricsTestScores, metricsTestActual, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCurvesCSV(&buf, curves); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := 1
+	for _, c := range curves {
+		expected += len(c.ROC) + len(c.PR)
+	}
+	if len(rows) != expected {
+		t.Fatalf("expected %d rows, got %d", expected, len(rows))
+	}
+	if strings.Join(rows[0], ",") != "class,name,curve,threshold,x,y" {
+		t.Errorf("unexpected header %v", rows[0])
+	}
+	if rows[1][2] != "roc" || rows[1][3] != "+Inf" || rows[1][4] != "0" || rows[1][5] != "0" {
+		t.Errorf("expected the first row to be the start of the ROC curve, got %v", rows[1])
+	}
+}
+
+func TestRenderROC(t *testing.T) {
+	curves := []ClassCurves{
+		{ROC: []ROCPoint{{FPR: 0, TPR: 0}, {FPR: 0.2, TPR: 0.8}, {FPR: 1, TPR: 1}}},
+		{ROC: []ROCPoint{{FPR: 0, TPR: 0}, {FPR: 0.5, TPR: 0.6}, {FPR: 1, TPR: 1}}},
+		{ROC: []ROCPoint{{FPR: 0, TPR: 0}, {FPR: 0.8, TPR: 0.2}, {FPR: 1, TPR: 1}}},
+	}
+	img := RenderROC(curves, 200, 100)
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 100 {
+		t.Fatalf("expected a 200x100 image, got %v", b)
+	}
+	found := make([]bool, len(curves))
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := range curves {
+			colour := curvePalette[c]
+			if img.Pix[i] == colour.R && img.Pix[i+1] == colour.G && img.Pix[i+2] == colour.B {
+				found[c] = true
+			}
+		}
+	}
+	for c := range found {
+		if !found[c] {
+			t.Errorf("expected the curve of class %d to be drawn", c)
+		}
+	}
+}
+
+func TestSaveCurves(t *testing.T) {
+	curves, err := OneVsRest(metricsTestScores, metricsTestActual, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "curves")
+	if err := SaveCurves(dir, curves); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "curves.csv")); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"roc.png", "pr.png"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if b := img.Bounds(); b.Dx() != curveImageSize || b.Dy() != curveImageSize {
+			t.Errorf("%s: expected a %dx%d image, got %v", name, curveImageSize, curveImageSize, b)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import "fmt"
+
+// Dataset is a collection of examples that can be read in any order, which lets Train work
+// through data that is decoded or read from disk on demand instead of held in memory as float64s
+type Dataset interface {
+	// Len returns the number of examples
+	Len() int
+	// Dims returns the number of features and classes
+	Dims() (features, classes int)
+	// Example copies the features and one-hot label of example i into x and y
+	Example(i int, x, y []float64) error
+	// ClassNames returns the name of each class in the order of the one-hot labels
+	ClassNames() []string
+	// FeatureNames returns the name of each feature, or nil if the features aren't named
+	FeatureNames() []string
+}
+
+// batcher is implemented by datasets that can build a batch better than one example at a time,
+// e.g. by keeping it sparse
+type batcher interface {
+	batch(idx []int) (features, *Matrix, error)
+}
+
+// batchOf returns the examples at the given indices as the input and output of the net
+func batchOf(set Dataset, idx []int) (features, *Matrix, error) {
+	if b, ok := set.(batcher); ok {
+		return b.batch(idx)
+	}
+	numFeatures, numClasses := set.Dims()
+	x := make([]float64, len(idx)*numFeatures)
+	y := make([]float64, len(idx)*numClasses)
+	for i, row := range idx {
+		err := set.Example(row, x[i*numFeatures:(i+1)*numFeatures], y[i*numClasses:(i+1)*numClasses])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return MustNewMatrixF(x, len(idx), numFeatures), MustNewMatrixF(y, len(idx), numClasses), nil
+}
+
+// allRows returns the indices of every example in the set
+func allRows(set Dataset) []int {
+	idx := make([]int, set.Len())
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// eachBatch reads the examples of the set in order, batchSize at a time, and passes the features of
+// every batch to fn. The rows are reused for the next batch.
+func eachBatch(set Dataset, batchSize int, fn func(x [][]float64) error) error {
+	if batchSize < 1 {
+		batchSize = costChunkSize
+	}
+	numFeatures, numClasses := set.Dims()
+	batch := make([][]float64, batchSize)
+	for i := range batch {
+		batch[i] = make([]float64, numFeatures)
+	}
+	y := make([]float64, numClasses)
+	for start := 0; start < set.Len(); start += batchSize {
+		end := start + batchSize
+		if end > set.Len() {
+			end = set.Len()
+		}
+		for i := start; i < end; i++ {
+			if err := set.Example(i, batch[i-start], y); err != nil {
+				return err
+			}
+		}
+		if err := fn(batch[:end-start]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleSlices reads at most n examples spread evenly over the set into memory
+func sampleSlices(set Dataset, n int) ([][]float64, error) {
+	step := 1.0
+	if set.Len() > n {
+		step = float64(set.Len()) / float64(n)
+	} else {
+		n = set.Len()
+	}
+	numFeatures, numClasses := set.Dims()
+	x := make([][]float64, n)
+	y := make([]float64, numClasses)
+	for i := range x {
+		x[i] = make([]float64, numFeatures)
+		if err := set.Example(int(float64(i)*step), x[i], y); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// Preprocessed returns a view of set that runs every example through transform as it's read, so the
+// examples can stay on disk instead of being copied into memory to be preprocessed. Sparse batches
+// stay sparse if the transform keeps zeros at zero, e.g. scaling without centring.
+func Preprocessed(set Dataset, transform func([]float64) ([]float64, error)) (Dataset, error) {
+	p := &preprocessedDataset{set: set, transform: transform}
+	if set.Len() == 0 {
+		return p, nil
+	}
+	// the transform can change the number of features
+	numFeatures, numClasses := set.Dims()
+	x, err := transform(make([]float64, numFeatures))
+	if err != nil {
+		return nil, err
+	}
+	p.numFeatures, p.numClasses = len(x), numClasses
+	p.keepsZeros = true
+	for _, val := range x {
+		if val != 0 {
+			p.keepsZeros = false
+		}
+	}
+	return p, nil
+}
+
+type preprocessedDataset struct {
+	set                     Dataset
+	transform               func([]float64) ([]float64, error)
+	numFeatures, numClasses int
+	// keepsZeros is true if an all zero example is still all zeros after the transform
+	keepsZeros bool
+}
+
+func (p *preprocessedDataset) Len() int {
+	return p.set.Len()
+}
+
+func (p *preprocessedDataset) Dims() (int, int) {
+	return p.numFeatures, p.numClasses
+}
+
+func (p *preprocessedDataset) ClassNames() []string {
+	return p.set.ClassNames()
+}
+
+// FeatureNames returns nil, the transformed features aren't the same as the named ones
+func (p *preprocessedDataset) FeatureNames() []string {
+	return nil
+}
+
+func (p *preprocessedDataset) Example(i int, x, y []float64) error {
+	numFeatures, _ := p.set.Dims()
+	raw := make([]float64, numFeatures)
+	if err := p.set.Example(i, raw, y); err != nil {
+		return err
+	}
+	res, err := p.transform(raw)
+	if err != nil {
+		return err
+	}
+	copy(x, res)
+	return nil
+}
+
+// transformBatch runs every row of a batch read from the underlying set through the transform
+func (p *preprocessedDataset) transformBatch(x *Matrix) (*Matrix, error) {
+	res := make([]float64, 0, x.Rows*p.numFeatures)
+	for r := 0; r < x.Rows; r++ {
+		row, err := p.transform(x.Data[r*x.Cols : (r+1)*x.Cols])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, row...)
+	}
+	return NewMatrixF(res, x.Rows, p.numFeatures)
+}
+
+// batch reads the examples from the underlying set and transforms them, a sparse batch is only made
+// dense if the transform doesn't keep zeros at zero
+func (p *preprocessedDataset) batch(idx []int) (features, *Matrix, error) {
+	x, y, err := batchOf(p.set, idx)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch x := x.(type) {
+	case *Matrix:
+		X, err := p.transformBatch(x)
+		return X, y, err
+	case *SparseMatrix:
+		if p.keepsZeros {
+			X, err := p.transformSparse(x)
+			return X, y, err
+		}
+		X, err := p.transformBatch(x.Dense())
+		return X, y, err
+	}
+	return nil, nil, fmt.Errorf("dataset: can't transform a batch of %T", x)
+}
+
+// transformSparse runs every row of a sparse batch through the transform and keeps the non zero
+// values of the result
+func (p *preprocessedDataset) transformSparse(x *SparseMatrix) (*SparseMatrix, error) {
+	res := &SparseMatrix{Rows: x.Rows, Cols: p.numFeatures, Indptr: make([]int, 1, x.Rows+1)}
+	dense := make([]float64, x.Cols)
+	for r := 0; r < x.Rows; r++ {
+		start, end := x.Indptr[r], x.Indptr[r+1]
+		for i := start; i < end; i++ {
+			dense[x.Indices[i]] = x.Data[i]
+		}
+		row, err := p.transform(dense)
+		if err != nil {
+			return nil, err
+		}
+		for col, val := range row {
+			if val != 0 {
+				res.Indices = append(res.Indices, col)
+				res.Data = append(res.Data, val)
+			}
+		}
+		res.Indptr = append(res.Indptr, len(res.Data))
+		for i := start; i < end; i++ {
+			dense[x.Indices[i]] = 0
+		}
+	}
+	return res, nil
+}
+
+// SliceDataset is a Dataset held in memory
+type SliceDataset struct {
+	X        [][]float64
+	Y        [][]float64
+	Classes  []string
+	Features []string
+	// Encoder is the encoder that produced X from a Table, if any
+	Encoder *TabularEncoder
+}
+
+func (s *SliceDataset) Len() int {
+	return len(s.X)
+}
+
+func (s *SliceDataset) Dims() (int, int) {
+	if len(s.X) == 0 {
+		return 0, 0
+	}
+	return len(s.X[0]), len(s.Y[0])
+}
+
+func (s *SliceDataset) ClassNames() []string {
+	return s.Classes
+}
+
+func (s *SliceDataset) FeatureNames() []string {
+	return s.Features
+}
+
+func (s *SliceDataset) Example(i int, x, y []float64) error {
+	if i < 0 || i >= len(s.X) {
+		return fmt.Errorf("dataset: example %d out of range [0, %d)", i, len(s.X))
+	}
+	copy(x, s.X[i])
+	copy(y, s.Y[i])
+	return nil
+}
+
+func (s *SliceDataset) batch(idx []int) (features, *Matrix, error) {
+	x := make([][]float64, len(idx))
+	y := make([][]float64, len(idx))
+	for i, row := range idx {
+		if row < 0 || row >= len(s.X) {
+			return nil, nil, fmt.Errorf("dataset: example %d out of range [0, %d)", row, len(s.X))
+		}
+		x[i] = s.X[row]
+		y[i] = s.Y[row]
+	}
+	X, err := NewMatrix(x)
+	if err != nil {
+		return nil, nil, err
+	}
+	Y, err := NewMatrix(y)
+	if err != nil {
+		return nil, nil, err
+	}
+	return X, Y, nil
+}
+
+// unlabelled returns the examples in x as a dataset without any classes, so they can be fitted the
+// same way as a dataset
+func unlabelled(x [][]float64) *SliceDataset {
+	return &SliceDataset{X: x, Y: make([][]float64, len(x))}
+}
+
+// sparseDataset keeps the examples as a SparseMatrix so that batches stay sparse
+type sparseDataset struct {
+	x       *SparseMatrix
+	y       [][]float64
+	classes []string
+}
+
+func (s *sparseDataset) Len() int {
+	return s.x.Rows
+}
+
+func (s *sparseDataset) Dims() (int, int) {
+	return s.x.Cols, len(s.y[0])
+}
+
+func (s *sparseDataset) ClassNames() []string {
+	return s.classes
+}
+
+func (s *sparseDataset) FeatureNames() []string {
+	return nil
+}
+
+func (s *sparseDataset) Example(i int, x, y []float64) error {
+	if i < 0 || i >= s.x.Rows {
+		return fmt.Errorf("dataset: example %d out of range [0, %d)", i, s.x.Rows)
+	}
+	for j := range x {
+		x[j] = 0
+	}
+	for j := s.x.Indptr[i]; j < s.x.Indptr[i+1]; j++ {
+		x[s.x.Indices[j]] = s.x.Data[j]
+	}
+	copy(y, s.y[i])
+	return nil
+}
+
+func (s *sparseDataset) batch(idx []int) (features, *Matrix, error) {
+	y := make([][]float64, len(idx))
+	for i, row := range idx {
+		if row < 0 || row >= s.x.Rows {
+			return nil, nil, fmt.Errorf("dataset: example %d out of range [0, %d)", row, s.x.Rows)
+		}
+		y[i] = s.y[row]
+	}
+	Y, err := NewMatrix(y)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.x.selectRows(idx), Y, nil
+}
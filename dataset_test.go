@@ -0,0 +1,203 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// lazyDataset only implements Dataset so batchOf has to read one example at a time
+type lazyDataset struct {
+	set   *SliceDataset
+	reads int
+}
+
+func (d *lazyDataset) Len() int {
+	return d.set.Len()
+}
+
+func (d *lazyDataset) Dims() (int, int) {
+	return d.set.Dims()
+}
+
+func (d *lazyDataset) ClassNames() []string {
+	return d.set.ClassNames()
+}
+
+func (d *lazyDataset) FeatureNames() []string {
+	return d.set.FeatureNames()
+}
+
+func (d *lazyDataset) Example(i int, x, y []float64) error {
+	d.reads++
+	return d.set.Example(i, x, y)
+}
+
+func TestBatchOf(t *testing.T) {
+	set := &lazyDataset{set: &SliceDataset{
+		X: [][]float64{
+			[]float64{1, 2},
+			[]float64{3, 4},
+			[]float64{5, 6},
+		},
+		Y: [][]float64{
+			[]float64{1, 0},
+			[]float64{0, 1},
+			[]float64{1, 0},
+		},
+	}}
+
+	x, y, err := batchOf(set, []int{2, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedX := MustNewMatrix([][]float64{
+		[]float64{5, 6},
+		[]float64{1, 2},
+	})
+	if !x.(*Matrix).Equals(expectedX) {
+		t.Errorf("x is not the same as expected")
+		x.(*Matrix).Print()
+	}
+	if y.At(0, 0) != 1 || y.At(1, 0) != 1 {
+		t.Errorf("y is not the same as expected")
+		y.Print()
+	}
+	if set.reads != 2 {
+		t.Errorf("expected 2 examples to be read, got %d", set.reads)
+	}
+
+	if _, _, err := batchOf(set, []int{3}); err == nil {
+		t.Errorf("expected an error for an out of range example")
+	}
+	if _, _, err := batchOf(set.set, []int{3}); err == nil {
+		t.Errorf("expected an error for an out of range example")
+	}
+}
+
+func TestTrainDatasetMiniBatches(t *testing.T) {
+	set := &lazyDataset{set: &SliceDataset{
+		X: [][]float64{
+			[]float64{0, 1},
+			[]float64{0, 1},
+			[]float64{1, 0},
+			[]float64{1, 0},
+			[]float64{1, 0},
+		},
+		Y: [][]float64{
+			[]float64{1, 0},
+			[]float64{1, 0},
+			[]float64{0, 1},
+			[]float64{0, 1},
+			[]float64{0, 1},
+		},
+	}}
+
+	neuro := &NeuralNet{HiddenNeurons: 3, Alpha: 1e-1, numBatches: 2, numEpochs: 4, batchSize: 2}
+	if batches := neuro.miniBatches(5); len(batches) != 3 || len(batches[2]) != 1 {
+		t.Errorf("expected 5 examples to be split into batches of 2, 2 and 1, got %v", batches)
+	}
+
+	jTrain, jValidation, err := neuro.TrainDataset(set, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(jTrain-jValidation) > 1e-12 {
+		t.Errorf("expected the same cost for the same set, got %f and %f", jTrain, jValidation)
+	}
+	// 4 epochs of 5 examples and the final cost for both sets
+	if set.reads != 4*5+2*5 {
+		t.Errorf("expected %d reads, got %d", 4*5+2*5, set.reads)
+	}
+}
+
+func TestCostChunks(t *testing.T) {
+	n := costChunkSize + 10
+	x := make([][]float64, n)
+	y := make([][]float64, n)
+	for i := range x {
+		x[i] = []float64{float64(i % 7), float64(i % 3)}
+		y[i] = []float64{float64(i % 2), float64(1 - i%2)}
+	}
+	neuro := &NeuralNet{HiddenNeurons: 3}
+	neuro.W1 = NewRandomMatrix(3, 3)
+	neuro.W2 = NewRandomMatrix(2, 4)
+
+	expected, _, _ := neuro.costFunction(MustNewMatrix(x), MustNewMatrix(y), 0)
+	actual, err := neuro.cost(&SliceDataset{X: x, Y: y})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(expected-actual) > 1e-9 {
+		t.Errorf("expected the chunked cost to be %f, got %f", expected, actual)
+	}
+}
+
+func TestTrainDatasetEmpty(t *testing.T) {
+	set := &SliceDataset{X: [][]float64{{0, 1}, {1, 0}}, Y: [][]float64{{1, 0}, {0, 1}}}
+	neuro := &NeuralNet{HiddenNeurons: 2, Alpha: 0.5, numBatches: 1, numEpochs: 5}
+	if _, err := neuro.cost(&SliceDataset{}); err == nil {
+		t.Errorf("expected an error for the cost of an empty set")
+	}
+	if _, _, err := neuro.TrainDataset(&SliceDataset{}, set); err == nil {
+		t.Errorf("expected an error without training examples")
+	}
+	// an empty validation set, like -val 0, is skipped rather than giving a NaN cost
+	jTrain, jValidation, err := neuro.TrainDataset(set, &SliceDataset{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isFinite(jTrain) || jValidation != 0 {
+		t.Errorf("expected a training cost and no validation cost, got %f and %f", jTrain, jValidation)
+	}
+	tuner := &Tuner{NewNet: func() *NeuralNet { return &NeuralNet{HiddenNeurons: 2, numBatches: 1, numEpochs: 1} }, Train: set, Validation: &SliceDataset{}}
+	if _, err := tuner.Run([]Trial{{HiddenNeurons: 2, Alpha: 0.1}}); err == nil {
+		t.Errorf("expected the tuner to fail without validation examples")
+	}
+}
+
+func TestPreprocessed(t *testing.T) {
+	set := &lazyDataset{set: &SliceDataset{
+		X: [][]float64{{1, 2}, {3, 4}},
+		Y: [][]float64{{1, 0}, {0, 1}},
+	}}
+	// sums the features and adds them as a third one
+	view, err := Preprocessed(set, func(row []float64) ([]float64, error) {
+		return append(append([]float64(nil), row...), row[0]+row[1]), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numFeatures, numClasses := view.Dims(); numFeatures != 3 || numClasses != 2 {
+		t.Errorf("expected the dimensions 3 and 2, got %d and %d", numFeatures, numClasses)
+	}
+	if set.reads != 0 {
+		t.Errorf("expected no examples to be read up front, got %d reads", set.reads)
+	}
+	x, y, err := batchOf(view, []int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !x.(*Matrix).Equals(MustNewMatrix([][]float64{{3, 4, 7}})) || !y.Equals(MustNewMatrix([][]float64{{0, 1}})) {
+		t.Errorf("unexpected example %v %v", x.(*Matrix).Data, y.Data)
+	}
+	if set.set.X[1][0] != 3 {
+		t.Errorf("expected the original examples to be unchanged")
+	}
+}
+
+func TestSampleSlices(t *testing.T) {
+	set := &SliceDataset{X: make([][]float64, 10), Y: make([][]float64, 10)}
+	for i := range set.X {
+		set.X[i], set.Y[i] = []float64{float64(i)}, []float64{1}
+	}
+	x, err := sampleSlices(set, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 4 || x[0][0] != 0 || x[1][0] != 2 || x[2][0] != 5 || x[3][0] != 7 {
+		t.Errorf("expected 4 examples spread over the set, got %v", x)
+	}
+	if x, _ := sampleSlices(set, 20); len(x) != 10 {
+		t.Errorf("expected every example of a small set, got %d", len(x))
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ColumnEncoding is how a column of strings is turned into features
+type ColumnEncoding int
+
+const (
+	// AutoEncoding is resolved by Fit to Numeric if every value is a number and OneHot otherwise
+	AutoEncoding ColumnEncoding = iota
+	// Numeric parses the values as numbers, missing values are NaN
+	Numeric
+	// OneHot has a feature per category seen by Fit, unseen and missing categories are all zeros
+	OneHot
+	// Ordinal is a single feature with the position of the category in the sorted categories seen
+	// by Fit, unseen and missing categories are -1
+	Ordinal
+	// Hashing has a fixed number of features and sets the one that the category hashes to, it needs
+	// no fitting and handles unseen categories at the price of collisions
+	Hashing
+)
+
+const defaultHashBuckets = 16
+
+var columnEncodingNames = []string{"auto", "numeric", "onehot", "ordinal", "hashing"}
+
+func (e ColumnEncoding) String() string {
+	if e < 0 || int(e) >= len(columnEncodingNames) {
+		return fmt.Sprintf("ColumnEncoding(%d)", int(e))
+	}
+	return columnEncodingNames[e]
+}
+
+// MarshalText implements encoding.TextMarshaler so the encoding is readable in saved models
+func (e ColumnEncoding) MarshalText() ([]byte, error) {
+	if e < 0 || int(e) >= len(columnEncodingNames) {
+		return nil, fmt.Errorf("unknown column encoding %d", int(e))
+	}
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (e *ColumnEncoding) UnmarshalText(text []byte) error {
+	for i, name := range columnEncodingNames {
+		if name == string(text) {
+			*e = ColumnEncoding(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown column encoding %q", text)
+}
+
+// ColumnEncoder encodes a single column of a Table
+type ColumnEncoder struct {
+	Name       string
+	Encoding   ColumnEncoding
+	Categories []string `json:",omitempty"`
+	Buckets    int      `json:",omitempty"`
+
+	index map[string]int
+}
+
+// Width returns the number of features the column is encoded into
+func (c *ColumnEncoder) Width() int {
+	switch c.Encoding {
+	case OneHot:
+		return len(c.Categories)
+	case Hashing:
+		return c.Buckets
+	default:
+		return 1
+	}
+}
+
+func (c *ColumnEncoder) fit(values []string) error {
+	if c.Encoding == AutoEncoding {
+		c.Encoding = Numeric
+		for _, val := range values {
+			if _, err := strconv.ParseFloat(val, 64); val != "" && err != nil {
+				c.Encoding = OneHot
+				break
+			}
+		}
+	}
+
+	switch c.Encoding {
+	case Numeric:
+		for _, val := range values {
+			if _, err := strconv.ParseFloat(val, 64); val != "" && err != nil {
+				return fmt.Errorf("column %s: %q is not a number, it should be encoded as a category", c.Name, val)
+			}
+		}
+	case OneHot, Ordinal:
+		seen := make(map[string]bool)
+		c.Categories = c.Categories[:0]
+		for _, val := range values {
+			if val != "" && !seen[val] {
+				seen[val] = true
+				c.Categories = append(c.Categories, val)
+			}
+		}
+		sort.Strings(c.Categories)
+		c.buildIndex()
+	case Hashing:
+		if c.Buckets <= 0 {
+			c.Buckets = defaultHashBuckets
+		}
+	default:
+		return fmt.Errorf("column %s: unknown encoding %s", c.Name, c.Encoding)
+	}
+	return nil
+}
+
+func (c *ColumnEncoder) buildIndex() {
+	c.index = make(map[string]int, len(c.Categories))
+	for i, category := range c.Categories {
+		c.index[category] = i
+	}
+}
+
+// encode writes the Width() features of field into dst
+func (c *ColumnEncoder) encode(field string, dst []float64) error {
+	switch c.Encoding {
+	case Numeric:
+		if field == "" {
+			dst[0] = math.NaN()
+			return nil
+		}
+		val, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return fmt.Errorf("column %s: %s", c.Name, err)
+		}
+		dst[0] = val
+	case OneHot:
+		for i := range dst {
+			dst[i] = 0
+		}
+		if idx, ok := c.index[field]; ok {
+			dst[idx] = 1
+		}
+	case Ordinal:
+		dst[0] = -1
+		if idx, ok := c.index[field]; ok {
+			dst[0] = float64(idx)
+		}
+	case Hashing:
+		for i := range dst {
+			dst[i] = 0
+		}
+		if field != "" {
+			h := fnv.New32a()
+			h.Write([]byte(field))
+			dst[h.Sum32()%uint32(c.Buckets)] = 1
+		}
+	default:
+		return fmt.Errorf("column %s: the encoder hasn't been fitted", c.Name)
+	}
+	return nil
+}
+
+func (c *ColumnEncoder) featureNames() []string {
+	switch c.Encoding {
+	case OneHot:
+		names := make([]string, len(c.Categories))
+		for i, category := range c.Categories {
+			names[i] = c.Name + "=" + category
+		}
+		return names
+	case Hashing:
+		names := make([]string, c.Buckets)
+		for i := range names {
+			names[i] = fmt.Sprintf("%s#%d", c.Name, i)
+		}
+		return names
+	default:
+		return []string{c.Name}
+	}
+}
+
+// UnmarshalJSON rebuilds the category lookup after loading a saved encoder
+func (c *ColumnEncoder) UnmarshalJSON(data []byte) error {
+	type plain ColumnEncoder
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	c.buildIndex()
+	return nil
+}
+
+// TabularEncoder turns the raw fields of a row into a feature vector. It's fitted on the training
+// rows of a Table and saved with the net so that new rows are encoded the same way.
+type TabularEncoder struct {
+	Columns []*ColumnEncoder
+}
+
+// NewTabularEncoder returns an unfitted encoder for the feature columns of the table
+func NewTabularEncoder(table *Table, hashBuckets int) *TabularEncoder {
+	if hashBuckets <= 0 {
+		hashBuckets = defaultHashBuckets
+	}
+	numColumns := len(table.Encodings)
+	if numColumns == 0 && len(table.Rows) > 0 {
+		numColumns = len(table.Rows[0])
+	}
+	e := &TabularEncoder{Columns: make([]*ColumnEncoder, numColumns)}
+	for i := range e.Columns {
+		c := &ColumnEncoder{Name: fmt.Sprintf("column %d", i)}
+		if i < len(table.Names) {
+			c.Name = table.Names[i]
+		}
+		if i < len(table.Encodings) {
+			c.Encoding = table.Encodings[i]
+		}
+		if c.Encoding == Hashing {
+			c.Buckets = hashBuckets
+		}
+		e.Columns[i] = c
+	}
+	return e
+}
+
+// Fit learns the categories of each column from the given rows of the table
+func (e *TabularEncoder) Fit(table *Table, rows []int) error {
+	values := make([]string, len(rows))
+	for col, c := range e.Columns {
+		for i, row := range rows {
+			values[i] = table.Rows[row][col]
+		}
+		if err := c.fit(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Width returns the number of features a row is encoded into
+func (e *TabularEncoder) Width() int {
+	width := 0
+	for _, c := range e.Columns {
+		width += c.Width()
+	}
+	return width
+}
+
+// FeatureNames returns the name of each encoded feature
+func (e *TabularEncoder) FeatureNames() []string {
+	var names []string
+	for _, c := range e.Columns {
+		names = append(names, c.featureNames()...)
+	}
+	return names
+}
+
+// Encode returns the features for the fields of a single row, missing fields should be ""
+func (e *TabularEncoder) Encode(fields []string) ([]float64, error) {
+	if len(fields) != len(e.Columns) {
+		return nil, fmt.Errorf("encoder: expected %d fields, got %d", len(e.Columns), len(fields))
+	}
+	res := make([]float64, e.Width())
+	offset := 0
+	for i, c := range e.Columns {
+		width := c.Width()
+		if err := c.encode(fields[i], res[offset:offset+width]); err != nil {
+			return nil, err
+		}
+		offset += width
+	}
+	return res, nil
+}
+
+// Transform encodes every row of the table
+func (e *TabularEncoder) Transform(table *Table) ([][]float64, error) {
+	res := make([][]float64, len(table.Rows))
+	for i, row := range table.Rows {
+		var err error
+		if res[i], err = e.Encode(row); err != nil {
+			return nil, fmt.Errorf("row %d: %s", i, err)
+		}
+	}
+	return res, nil
+}
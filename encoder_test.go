@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var encoderTestTable = &Table{
+	Names: []string{"size", "colour", "region", "grade"},
+	Rows: [][]string{
+		{"1.5", "red", "north", "b"},
+		{"2", "green", "south", "a"},
+		{"", "red", "east", "c"},
+	},
+	Labels:    []string{"x", "y", "x"},
+	Classes:   []string{"x", "y"},
+	Encodings: []ColumnEncoding{AutoEncoding, AutoEncoding, Hashing, Ordinal},
+}
+
+func TestTabularEncoder(t *testing.T) {
+	enc := NewTabularEncoder(encoderTestTable, 4)
+	if err := enc.Fit(encoderTestTable, []int{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if enc.Columns[0].Encoding != Numeric {
+		t.Errorf("expected a numeric column to be detected, got %s", enc.Columns[0].Encoding)
+	}
+	if enc.Columns[1].Encoding != OneHot {
+		t.Errorf("expected a string column to be one-hot encoded, got %s", enc.Columns[1].Encoding)
+	}
+	// 1 numeric, 2 colours, 4 buckets and 1 ordinal
+	if enc.Width() != 8 {
+		t.Errorf("expected 8 features, got %d", enc.Width())
+	}
+	names := enc.FeatureNames()
+	if len(names) != 8 || names[1] != "colour=green" || names[3] != "region#0" || names[7] != "grade" {
+		t.Errorf("unexpected feature names %v", names)
+	}
+
+	x, err := enc.Transform(encoderTestTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x[0][0] != 1.5 || x[0][1] != 0 || x[0][2] != 1 || x[0][7] != 1 {
+		t.Errorf("unexpected first row %v", x[0])
+	}
+	if !math.IsNaN(x[2][0]) {
+		t.Errorf("expected a missing numeric value to be NaN, got %f", x[2][0])
+	}
+	// grade c wasn't in the rows used for fitting
+	if x[2][7] != -1 {
+		t.Errorf("expected an unseen ordinal category to be -1, got %f", x[2][7])
+	}
+
+	hashed := 0.0
+	for _, val := range x[2][3:7] {
+		hashed += val
+	}
+	if hashed != 1 {
+		t.Errorf("expected exactly one hashing bucket to be set, got %v", x[2][3:7])
+	}
+}
+
+func TestTabularEncoderUnseenCategory(t *testing.T) {
+	enc := NewTabularEncoder(encoderTestTable, 4)
+	if err := enc.Fit(encoderTestTable, []int{0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	x, err := enc.Encode([]string{"3", "purple", "west", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x[1] != 0 || x[2] != 0 {
+		t.Errorf("expected an unseen one-hot category to be all zeros, got %v", x[1:3])
+	}
+	if x[7] != -1 {
+		t.Errorf("expected a missing ordinal category to be -1, got %f", x[7])
+	}
+
+	if _, err := enc.Encode([]string{"3"}); err == nil {
+		t.Errorf("expected an error for the wrong number of fields")
+	}
+	if _, err := enc.Encode([]string{"three", "red", "west", "a"}); err == nil {
+		t.Errorf("expected an error for a non numeric value in a numeric column")
+	}
+}
+
+func TestTabularEncoderNotNumeric(t *testing.T) {
+	table := &Table{
+		Rows:      [][]string{{"1"}, {"two"}},
+		Encodings: []ColumnEncoding{Numeric},
+	}
+	if err := NewTabularEncoder(table, 0).Fit(table, []int{0, 1}); err == nil {
+		t.Errorf("expected an error when a numeric column has strings")
+	}
+}
+
+func TestTabularEncoderJSON(t *testing.T) {
+	enc := NewTabularEncoder(encoderTestTable, 4)
+	if err := enc.Fit(encoderTestTable, []int{0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &TabularEncoder{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range encoderTestTable.Rows {
+		expected, _ := enc.Encode(row)
+		actual, err := loaded.Encode(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range expected {
+			if expected[i] != actual[i] && !(math.IsNaN(expected[i]) && math.IsNaN(actual[i])) {
+				t.Errorf("expected the loaded encoder to encode %v the same, got %v and %v", row, expected, actual)
+				break
+			}
+		}
+	}
+}
+
+func TestLoadCSVCategorical(t *testing.T) {
+	file := writeTestFile(t, "data.csv", "colour,size,class\nred,1,a\nblue,2,b\nred,3,a\n")
+
+	set, err := LoadCSV(file, CSVSchema{Header: true, Categorical: map[string]ColumnEncoding{"colour": Ordinal}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.X[0][0] != 1 || set.X[1][0] != 0 {
+		t.Errorf("expected colour to be ordinal encoded, got %v", set.X)
+	}
+	if set.Encoder == nil {
+		t.Errorf("expected the fitted encoder to be returned")
+	}
+
+	set, err = LoadCSV(file, CSVSchema{Header: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set.X[0]) != 3 {
+		t.Errorf("expected colour to be one-hot encoded by default, got %v", set.X[0])
+	}
+}
+
+func TestLoadTableFitOnTrainingRows(t *testing.T) {
+	file := writeTestFile(t, "data.csv", "colour,size,class\nred,1,a\nblue,2,b\ngreen,3,a\n")
+
+	l, err := FindLoader("", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, enc, err := l.(TableLoader).LoadTable(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Fit(table, []int{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	set, err := EncodeTable(table, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// green is only in the held out row so it's encoded like an unseen category
+	if len(set.X[2]) != 3 || set.X[2][0] != 0 || set.X[2][1] != 0 {
+		t.Errorf("expected green to be unknown to the encoder, got %v", set.X[2])
+	}
+	if labels, err := table.ClassIndices(); err != nil || !reflect.DeepEqual(labels, []int{0, 1, 0}) {
+		t.Errorf("expected the classes 0, 1, 0, got %v %v", labels, err)
+	}
+}
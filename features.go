@@ -0,0 +1,24 @@
+package main
+
+// features is the input to the first layer of the net. It's implemented by both Matrix and
+// SparseMatrix so that the products with W1, which are by far the most expensive ones for wide
+// inputs, can skip the zeros when the input is sparse.
+type features interface {
+	dims() (rows, cols int)
+	// biasDotT returns [1 X] * W^T, i.e. the input with a bias column multiplied by the weights
+	biasDotT(W *Matrix) *Matrix
+	// tDotBias returns D^T * [1 X], i.e. the gradient of the weights used by biasDotT
+	tDotBias(D *Matrix) *Matrix
+}
+
+func (A *Matrix) dims() (rows, cols int) {
+	return A.Rows, A.Cols
+}
+
+func (A *Matrix) biasDotT(W *Matrix) *Matrix {
+	return A.AddBias().MustDot(W.T())
+}
+
+func (A *Matrix) tDotBias(D *Matrix) *Matrix {
+	return D.T().MustDot(A.AddBias())
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// HalvingOptions configures SuccessiveHalving and Hyperband
+type HalvingOptions struct {
+	// MinEpochs is how long every trial is trained for before the poor ones are stopped
+	MinEpochs int
+	// MaxEpochs is how long the best trials are trained for
+	MaxEpochs int
+	// Eta is the factor the epochs grow by between rounds, only the best 1/Eta of the trials are
+	// kept after each round. Defaults to 3.
+	Eta int
+	// Seed makes the sampled trials reproducible, it must be the same when resuming
+	Seed int64
+	// Dir keeps the tuning state and the weights of the trials so that an interrupted search can be
+	// resumed by running it again with the same options. Nothing is kept on disk if it's empty.
+	Dir string
+}
+
+func (o HalvingOptions) validate() (HalvingOptions, error) {
+	if o.Eta == 0 {
+		o.Eta = 3
+	}
+	if o.Eta < 2 {
+		return o, fmt.Errorf("tuner: eta must be at least 2, got %d", o.Eta)
+	}
+	if o.MinEpochs < 1 || o.MaxEpochs < o.MinEpochs {
+		return o, fmt.Errorf("tuner: the epochs must be 1 <= min (%d) <= max (%d)", o.MinEpochs, o.MaxEpochs)
+	}
+	return o, nil
+}
+
+// SuccessiveHalving samples n trials from space and trains them all for MinEpochs, then keeps
+// training the best 1/Eta of them for Eta times as many epochs until MaxEpochs is reached. The
+// results have the latest validation cost of every trial.
+func (t *Tuner) SuccessiveHalving(space SearchSpace, n int, opts HalvingOptions) ([]TrialResult, error) {
+	opts, err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("tuner: there are no trials to run")
+	}
+	state, err := loadTuningState(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	trials := RandomTrials(space, trialOf(t.NewNet()), n, opts.Seed)
+	results, err := t.halving("sh", trials, opts.MinEpochs, opts, state)
+	if err != nil {
+		return nil, err
+	}
+	return t.finishHalving(results, opts)
+}
+
+// Hyperband runs successive halving in brackets that trade the number of trials against how long
+// they are trained before the first are stopped, from many trials starting with MinEpochs to a few
+// trained for MaxEpochs. This hedges against poor trials that only improve late.
+func (t *Tuner) Hyperband(space SearchSpace, opts HalvingOptions) ([]TrialResult, error) {
+	opts, err := opts.validate()
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadTuningState(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	base := trialOf(t.NewNet())
+
+	// sMax is the number of times the trials can be halved between MinEpochs and MaxEpochs
+	sMax := 0
+	for epochs := opts.MinEpochs * opts.Eta; epochs <= opts.MaxEpochs; epochs *= opts.Eta {
+		sMax++
+	}
+	var results []TrialResult
+	for s := sMax; s >= 0; s-- {
+		eta := math.Pow(float64(opts.Eta), float64(s))
+		n := int(math.Ceil(float64(sMax+1) / float64(s+1) * eta))
+		epochs := int(float64(opts.MaxEpochs) / eta)
+		trials := RandomTrials(space, base, n, opts.Seed+int64(s))
+		res, err := t.halving(fmt.Sprintf("b%d", s), trials, epochs, opts, state)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res...)
+	}
+	return t.finishHalving(results, opts)
+}
+
+// halving runs successive halving of trials starting with epochs and returns the latest result of
+// every trial. Results in state are reused rather than trained again.
+func (t *Tuner) halving(bracket string, trials []Trial, epochs int, opts HalvingOptions, state *tuningState) ([]TrialResult, error) {
+	latest := make([]TrialResult, len(trials))
+	nets := make(map[int]*NeuralNet)
+	alive := make([]int, len(trials))
+	for i := range alive {
+		alive[i] = i
+	}
+	id := func(i int) string {
+		return fmt.Sprintf("%s-%d", bracket, i)
+	}
+	dropped := -1
+
+	for {
+		if epochs > opts.MaxEpochs {
+			epochs = opts.MaxEpochs
+		}
+
+		var jobs []trialJob
+		var jobTrials []int
+		for _, i := range alive {
+			history, err := state.history(id(i), trials[i])
+			if err != nil {
+				return nil, err
+			}
+			if rung, ok := history.rung(epochs); ok {
+				latest[i] = rung.result(trials[i], id(i))
+				continue
+			}
+			job := trialJob{trial: trials[i], epochs: epochs}
+			if done := history.epochs(); done > 0 {
+				net, ok := nets[i]
+				if !ok {
+					if net, err = loadTrialNet(opts.Dir, id(i), done); err != nil {
+						return nil, err
+					}
+				}
+				job.net, job.done, job.epochs = net, done, epochs-done
+			}
+			jobs = append(jobs, job)
+			jobTrials = append(jobTrials, i)
+		}
+
+		var stale []string
+		for j, res := range t.run(jobs) {
+			i := jobTrials[j]
+			res.id = id(i)
+			state.record(res)
+			if res.net != nil && opts.Dir != "" {
+				// the weights are read back from disk when needed so they don't pile up in memory
+				if err := saveTrialNet(opts.Dir, res.id, res.Epochs, res.net); err != nil {
+					return nil, err
+				}
+				if done := jobs[j].done; done > 0 {
+					stale = append(stale, trialNetFile(opts.Dir, res.id, done))
+				}
+			} else if res.net != nil {
+				nets[i] = res.net
+			}
+			res.net = nil
+			latest[i] = res
+		}
+		if err := state.save(opts.Dir); err != nil {
+			return nil, err
+		}
+		// the older weights are only removed once the state no longer refers to them
+		for _, file := range stale {
+			os.Remove(file)
+		}
+
+		// the failed trials are stopped regardless of how many are kept
+		var ok []int
+		for _, i := range alive {
+			if latest[i].Err == nil {
+				ok = append(ok, i)
+			}
+		}
+		alive = ok
+		sort.SliceStable(alive, func(a, b int) bool {
+			return latest[alive[a]].ValidationCost < latest[alive[b]].ValidationCost
+		})
+		if epochs >= opts.MaxEpochs || len(alive) <= 1 {
+			break
+		}
+		keep := len(alive) / opts.Eta
+		if keep < 1 {
+			keep = 1
+		}
+		for _, i := range alive[keep:] {
+			// the best stopped trial keeps its net in case no survivor gets a lower cost
+			if dropped < 0 || latest[i].ValidationCost < latest[dropped].ValidationCost {
+				if dropped >= 0 {
+					delete(nets, dropped)
+				}
+				dropped = i
+				continue
+			}
+			delete(nets, i)
+		}
+		alive = alive[:keep]
+		epochs *= opts.Eta
+	}
+	// only the nets of the survivors and the best stopped trial are kept in memory
+	for i, nn := range nets {
+		latest[i].net = nn
+	}
+	return latest, nil
+}
+
+// finishHalving orders the results and reads the weights of the best trial back from disk if needed
+func (t *Tuner) finishHalving(results []TrialResult, opts HalvingOptions) ([]TrialResult, error) {
+	results, err := t.finish(results)
+	if err != nil {
+		return results, err
+	}
+	if best := &results[0]; best.net == nil {
+		net, err := loadTrialNet(opts.Dir, best.id, best.Epochs)
+		if err != nil {
+			return results, err
+		}
+		nn := t.NewNet()
+		best.Trial.apply(nn)
+		nn.W1, nn.W2 = net.W1, net.W2
+		best.net = nn
+	}
+	return results, nil
+}
+
+// tuningState is the progress of a search, it's saved after every round so the search can resume
+type tuningState struct {
+	Trials map[string]*trialHistory
+}
+
+// trialHistory has the result of every round a trial took part in
+type trialHistory struct {
+	Trial Trial
+	Rungs []rung
+}
+
+// rung is the result of a trial after training for Epochs in total
+type rung struct {
+	Epochs                    int
+	TrainCost, ValidationCost float64
+	Err                       string `json:",omitempty"`
+}
+
+const tuningStateFile = "state.json"
+
+// loadTuningState reads the state in dir, a missing file or dir starts a new search
+func loadTuningState(dir string) (*tuningState, error) {
+	state := &tuningState{Trials: make(map[string]*trialHistory)}
+	if dir == "" {
+		return state, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("tuner: %s", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, tuningStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("tuner: %s", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("tuner: %s: %s", filepath.Join(dir, tuningStateFile), err)
+	}
+	return state, nil
+}
+
+// save writes the state to dir by replacing the previous file so an interruption can't corrupt it
+func (s *tuningState) save(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	file := filepath.Join(dir, tuningStateFile)
+	if err := os.WriteFile(file+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	return nil
+}
+
+// history returns the history of the trial with id, the trial must be the same as when it was
+// recorded otherwise the state is from a different search
+func (s *tuningState) history(id string, trial Trial) (*trialHistory, error) {
+	h, ok := s.Trials[id]
+	if !ok {
+		h = &trialHistory{Trial: trial}
+		s.Trials[id] = h
+	}
+	if h.Trial != trial {
+		return nil, fmt.Errorf("tuner: trial %s was %s in the saved state but is now %s, the state is from a different search", id, h.Trial, trial)
+	}
+	return h, nil
+}
+
+// record adds the result to the history of its trial
+func (s *tuningState) record(res TrialResult) {
+	r := rung{Epochs: res.Epochs, TrainCost: res.TrainCost, ValidationCost: res.ValidationCost}
+	if res.Err != nil {
+		// the costs might not be finite which JSON can't represent
+		r = rung{Epochs: res.Epochs, Err: res.Err.Error()}
+	}
+	h := s.Trials[res.id]
+	h.Rungs = append(h.Rungs, r)
+}
+
+// rung returns the result after epochs if the trial got there
+func (h *trialHistory) rung(epochs int) (rung, bool) {
+	for _, r := range h.Rungs {
+		if r.Epochs == epochs {
+			return r, true
+		}
+	}
+	return rung{}, false
+}
+
+// epochs returns how long the trial has been trained for, failed rounds aren't counted since their
+// weights weren't saved
+func (h *trialHistory) epochs() int {
+	done := 0
+	for _, r := range h.Rungs {
+		if r.Err == "" && r.Epochs > done {
+			done = r.Epochs
+		}
+	}
+	return done
+}
+
+func (r rung) result(trial Trial, id string) TrialResult {
+	res := TrialResult{Trial: trial, Epochs: r.Epochs, TrainCost: r.TrainCost, ValidationCost: r.ValidationCost, id: id}
+	if r.Err != "" {
+		res.Err = errors.New(r.Err)
+	}
+	return res
+}
+
+// trialNetFile is where the weights of a trial are kept after it has been trained for epochs
+func trialNetFile(dir, id string, epochs int) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%d.bin", id, epochs))
+}
+
+func saveTrialNet(dir, id string, epochs int, nn *NeuralNet) error {
+	data, err := nn.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	if err := os.WriteFile(trialNetFile(dir, id, epochs), data, 0644); err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	return nil
+}
+
+func loadTrialNet(dir, id string, epochs int) (*NeuralNet, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("tuner: the weights of trial %s aren't kept", id)
+	}
+	data, err := os.ReadFile(trialNetFile(dir, id, epochs))
+	if err != nil {
+		return nil, fmt.Errorf("tuner: %s", err)
+	}
+	nn := &NeuralNet{}
+	if err := nn.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("tuner: %s", err)
+	}
+	return nn, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var halvingTestSpace = SearchSpace{AlphaDist: LogUniform{1e-4, 1}, Activation: []Activation{Sigmoid, Tanh}}
+
+func TestSuccessiveHalving(t *testing.T) {
+	train, validation := separableTestSets()
+	tuner := &Tuner{
+		NewNet: func() *NeuralNet {
+			return &NeuralNet{HiddenNeurons: 4, numBatches: 1, numEpochs: 18}
+		},
+		Train:      train,
+		Validation: validation,
+		CPUs:       2,
+	}
+	results, err := tuner.SuccessiveHalving(halvingTestSpace, 9, HalvingOptions{MinEpochs: 2, MaxEpochs: 18, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 9 {
+		t.Fatalf("expected a result per trial, got %d", len(results))
+	}
+	epochs := make(map[int]int)
+	for _, res := range results {
+		epochs[res.Epochs]++
+	}
+	if epochs[2] != 6 || epochs[6] != 2 || epochs[18] != 1 {
+		t.Errorf("expected 6, 2 and 1 trials stopped after 2, 6 and 18 epochs, got %v", epochs)
+	}
+	if best := Best(results); best == nil || best.Alpha != results[0].Alpha {
+		t.Errorf("expected the net of the best trial")
+	}
+}
+
+func TestSuccessiveHalvingResume(t *testing.T) {
+	dir := t.TempDir()
+	opts := HalvingOptions{MinEpochs: 2, MaxEpochs: 18, Seed: 1, Dir: dir}
+	train, validation := separableTestSets()
+	tuner := &Tuner{
+		NewNet: func() *NeuralNet {
+			return &NeuralNet{HiddenNeurons: 4, numBatches: 1, numEpochs: 18}
+		},
+		Train:      train,
+		Validation: validation,
+		CPUs:       2,
+	}
+	first, err := tuner.SuccessiveHalving(halvingTestSpace, 9, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	final := first[0].id
+	for _, res := range first {
+		if res.Epochs == 18 {
+			final = res.id
+		}
+	}
+	if _, err := os.Stat(trialNetFile(dir, final, 18)); err != nil {
+		t.Errorf("expected the weights of the last trial to be kept, %s", err)
+	}
+	if _, err := os.Stat(trialNetFile(dir, final, 6)); !os.IsNotExist(err) {
+		t.Errorf("expected the older weights of the last trial to be removed")
+	}
+
+	// everything is in the state so nothing is trained again
+	second, err := tuner.SuccessiveHalving(halvingTestSpace, 9, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range first {
+		if first[i].id != second[i].id || first[i].ValidationCost != second[i].ValidationCost {
+			t.Fatalf("expected the same results when resuming a finished search")
+		}
+	}
+	if !second[0].net.W1.Equals(first[0].net.W1) {
+		t.Errorf("expected the weights of the best trial to be read back")
+	}
+
+	// an interrupted last round is trained again from the weights of the round before
+	state, err := loadTuningState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := state.Trials[final]
+	h.Rungs = h.Rungs[:len(h.Rungs)-1]
+	if err := state.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(trialNetFile(dir, final, 18))
+	if err := saveTrialNet(dir, final, 6, &NeuralNet{HiddenNeurons: 4, W1: NewOnes(4, 3), W2: NewOnes(2, 5)}); err != nil {
+		t.Fatal(err)
+	}
+	third, err := tuner.SuccessiveHalving(halvingTestSpace, 9, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range third {
+		if res.id == final && (res.Epochs != 18 || res.ValidationCost == 0) {
+			t.Errorf("expected the last trial to be trained to 18 epochs, got %d", res.Epochs)
+		}
+	}
+
+	opts.Seed = 2
+	if _, err := tuner.SuccessiveHalving(halvingTestSpace, 9, opts); err == nil {
+		t.Errorf("expected an error when resuming with different trials")
+	}
+	if _, err := os.Stat(filepath.Join(dir, tuningStateFile)); err != nil {
+		t.Errorf("expected the state to be saved, %s", err)
+	}
+}
+
+func TestHyperband(t *testing.T) {
+	train, validation := separableTestSets()
+	tuner := &Tuner{
+		NewNet: func() *NeuralNet {
+			return &NeuralNet{HiddenNeurons: 4, numBatches: 1, numEpochs: 18}
+		},
+		Train:      train,
+		Validation: validation,
+		CPUs:       2,
+	}
+	results, err := tuner.Hyperband(halvingTestSpace, HalvingOptions{MinEpochs: 1, MaxEpochs: 9, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// brackets of 9 trials from 1 epoch, 5 from 3 and 3 from 9
+	if len(results) != 17 {
+		t.Fatalf("expected 17 trials, got %d", len(results))
+	}
+	trained := 0
+	for _, res := range results {
+		if res.Epochs == 9 {
+			trained++
+		}
+	}
+	if trained != 5 {
+		t.Errorf("expected 5 trials trained to the end, got %d", trained)
+	}
+	if Best(results) == nil {
+		t.Errorf("expected the net of the best trial")
+	}
+}
+
+func TestHalvingOptionsInvalid(t *testing.T) {
+	for _, opts := range []HalvingOptions{{MinEpochs: 0, MaxEpochs: 1}, {MinEpochs: 5, MaxEpochs: 4}, {MinEpochs: 1, MaxEpochs: 2, Eta: 1}} {
+		// the options are checked before any trial is trained
+		if _, err := (&Tuner{}).Hyperband(halvingTestSpace, opts); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+	}
+}
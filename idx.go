@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the IDX element types, the type is the third byte of the magic number
+const (
+	idxUint8   = 0x08
+	idxInt8    = 0x09
+	idxInt16   = 0x0B
+	idxInt32   = 0x0C
+	idxFloat32 = 0x0D
+	idxFloat64 = 0x0E
+)
+
+// idxSizes is the number of bytes per element of each type
+var idxSizes = map[byte]int{idxUint8: 1, idxInt8: 1, idxInt16: 2, idxInt32: 4, idxFloat32: 4, idxFloat64: 8}
+
+// maxIDXSize limits how much data a header can ask for, so that a corrupt header doesn't allocate
+// all memory
+const maxIDXSize = 1 << 30
+
+// IDXArray is a multi dimensional array read from an IDX file, the format used by MNIST. Data
+// holds the big-endian elements in row major order.
+type IDXArray struct {
+	Type byte
+	Dims []int
+	Data []byte
+}
+
+// Len returns the number of elements
+func (a *IDXArray) Len() int {
+	return len(a.Data) / idxSizes[a.Type]
+}
+
+// At returns element i as a float64
+func (a *IDXArray) At(i int) float64 {
+	switch a.Type {
+	case idxUint8:
+		return float64(a.Data[i])
+	case idxInt8:
+		return float64(int8(a.Data[i]))
+	case idxInt16:
+		return float64(int16(binary.BigEndian.Uint16(a.Data[i*2:])))
+	case idxInt32:
+		return float64(int32(binary.BigEndian.Uint32(a.Data[i*4:])))
+	case idxFloat32:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(a.Data[i*4:])))
+	default:
+		return math.Float64frombits(binary.BigEndian.Uint64(a.Data[i*8:]))
+	}
+}
+
+// ReadIDX reads an IDX array from r, which may be gzip compressed
+func ReadIDX(r io.Reader) (*IDXArray, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("idx: %s", err)
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("idx: reading magic number: %s", err)
+	}
+	size, ok := idxSizes[magic[2]]
+	if magic[0] != 0 || magic[1] != 0 || !ok {
+		return nil, fmt.Errorf("idx: invalid magic number % x", magic)
+	}
+
+	a := &IDXArray{Type: magic[2], Dims: make([]int, magic[3])}
+	total := size
+	dims := make([]byte, 4*len(a.Dims))
+	if _, err := io.ReadFull(br, dims); err != nil {
+		return nil, fmt.Errorf("idx: reading dimensions: %s", err)
+	}
+	for i := range a.Dims {
+		a.Dims[i] = int(binary.BigEndian.Uint32(dims[i*4:]))
+		if a.Dims[i] > 0 && total > maxIDXSize/a.Dims[i] {
+			return nil, fmt.Errorf("idx: dimensions %v are too large", a.Dims[:i+1])
+		}
+		total *= a.Dims[i]
+	}
+
+	a.Data = make([]byte, total)
+	if n, err := io.ReadFull(br, a.Data); err != nil {
+		return nil, fmt.Errorf("idx: expected %d bytes of data, got %d", total, n)
+	}
+	return a, nil
+}
+
+// ReadIDXFile reads an IDX array from a file, which may be gzip compressed
+func ReadIDXFile(file string) (*IDXArray, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	a, err := ReadIDX(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return a, nil
+}
+
+// mnistClasses are the names of the MNIST digits
+var mnistClasses = []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+
+// fashionMNISTClasses are the names of the Fashion-MNIST labels 0 to 9
+var fashionMNISTClasses = []string{"T-shirt/top", "Trouser", "Pullover", "Dress", "Coat", "Sandal", "Shirt", "Sneaker", "Bag", "Ankle boot"}
+
+func init() {
+	RegisterLoader("mnist", LoaderFunc(func(images string) (Dataset, error) {
+		return loadMNIST(images, mnistClasses)
+	}), "idx3-ubyte", "idx3-ubyte.gz")
+	RegisterLoader("fashion-mnist", LoaderFunc(func(images string) (Dataset, error) {
+		return loadMNIST(images, fashionMNISTClasses)
+	}))
+}
+
+func loadMNIST(images string, classes []string) (Dataset, error) {
+	x, y, err := mnistLoader(images, mnistLabelsFile(images))
+	if err != nil {
+		return nil, err
+	}
+	return &SliceDataset{X: x, Y: y, Classes: classes}, nil
+}
+
+// mnistLabelsFile returns the labels file that goes with an images file by the standard naming,
+// train-images-idx3-ubyte.gz has the labels in train-labels-idx1-ubyte.gz
+func mnistLabelsFile(images string) string {
+	dir, base := filepath.Split(images)
+	base = strings.Replace(base, "images", "labels", 1)
+	base = strings.Replace(base, "idx3", "idx1", 1)
+	return filepath.Join(dir, base)
+}
+
+// MNISTImage represent a singular grayscale image from an MNIST style dataset
+type MNISTImage struct {
+	label  byte
+	width  int
+	height int
+	data   []byte
+	raw    []float64
+}
+
+// Label returns the class of the image
+func (i *MNISTImage) Label() int {
+	return int(i.label)
+}
+
+// Decode will return a image.Image that can be converted to a png or what not
+func (i *MNISTImage) Decode() (image.Image, error) {
+	if len(i.data) != i.width*i.height {
+		return nil, fmt.Errorf("mnist: expected %d bytes of pixels, got %d", i.width*i.height, len(i.data))
+	}
+	img := image.NewGray(image.Rect(0, 0, i.width, i.height))
+	copy(img.Pix, i.data)
+	return img, nil
+}
+
+// readMNIST reads the images and the labels that go with them, the images must be unsigned bytes
+// of 3 dimensions and the labels unsigned bytes in the range 0 to 9
+func readMNIST(imagesFile, labelsFile string) ([]MNISTImage, error) {
+	images, err := ReadIDXFile(imagesFile)
+	if err != nil {
+		return nil, err
+	}
+	if images.Type != idxUint8 || len(images.Dims) != 3 {
+		return nil, fmt.Errorf("%s: expected unsigned byte images of 3 dimensions, got type 0x%02x with dimensions %v", imagesFile, images.Type, images.Dims)
+	}
+	labels, err := ReadIDXFile(labelsFile)
+	if err != nil {
+		return nil, err
+	}
+	if labels.Type != idxUint8 || len(labels.Dims) != 1 {
+		return nil, fmt.Errorf("%s: expected unsigned byte labels of 1 dimension, got type 0x%02x with dimensions %v", labelsFile, labels.Type, labels.Dims)
+	}
+	if images.Dims[0] != labels.Dims[0] {
+		return nil, fmt.Errorf("%s has %d images but %s has %d labels", imagesFile, images.Dims[0], labelsFile, labels.Dims[0])
+	}
+
+	height, width := images.Dims[1], images.Dims[2]
+	size := width * height
+	res := make([]MNISTImage, images.Dims[0])
+	for n := range res {
+		if labels.Data[n] >= 10 {
+			return nil, fmt.Errorf("%s: label %d of image %d is out of range", labelsFile, labels.Data[n], n)
+		}
+		data := images.Data[n*size : (n+1)*size]
+		raw := make([]float64, size)
+		for i, val := range data {
+			raw[i] = float64(val)
+		}
+		res[n] = MNISTImage{label: labels.Data[n], width: width, height: height, data: data, raw: raw}
+	}
+	return res, nil
+}
+
+// mnistLoader returns the pixels and the one-hot labels of an MNIST images and labels file pair
+func mnistLoader(imagesFile, labelsFile string) ([][]float64, [][]float64, error) {
+	images, err := readMNIST(imagesFile, labelsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	x := make([][]float64, len(images))
+	y := make([][]float64, len(images))
+	for i := range images {
+		x[i] = images[i].raw
+		y[i] = make([]float64, 10)
+		y[i][images[i].label] = 1.0
+	}
+	return x, y, nil
+}
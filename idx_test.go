@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// idxFixture encodes data as an IDX file of unsigned bytes
+func idxFixture(dims []int, data []byte) []byte {
+	buf := []byte{0, 0, idxUint8, byte(len(dims))}
+	for _, dim := range dims {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(dim))
+	}
+	return append(buf, data...)
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadIDX(t *testing.T) {
+	raw := idxFixture([]int{2, 3}, []byte{1, 2, 3, 4, 5, 6})
+	for name, data := range map[string][]byte{"plain": raw, "gzip": gzipped(t, raw)} {
+		a, err := ReadIDX(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if len(a.Dims) != 2 || a.Dims[0] != 2 || a.Dims[1] != 3 || a.Len() != 6 || a.At(5) != 6 {
+			t.Errorf("%s: unexpected array %+v", name, a)
+		}
+	}
+
+	if _, err := ReadIDX(bytes.NewReader(raw[:len(raw)-1])); err == nil {
+		t.Errorf("expected an error for truncated data")
+	}
+	if _, err := ReadIDX(bytes.NewReader([]byte{0, 0, 0x42, 1, 0, 0, 0, 1, 0})); err == nil {
+		t.Errorf("expected an error for an unknown type")
+	}
+	if _, err := ReadIDX(bytes.NewReader([]byte{0, 0, idxUint8, 2, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})); err == nil {
+		t.Errorf("expected an error for huge dimensions")
+	}
+}
+
+func TestIDXArrayTypes(t *testing.T) {
+	data := []byte{0, 0, idxInt16, 1, 0, 0, 0, 2, 0xff, 0xfe, 0x01, 0x00}
+	a, err := ReadIDX(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.At(0) != -2 || a.At(1) != 256 {
+		t.Errorf("expected -2 and 256, got %f and %f", a.At(0), a.At(1))
+	}
+
+	data = []byte{0, 0, idxFloat32, 1, 0, 0, 0, 1}
+	data = binary.BigEndian.AppendUint32(data, 0x3fc00000)
+	if a, err = ReadIDX(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if a.At(0) != 1.5 {
+		t.Errorf("expected 1.5, got %f", a.At(0))
+	}
+}
+
+func writeMNISTFixture(t *testing.T, labels []byte) string {
+	dir := t.TempDir()
+	pixels := make([]byte, len(labels)*2*3)
+	for i := range pixels {
+		pixels[i] = byte(i)
+	}
+	images := filepath.Join(dir, "train-images-idx3-ubyte.gz")
+	if err := os.WriteFile(images, gzipped(t, idxFixture([]int{len(labels), 2, 3}, pixels)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "train-labels-idx1-ubyte.gz"), idxFixture([]int{len(labels)}, labels), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return images
+}
+
+func TestMNISTLoader(t *testing.T) {
+	images := writeMNISTFixture(t, []byte{7, 2})
+	x, y, err := mnistLoader(images, mnistLabelsFile(images))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 2 || len(x[0]) != 6 || len(y[0]) != 10 {
+		t.Fatalf("expected 2 examples of 6 pixels and 10 classes, got %d", len(x))
+	}
+	if x[1][0] != 6 || y[0][7] != 1 || y[1][2] != 1 {
+		t.Errorf("unexpected examples %v %v", x, y)
+	}
+
+	set, err := LoadDataset("fashion-mnist", images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 2 || set.ClassNames()[7] != "Sneaker" {
+		t.Errorf("unexpected dataset with %d examples and the classes %v", set.Len(), set.ClassNames())
+	}
+
+	if _, _, err := mnistLoader(images, images); err == nil {
+		t.Errorf("expected an error when the labels file has images")
+	}
+	bad := writeMNISTFixture(t, []byte{1, 10})
+	if _, _, err := mnistLoader(bad, mnistLabelsFile(bad)); err == nil {
+		t.Errorf("expected an error for an out of range label")
+	}
+
+	// the standard file names are picked up without naming the loader, with or without .gz
+	plain := strings.TrimSuffix(images, ".gz")
+	for _, name := range []string{images, plain} {
+		if name == plain {
+			for _, file := range []string{images, mnistLabelsFile(images)} {
+				if err := os.Rename(file, strings.TrimSuffix(file, ".gz")); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		set, err := LoadDataset("", name)
+		if err != nil {
+			t.Fatalf("%s: %s", filepath.Base(name), err)
+		}
+		if set.ClassNames()[7] != "7" {
+			t.Errorf("%s: expected the mnist loader, got the classes %v", filepath.Base(name), set.ClassNames())
+		}
+	}
+}
+
+func TestMNISTImageDecode(t *testing.T) {
+	images := writeMNISTFixture(t, []byte{3})
+	set, err := readMNIST(images, mnistLabelsFile(images))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := set[0].Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 3 || b.Dy() != 2 {
+		t.Fatalf("expected a 3x2 image, got %v", b)
+	}
+	if gray := img.(*image.Gray); gray.GrayAt(2, 1).Y != 5 {
+		t.Errorf("expected the last pixel to be 5, got %d", gray.GrayAt(2, 1).Y)
+	}
+	if set[0].Label() != 3 {
+		t.Errorf("expected label 3, got %d", set[0].Label())
+	}
+}
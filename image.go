@@ -1,71 +1,345 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
+	"fmt"
 	"image"
 	"image/png"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // ImageSet is an collection of CIFAR10Images
 type ImageSet []CIFAR10Image
 
 func (c ImageSet) asFloatSlices() ([][]float64, [][]float64) {
+	return c.asFloatSlicesN(10)
+}
+
+// asFloatSlicesN returns the pixels and the one-hot labels with numClasses classes
+func (c ImageSet) asFloatSlicesN(numClasses int) ([][]float64, [][]float64) {
 	x := make([][]float64, len(c))
 	y := make([][]float64, len(c))
 	for i := 0; i < len(c); i++ {
 		x[i] = make([]float64, len(c[i].raw))
 		copy(x[i], c[i].raw)
-		y[i] = make([]float64, 10)
-		if c[i].label == 0 {
-		}
+		y[i] = make([]float64, numClasses)
 		y[i][c[i].label] = 1.0
 	}
 	return x, y
 }
 
 func cifar10Loader(pattern string) ([][]float64, [][]float64, error) {
-	var set = make(ImageSet, 0)
-	trainingFiles, err := filepath.Glob(pattern)
+	return cifarLoader(pattern, CIFAR10)
+}
+
+// cifarLoader reads every file matching pattern into memory
+func cifarLoader(pattern string, format CIFARFormat) ([][]float64, [][]float64, error) {
+	trainingFiles, err := cifarFiles(pattern, format)
 	if err != nil {
-		log.Printf("error when trying to find training data: %s", err)
-		os.Exit(1)
+		return nil, nil, err
 	}
+	var set = make(ImageSet, 0)
 	for _, dataFile := range trainingFiles {
-		images, err := imagesFromFile(dataFile)
 		log.Printf("importing data from %s\n", dataFile)
+		images, err := readCIFARFile(dataFile, format)
 		if err != nil {
-			if err != io.EOF {
-
-				log.Printf("error during import %s", err)
-			}
+			return nil, nil, err
 		}
 		set = append(set, images...)
 	}
 
-	x, y := set.asFloatSlices()
-
+	x, y := set.asFloatSlicesN(format.NumClasses)
 	return x, y, nil
 }
 
+const (
+	cifarImageSize    = 32
+	cifarPixels       = cifarImageSize * cifarImageSize
+	cifar10RecordSize = 1 + cifarPixels*3
+)
+
+// cifar10Classes are the names of the labels 0 to 9
+var cifar10Classes = []string{"airplane", "automobile", "bird", "cat", "deer", "dog", "frog", "horse", "ship", "truck"}
+
+// CIFARFormat describes the binary batch files of CIFAR-10 and CIFAR-100. Each record is
+// LabelBytes labels followed by the 32x32 red, green and blue channels.
+type CIFARFormat struct {
+	Name string
+	// LabelBytes is the number of label bytes before the pixels of each record
+	LabelBytes int
+	// Label is the label byte that is used as the class
+	Label int
+	// NumClasses is the number of classes, records with a larger label are invalid
+	NumClasses int
+	// MetaFile is the file next to the batches with the name of a class per line
+	MetaFile string
+}
+
+var (
+	// CIFAR10 is the 10 class CIFAR-10 dataset
+	CIFAR10 = CIFARFormat{Name: "cifar10", LabelBytes: 1, Label: 0, NumClasses: 10, MetaFile: "batches.meta.txt"}
+	// CIFAR100Coarse is CIFAR-100 labelled with the 20 superclasses
+	CIFAR100Coarse = CIFARFormat{Name: "cifar100", LabelBytes: 2, Label: 0, NumClasses: 20, MetaFile: "coarse_label_names.txt"}
+	// CIFAR100Fine is CIFAR-100 labelled with the 100 classes
+	CIFAR100Fine = CIFARFormat{Name: "cifar100", LabelBytes: 2, Label: 1, NumClasses: 100, MetaFile: "fine_label_names.txt"}
+)
+
+// RecordSize returns the size in bytes of a single image
+func (f CIFARFormat) RecordSize() int {
+	return f.LabelBytes + cifarPixels*3
+}
+
+// label returns the class of a record
+func (f CIFARFormat) label(record []byte) (byte, error) {
+	label := record[f.Label]
+	if int(label) >= f.NumClasses {
+		return 0, fmt.Errorf("%s: invalid label %d, there are %d classes", f.Name, label, f.NumClasses)
+	}
+	return label, nil
+}
+
+func init() {
+	RegisterLoader("cifar10", LoaderFunc(loadCIFAR10), ".bin")
+	RegisterLoader("cifar100", LoaderFunc(func(pattern string) (Dataset, error) {
+		return OpenCIFARDataset(pattern, CIFAR100Fine)
+	}))
+	RegisterLoader("cifar100-coarse", LoaderFunc(func(pattern string) (Dataset, error) {
+		return OpenCIFARDataset(pattern, CIFAR100Coarse)
+	}))
+}
+
+func loadCIFAR10(pattern string) (Dataset, error) {
+	d, err := OpenCIFAR10Dataset(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// cifarFiles returns the files matching pattern, it's an error if there are none
+func cifarFiles(pattern string, format CIFARFormat) ([]string, error) {
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", format.Name, err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no %s files matches %s", format.Name, pattern)
+	}
+	return names, nil
+}
+
+// ReadCIFARLabelNames reads a label names file like batches.meta.txt, which has a name per line.
+// Blank lines are ignored.
+func ReadCIFARLabelNames(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return names, nil
+}
+
+// cifarClassNames reads the label names from the meta file in dir, the built in names are used if
+// there is no such file
+func cifarClassNames(dir string, format CIFARFormat) ([]string, error) {
+	file := filepath.Join(dir, format.MetaFile)
+	names, err := ReadCIFARLabelNames(file)
+	if os.IsNotExist(err) {
+		if format.Name == CIFAR10.Name {
+			return cifar10Classes, nil
+		}
+		names = make([]string, format.NumClasses)
+		for i := range names {
+			names[i] = fmt.Sprintf("%d", i)
+		}
+		return names, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != format.NumClasses {
+		return nil, fmt.Errorf("%s: expected %d label names, got %d", file, format.NumClasses, len(names))
+	}
+	return names, nil
+}
+
+// CIFARDataset is a Dataset over CIFAR-10 or CIFAR-100 binary batch files. The files are memory
+// mapped and each image is only converted to float64s when it's used, so the whole set never has
+// to be held in memory.
+type CIFARDataset struct {
+	format CIFARFormat
+	files  []*recordFile
+	// starts holds the index of the first example in each file
+	starts  []int
+	len     int
+	classes []string
+}
+
+// OpenCIFAR10Dataset opens all CIFAR-10 files matching pattern, the dataset must be closed when
+// done with
+func OpenCIFAR10Dataset(pattern string) (*CIFARDataset, error) {
+	return OpenCIFARDataset(pattern, CIFAR10)
+}
+
+// OpenCIFARDataset opens all files matching pattern, the class names are read from the format's
+// MetaFile in the directory of the first file if there is one. The dataset must be closed when
+// done with.
+func OpenCIFARDataset(pattern string, format CIFARFormat) (*CIFARDataset, error) {
+	names, err := cifarFiles(pattern, format)
+	if err != nil {
+		return nil, err
+	}
+	d := &CIFARDataset{format: format}
+	if d.classes, err = cifarClassNames(filepath.Dir(names[0]), format); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		f, err := openRecordFile(name, format.RecordSize())
+		if err != nil {
+			d.Close()
+			return nil, err
+		}
+		d.files = append(d.files, f)
+		d.starts = append(d.starts, d.len)
+		d.len += f.Len()
+	}
+	return d, nil
+}
+
+func (d *CIFARDataset) Len() int {
+	return d.len
+}
+
+func (d *CIFARDataset) Dims() (int, int) {
+	return cifarPixels * 3, d.format.NumClasses
+}
+
+// ImageShape returns the channels, height and width of the images
+func (d *CIFARDataset) ImageShape() (int, int, int) {
+	return 3, cifarImageSize, cifarImageSize
+}
+
+func (d *CIFARDataset) ClassNames() []string {
+	return d.classes
+}
+
+func (d *CIFARDataset) FeatureNames() []string {
+	return nil
+}
+
+func (d *CIFARDataset) Example(i int, x, y []float64) error {
+	if i < 0 || i >= d.len {
+		return fmt.Errorf("%s: example %d out of range [0, %d)", d.format.Name, i, d.len)
+	}
+	// the last file that starts at or before i
+	f := sort.Search(len(d.starts), func(j int) bool { return d.starts[j] > i }) - 1
+	rec, err := d.files[f].record(i-d.starts[f], nil)
+	if err != nil {
+		return err
+	}
+	label, err := d.format.label(rec)
+	if err != nil {
+		return fmt.Errorf("example %d: %s", i, err)
+	}
+	for j, val := range rec[d.format.LabelBytes:] {
+		x[j] = float64(val)
+	}
+	for j := range y {
+		y[j] = 0
+	}
+	y[label] = 1
+	return nil
+}
+
+// Close unmaps and closes all files
+func (d *CIFARDataset) Close() error {
+	var firstErr error
+	for _, f := range d.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	d.files = nil
+	return firstErr
+}
+
+// CIFARReader reads the images of a CIFAR binary batch one at a time
+type CIFARReader struct {
+	r      io.Reader
+	format CIFARFormat
+	record []byte
+	n      int
+}
+
+// NewCIFARReader returns a reader of the records in r
+func NewCIFARReader(r io.Reader, format CIFARFormat) *CIFARReader {
+	return &CIFARReader{r: r, format: format, record: make([]byte, format.RecordSize())}
+}
+
+// Next returns the next image, io.EOF if there are no more images and an error if the last record
+// is truncated or has an invalid label
+func (r *CIFARReader) Next() (CIFAR10Image, error) {
+	n, err := io.ReadFull(r.r, r.record)
+	if err == io.EOF {
+		return CIFAR10Image{}, io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return CIFAR10Image{}, fmt.Errorf("%s: record %d is truncated, got %d of %d bytes", r.format.Name, r.n, n, len(r.record))
+	}
+	if err != nil {
+		return CIFAR10Image{}, err
+	}
+	label, err := r.format.label(r.record)
+	if err != nil {
+		return CIFAR10Image{}, fmt.Errorf("record %d: %s", r.n, err)
+	}
+	r.n++
+
+	// Write expects a single label byte before the pixels
+	image := CIFAR10Image{}
+	image.Write(r.record[r.format.LabelBytes-1:])
+	image.label = label
+	return image, nil
+}
+
 func imagesFromFile(filename string) (ImageSet, error) {
-	var images = make(ImageSet, 0)
+	return readCIFARFile(filename, CIFAR10)
+}
+
+// readCIFARFile reads every image of a batch file
+func readCIFARFile(filename string, format CIFARFormat) (ImageSet, error) {
 	f, err := os.Open(filename)
 	if err != nil {
-		return images, err
+		return nil, err
 	}
 	defer f.Close()
 
+	var images = make(ImageSet, 0)
+	r := NewCIFARReader(bufio.NewReader(f), format)
 	for {
-		data := make([]byte, 1+1024*3)
-		if _, err := f.Read(data); err != nil {
-			return images, err
+		image, err := r.Next()
+		if err == io.EOF {
+			return images, nil
+		}
+		if err != nil {
+			return images, fmt.Errorf("%s: %s", filename, err)
 		}
-		image := CIFAR10Image{}
-		io.Copy(&image, bytes.NewReader(data))
 		images = append(images, image)
 	}
 }
@@ -77,27 +351,40 @@ type CIFAR10Image struct {
 	raw   []float64
 }
 
+// Label returns the class of the image
+func (i *CIFAR10Image) Label() int {
+	return int(i.label)
+}
+
 // Decode will return a image.Image that can be converted to a png or what not
 func (i *CIFAR10Image) Decode() (image.Image, error) {
+	if len(i.data) != cifarPixels*3 {
+		return nil, fmt.Errorf("cifar: expected %d bytes of pixels, got %d", cifarPixels*3, len(i.data))
+	}
 
 	img := image.NewRGBA(image.Rectangle{
 		Min: image.Point{X: 0, Y: 0},
-		Max: image.Point{X: 32, Y: 32},
+		Max: image.Point{X: cifarImageSize, Y: cifarImageSize},
 	})
 	// alpha channel
-	for j := 0; j < len(i.data)/3; j++ {
+	for j := 0; j < cifarPixels; j++ {
 		img.Pix[j*4+3] = 255
 	}
 	for channel := 0; channel < 3; channel++ {
-		for j := 0; j < len(i.data)/3; j++ {
-			img.Pix[j*4+channel] = i.data[j+channel*1024]
+		for j := 0; j < cifarPixels; j++ {
+			img.Pix[j*4+channel] = i.data[j+channel*cifarPixels]
 		}
 	}
 	return img, nil
 }
 
+// Write sets the image from a record of a label byte followed by the pixels
 func (i *CIFAR10Image) Write(b []byte) (n int, err error) {
+	if len(b) == 0 {
+		return 0, io.ErrShortWrite
+	}
 	i.label = (b[0])
+	i.data = append([]byte(nil), b[1:]...)
 	i.raw = make([]float64, len(b)-1)
 	for idx, val := range b[1:] {
 		i.raw[idx] = float64(val)
@@ -106,14 +393,17 @@ func (i *CIFAR10Image) Write(b []byte) (n int, err error) {
 }
 
 func toPNG(i CIFAR10Image, location string) error {
-	var out *os.File
-	var err error
-	if out, err = os.Create(location); err != nil {
+	img, err := i.Decode()
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(location)
+	if err != nil {
 		return err
 	}
-	img, _ := i.Decode()
 	if err := png.Encode(out, img); err != nil {
+		out.Close()
 		return err
 	}
-	return nil
+	return out.Close()
 }
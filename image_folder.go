@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// imageExtensions are the file types an ImageFolderDataset reads, others are skipped
+var imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true}
+
+func init() {
+	RegisterLoader("imagefolder", LoaderFunc(func(root string) (Dataset, error) {
+		return OpenImageFolder(root, ImageFolderOptions{})
+	}))
+}
+
+// ImageFolderOptions configures an ImageFolderDataset
+type ImageFolderOptions struct {
+	// Width and Height of the examples when there is no Pipeline, the shorter side of the images
+	// is resized to the larger of the two and then center cropped. Both default to 32, the
+	// CIFAR-10 size.
+	Width, Height int
+	// Pipeline prepares the decoded images, it must have a fixed output size
+	Pipeline *ImagePipeline
+}
+
+// ImageFolderDataset is a Dataset of PNG and JPEG files in a directory per class, the name of each
+// directory under the root is a class. It's an ImageDataset whose images are decoded from the files
+// when they're used, the features are the channels one after the other (CHW), the layout of
+// CIFAR10Image.
+type ImageFolderDataset struct {
+	*ImageDataset
+	folder *imageFolder
+}
+
+// OpenImageFolder finds all images under root, the classes are the sorted sub directories
+func OpenImageFolder(root string, opts ImageFolderOptions) (*ImageFolderDataset, error) {
+	pipeline := opts.Pipeline
+	if pipeline == nil {
+		width, height := opts.Width, opts.Height
+		if width <= 0 {
+			width = cifarImageSize
+		}
+		if height <= 0 {
+			height = cifarImageSize
+		}
+		side := width
+		if height > side {
+			side = height
+		}
+		pipeline = NewImagePipeline(Resize(side, 0, Bilinear), CenterCrop(width, height))
+	}
+	folder := &imageFolder{}
+	images, err := newImageDataset(folder, pipeline, 3, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			folder.classes = append(folder.classes, entry.Name())
+		}
+	}
+	sort.Strings(folder.classes)
+	if len(folder.classes) == 0 {
+		return nil, fmt.Errorf("%s: no class directories", root)
+	}
+
+	for label, class := range folder.classes {
+		err := filepath.WalkDir(filepath.Join(root, class), func(path string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(path))] {
+				folder.files = append(folder.files, path)
+				folder.labels = append(folder.labels, label)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(folder.files) == 0 {
+		return nil, fmt.Errorf("%s: no PNG or JPEG images", root)
+	}
+	return &ImageFolderDataset{ImageDataset: images, folder: folder}, nil
+}
+
+// Labels returns the class index of every example without decoding the images
+func (d *ImageFolderDataset) Labels() []int {
+	return append([]int(nil), d.folder.labels...)
+}
+
+// File returns the path of example i
+func (d *ImageFolderDataset) File(i int) string {
+	return d.folder.files[i]
+}
+
+// imageFolder decodes the images of an ImageFolderDataset
+type imageFolder struct {
+	files   []string
+	labels  []int
+	classes []string
+}
+
+func (f *imageFolder) Len() int {
+	return len(f.files)
+}
+
+func (f *imageFolder) ClassNames() []string {
+	return f.classes
+}
+
+func (f *imageFolder) numClasses() int {
+	return len(f.classes)
+}
+
+func (f *imageFolder) image(i int, y []float64) (*ImageTensor, error) {
+	if i < 0 || i >= len(f.files) {
+		return nil, fmt.Errorf("imagefolder: example %d out of range [0, %d)", i, len(f.files))
+	}
+	img, err := decodeImageFile(f.files[i])
+	if err != nil {
+		return nil, err
+	}
+	if y != nil {
+		for j := range y {
+			y[j] = 0
+		}
+		y[f.labels[i]] = 1
+	}
+	return ImageTensorFrom(img), nil
+}
+
+func decodeImageFile(file string) (image.Image, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return img, nil
+}
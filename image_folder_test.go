@@ -0,0 +1,121 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestImage writes a width x height image of a single colour, the format is picked by the
+// extension of name
+func writeTestImage(t *testing.T, name string, width, height int, c color.RGBA) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(name), ".png") {
+		err = png.Encode(f, img)
+	} else {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 100})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageFolderDataset(t *testing.T) {
+	root := t.TempDir()
+	writeTestImage(t, filepath.Join(root, "red", "a.png"), 40, 20, color.RGBA{255, 0, 0, 255})
+	writeTestImage(t, filepath.Join(root, "red", "nested", "b.PNG"), 8, 8, color.RGBA{255, 0, 0, 255})
+	writeTestImage(t, filepath.Join(root, "blue", "c.jpg"), 16, 16, color.RGBA{0, 0, 255, 255})
+	if err := os.WriteFile(filepath.Join(root, "red", "notes.txt"), []byte("skipped"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := OpenImageFolder(root, ImageFolderOptions{Width: 4, Height: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if classes := set.ClassNames(); len(classes) != 2 || classes[0] != "blue" || classes[1] != "red" {
+		t.Fatalf("expected the classes blue and red, got %v", classes)
+	}
+	if set.Len() != 3 {
+		t.Fatalf("expected 3 images, got %d", set.Len())
+	}
+	features, classes := set.Dims()
+	if features != 24 || classes != 2 {
+		t.Fatalf("expected 24 features and 2 classes, got %d and %d", features, classes)
+	}
+
+	x := make([]float64, features)
+	y := make([]float64, classes)
+	for i := 0; i < set.Len(); i++ {
+		if err := set.Example(i, x, y); err != nil {
+			t.Fatal(err)
+		}
+		red, blue := y[1] == 1, y[0] == 1
+		// CHW, the first 8 values are the red channel and the last 8 the blue channel
+		if red && (x[0] != 255 || x[7] != 255 || x[16] != 0) {
+			t.Errorf("%s: expected a red image, got %v", set.File(i), x)
+		}
+		if blue && (x[0] > 2 || x[23] < 253) {
+			t.Errorf("%s: expected a blue image, got %v", set.File(i), x)
+		}
+	}
+	if err := set.Example(3, x, y); err == nil {
+		t.Errorf("expected an error for an out of range example")
+	}
+
+	if _, err := OpenImageFolder(t.TempDir(), ImageFolderOptions{}); err == nil {
+		t.Errorf("expected an error for a directory without classes")
+	}
+}
+
+func TestImageFolderPipeline(t *testing.T) {
+	root := t.TempDir()
+	writeTestImage(t, filepath.Join(root, "a", "1.png"), 8, 8, color.RGBA{100, 100, 100, 255})
+	writeTestImage(t, filepath.Join(root, "b", "2.png"), 8, 8, color.RGBA{200, 200, 200, 255})
+
+	pipeline := NewImagePipeline(Resize(4, 4, NearestNeighbour), RandomCrop(2, 2), Grayscale(), Normalise(nil, nil))
+	set, err := OpenImageFolder(root, ImageFolderOptions{Pipeline: pipeline})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if features, _ := set.Dims(); features != 4 {
+		t.Fatalf("expected a 2x2 grayscale image, got %d features", features)
+	}
+	if err := set.FitPipeline([]int{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	step := set.ImagePipeline().Steps[3]
+	if math.Abs(step.Mean[0]-150) > 1e-9 || math.Abs(step.Std[0]-50) > 1e-9 {
+		t.Errorf("expected a mean of 150 and std of 50, got %v and %v", step.Mean, step.Std)
+	}
+
+	x := make([]float64, 4)
+	y := make([]float64, 2)
+	if err := set.Randomise(1).Example(1, x, y); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(x[0]-1) > 1e-9 || y[1] != 1 {
+		t.Errorf("expected the normalised value 1, got %v", x)
+	}
+
+	if _, err := OpenImageFolder(root, ImageFolderOptions{Pipeline: NewImagePipeline(Resize(4, 0, Bilinear))}); err == nil {
+		t.Errorf("expected an error for a pipeline without a fixed size")
+	}
+}
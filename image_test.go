@@ -2,7 +2,10 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -64,3 +67,200 @@ func TestAsFloat64Slice(t *testing.T) {
 	}
 
 }
+
+func TestCIFAR10Dataset(t *testing.T) {
+	dir := t.TempDir()
+	for f := 0; f < 2; f++ {
+		var data []byte
+		for i := 0; i < 3; i++ {
+			record := make([]byte, cifar10RecordSize)
+			record[0] = byte(f*3 + i)
+			record[1] = byte(100 + f*3 + i)
+			data = append(data, record...)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("data_batch_%d.bin", f)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set, err := OpenCIFAR10Dataset(filepath.Join(dir, "data_batch_*.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer set.Close()
+
+	if set.Len() != 6 {
+		t.Fatalf("expected 6 examples, got %d", set.Len())
+	}
+	x := make([]float64, 3072)
+	y := make([]float64, 10)
+	for i := 0; i < set.Len(); i++ {
+		if err := set.Example(i, x, y); err != nil {
+			t.Fatal(err)
+		}
+		if y[i] != 1 {
+			t.Errorf("expected example %d to have label %d, got %v", i, i, y)
+		}
+		if x[0] != float64(100+i) {
+			t.Errorf("expected the first pixel of example %d to be %d, got %f", i, 100+i, x[0])
+		}
+	}
+	if err := set.Example(6, x, y); err == nil {
+		t.Errorf("expected an error for an out of range example")
+	}
+}
+
+func TestCIFAR10DatasetInvalidSize(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "short.bin")
+	if err := os.WriteFile(file, make([]byte, cifar10RecordSize+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCIFAR10Dataset(file); err == nil {
+		t.Errorf("expected an error when the file isn't a whole number of records")
+	}
+}
+
+// cifarFixture returns records of the format with the given labels, the first pixel of each
+// record is its index
+func cifarFixture(format CIFARFormat, labels ...[]byte) []byte {
+	var data []byte
+	for i, label := range labels {
+		record := make([]byte, format.RecordSize())
+		copy(record, label)
+		record[format.LabelBytes] = byte(i)
+		data = append(data, record...)
+	}
+	return data
+}
+
+func TestCIFARReader(t *testing.T) {
+	data := cifarFixture(CIFAR10, []byte{3}, []byte{9})
+	r := NewCIFARReader(bytes.NewReader(data), CIFAR10)
+	for i, expected := range []int{3, 9} {
+		image, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if image.Label() != expected || image.raw[0] != float64(i) {
+			t.Errorf("expected record %d to have label %d, got %d", i, expected, image.Label())
+		}
+		if _, err := image.Decode(); err != nil {
+			t.Errorf("expected record %d to decode, got %s", i, err)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF at the end, got %v", err)
+	}
+
+	r = NewCIFARReader(bytes.NewReader(data[:len(data)-1]), CIFAR10)
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected an error for a truncated record, got %v", err)
+	}
+
+	r = NewCIFARReader(bytes.NewReader(cifarFixture(CIFAR10, []byte{10})), CIFAR10)
+	if _, err := r.Next(); err == nil {
+		t.Errorf("expected an error for an out of range label")
+	}
+}
+
+func TestCIFAR100Labels(t *testing.T) {
+	data := cifarFixture(CIFAR100Fine, []byte{19, 99}, []byte{0, 42})
+	for _, test := range []struct {
+		format   CIFARFormat
+		expected []int
+	}{
+		{CIFAR100Fine, []int{99, 42}},
+		{CIFAR100Coarse, []int{19, 0}},
+	} {
+		r := NewCIFARReader(bytes.NewReader(data), test.format)
+		for i, expected := range test.expected {
+			image, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if image.Label() != expected || image.raw[0] != float64(i) || len(image.raw) != 3072 {
+				t.Errorf("%d classes: expected record %d to have label %d, got %d", test.format.NumClasses, i, expected, image.Label())
+			}
+		}
+	}
+
+	r := NewCIFARReader(bytes.NewReader(cifarFixture(CIFAR100Coarse, []byte{20, 0})), CIFAR100Coarse)
+	if _, err := r.Next(); err == nil {
+		t.Errorf("expected an error for an out of range coarse label")
+	}
+}
+
+func TestCIFAR10Loader(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data_batch_1.bin")
+	if err := os.WriteFile(file, cifarFixture(CIFAR10, []byte{1}, []byte{2}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	x, y, err := cifar10Loader(filepath.Join(dir, "*.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 2 || y[0][1] != 1 || y[1][2] != 1 {
+		t.Errorf("unexpected examples %v", y)
+	}
+
+	if _, _, err := cifar10Loader(filepath.Join(dir, "*.missing")); err == nil {
+		t.Errorf("expected an error when no files match")
+	}
+	if _, _, err := cifar10Loader("[invalid"); err == nil {
+		t.Errorf("expected an error for an invalid pattern")
+	}
+	if err := os.WriteFile(file, cifarFixture(CIFAR10, []byte{11}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := cifar10Loader(file); err == nil {
+		t.Errorf("expected an error for an invalid label")
+	}
+}
+
+func TestCIFARDatasetClassNames(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "train.bin")
+	if err := os.WriteFile(file, cifarFixture(CIFAR100Coarse, []byte{4, 0}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := OpenCIFARDataset(file, CIFAR100Coarse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := set.ClassNames(); len(names) != 20 || names[4] != "4" {
+		t.Errorf("expected numbered class names without a meta file, got %v", names)
+	}
+	x := make([]float64, 3072)
+	y := make([]float64, 20)
+	if err := set.Example(0, x, y); err != nil || y[4] != 1 {
+		t.Errorf("expected example 0 to have label 4, got %v (%v)", y, err)
+	}
+	set.Close()
+
+	var meta string
+	for i := 0; i < 20; i++ {
+		meta += fmt.Sprintf("superclass_%d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "coarse_label_names.txt"), []byte(meta+"\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if set, err = OpenCIFARDataset(file, CIFAR100Coarse); err != nil {
+		t.Fatal(err)
+	}
+	if names := set.ClassNames(); len(names) != 20 || names[19] != "superclass_19" {
+		t.Errorf("expected the names from the meta file, got %v", names)
+	}
+	set.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "coarse_label_names.txt"), []byte("a\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenCIFARDataset(file, CIFAR100Coarse); err == nil {
+		t.Errorf("expected an error when the meta file has the wrong number of names")
+	}
+}
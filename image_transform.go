@@ -0,0 +1,579 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// ImageTensor is an image as float64s in the CHW layout, all of the first channel followed by the
+// next, which is the layout of CIFAR10Image.raw
+type ImageTensor struct {
+	Channels, Height, Width int
+	Data                    []float64
+}
+
+// NewImageTensor wraps data, which must have channels*height*width values
+func NewImageTensor(channels, height, width int, data []float64) (*ImageTensor, error) {
+	if len(data) != channels*height*width {
+		return nil, fmt.Errorf("image: %d values can't be a %dx%dx%d image", len(data), channels, height, width)
+	}
+	return &ImageTensor{Channels: channels, Height: height, Width: width, Data: data}, nil
+}
+
+// ImageTensorFrom returns the red, green and blue channels of img as 0-255 floats
+func ImageTensorFrom(img image.Image) *ImageTensor {
+	rgba := toRGBA(img)
+	width, height := rgba.Rect.Dx(), rgba.Rect.Dy()
+	size := width * height
+	t := &ImageTensor{Channels: 3, Height: height, Width: width, Data: make([]float64, 3*size)}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			offset := rgba.PixOffset(x, y)
+			j := y*width + x
+			for c := 0; c < 3; c++ {
+				t.Data[j+c*size] = float64(rgba.Pix[offset+c])
+			}
+		}
+	}
+	return t
+}
+
+// toRGBA returns img as an *image.RGBA with its origin at 0, 0
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// at returns the value of channel c at x, y with the coordinates clamped to the image
+func (t *ImageTensor) at(c, x, y int) float64 {
+	if x < 0 {
+		x = 0
+	} else if x >= t.Width {
+		x = t.Width - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= t.Height {
+		y = t.Height - 1
+	}
+	return t.Data[(c*t.Height+y)*t.Width+x]
+}
+
+// Interpolation is how Resize calculates values between the pixels
+type Interpolation int
+
+const (
+	// Bilinear interpolates between the 4 nearest pixels
+	Bilinear Interpolation = iota
+	// NearestNeighbour uses the nearest pixel
+	NearestNeighbour
+	// Bicubic interpolates between the 16 nearest pixels with the Catmull-Rom spline, which is
+	// sharper than Bilinear
+	Bicubic
+)
+
+var interpolationNames = []string{"bilinear", "nearest", "bicubic"}
+
+func (i Interpolation) String() string {
+	if i < 0 || int(i) >= len(interpolationNames) {
+		return fmt.Sprintf("Interpolation(%d)", int(i))
+	}
+	return interpolationNames[i]
+}
+
+// MarshalText implements encoding.TextMarshaler so the interpolation is readable in saved models
+func (i Interpolation) MarshalText() ([]byte, error) {
+	if i < 0 || int(i) >= len(interpolationNames) {
+		return nil, fmt.Errorf("unknown interpolation %d", int(i))
+	}
+	return []byte(i.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (i *Interpolation) UnmarshalText(text []byte) error {
+	for j, name := range interpolationNames {
+		if name == string(text) {
+			*i = Interpolation(j)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown interpolation %q", text)
+}
+
+// sample returns channel c interpolated at x, y
+func (i Interpolation) sample(t *ImageTensor, c int, x, y float64) float64 {
+	switch i {
+	case NearestNeighbour:
+		return t.at(c, int(math.Floor(x+0.5)), int(math.Floor(y+0.5)))
+	case Bicubic:
+		x0, y0 := math.Floor(x), math.Floor(y)
+		var sum float64
+		for m := -1; m <= 2; m++ {
+			wy := catmullRom(y - y0 - float64(m))
+			for n := -1; n <= 2; n++ {
+				sum += wy * catmullRom(x-x0-float64(n)) * t.at(c, int(x0)+n, int(y0)+m)
+			}
+		}
+		return sum
+	default:
+		x0, y0 := math.Floor(x), math.Floor(y)
+		fx, fy := x-x0, y-y0
+		ix, iy := int(x0), int(y0)
+		top := t.at(c, ix, iy)*(1-fx) + t.at(c, ix+1, iy)*fx
+		bottom := t.at(c, ix, iy+1)*(1-fx) + t.at(c, ix+1, iy+1)*fx
+		return top*(1-fy) + bottom*fy
+	}
+}
+
+func catmullRom(x float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x < 1:
+		return 1.5*x*x*x - 2.5*x*x + 1
+	case x < 2:
+		return -0.5*x*x*x + 2.5*x*x - 4*x + 2
+	default:
+		return 0
+	}
+}
+
+// ImageOp is the operation of an ImageStep
+type ImageOp int
+
+const (
+	// ImageResize resizes to Width x Height, if Height is 0 the shorter side is resized to Width
+	// and the aspect ratio is kept
+	ImageResize ImageOp = iota
+	// ImageCenterCrop cuts Width x Height out of the center
+	ImageCenterCrop
+	// ImageRandomCrop cuts Width x Height out of a random position when training and out of the
+	// center otherwise
+	ImageRandomCrop
+	// ImageGrayscale turns red, green and blue into a single luminance channel
+	ImageGrayscale
+	// ImageScale scales 0-255 values to [0, 1]
+	ImageScale
+	// ImageNormalise subtracts Mean and divides by Std per channel, they are learnt by Fit if empty
+	ImageNormalise
+)
+
+var imageOpNames = []string{"resize", "center_crop", "random_crop", "grayscale", "scale", "normalise"}
+
+func (op ImageOp) String() string {
+	if op < 0 || int(op) >= len(imageOpNames) {
+		return fmt.Sprintf("ImageOp(%d)", int(op))
+	}
+	return imageOpNames[op]
+}
+
+// MarshalText implements encoding.TextMarshaler so the operation is readable in saved models
+func (op ImageOp) MarshalText() ([]byte, error) {
+	if op < 0 || int(op) >= len(imageOpNames) {
+		return nil, fmt.Errorf("unknown image operation %d", int(op))
+	}
+	return []byte(op.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (op *ImageOp) UnmarshalText(text []byte) error {
+	for i, name := range imageOpNames {
+		if name == string(text) {
+			*op = ImageOp(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown image operation %q", text)
+}
+
+// ImageStep is a single step of an ImagePipeline, which of the fields are used depends on Op
+type ImageStep struct {
+	Op            ImageOp
+	Width         int           `json:",omitempty"`
+	Height        int           `json:",omitempty"`
+	Interpolation Interpolation `json:",omitempty"`
+	Mean          []float64     `json:",omitempty"`
+	Std           []float64     `json:",omitempty"`
+}
+
+// Resize returns a step that resizes to width x height, or the shorter side to width if height is 0
+func Resize(width, height int, interpolation Interpolation) ImageStep {
+	return ImageStep{Op: ImageResize, Width: width, Height: height, Interpolation: interpolation}
+}
+
+// CenterCrop returns a step that cuts width x height out of the center
+func CenterCrop(width, height int) ImageStep {
+	return ImageStep{Op: ImageCenterCrop, Width: width, Height: height}
+}
+
+// RandomCrop returns a step that cuts width x height out of a random position when training
+func RandomCrop(width, height int) ImageStep {
+	return ImageStep{Op: ImageRandomCrop, Width: width, Height: height}
+}
+
+// Grayscale returns a step that converts to a single channel
+func Grayscale() ImageStep {
+	return ImageStep{Op: ImageGrayscale}
+}
+
+// ScaleToUnit returns a step that scales 0-255 values to [0, 1]
+func ScaleToUnit() ImageStep {
+	return ImageStep{Op: ImageScale}
+}
+
+// Normalise returns a step that standardises each channel, if mean and std are nil they are
+// learnt by ImagePipeline.Fit
+func Normalise(mean, std []float64) ImageStep {
+	return ImageStep{Op: ImageNormalise, Mean: mean, Std: std}
+}
+
+// apply runs the step on t, rng picks the random crops and is nil when not training
+func (s ImageStep) apply(t *ImageTensor, rng *rand.Rand) (*ImageTensor, error) {
+	switch s.Op {
+	case ImageResize:
+		width, height := s.size(t.Height, t.Width)
+		if width <= 0 || height <= 0 {
+			return nil, fmt.Errorf("image: can't resize to %dx%d", width, height)
+		}
+		return s.Interpolation.resize(t, width, height), nil
+	case ImageCenterCrop, ImageRandomCrop:
+		if s.Width > t.Width || s.Height > t.Height || s.Width <= 0 || s.Height <= 0 {
+			return nil, fmt.Errorf("image: can't crop %dx%d out of %dx%d", s.Width, s.Height, t.Width, t.Height)
+		}
+		x0, y0 := (t.Width-s.Width)/2, (t.Height-s.Height)/2
+		if s.Op == ImageRandomCrop && rng != nil {
+			x0, y0 = rng.Intn(t.Width-s.Width+1), rng.Intn(t.Height-s.Height+1)
+		}
+		return crop(t, x0, y0, s.Width, s.Height), nil
+	case ImageGrayscale:
+		return grayscale(t)
+	case ImageScale:
+		res := &ImageTensor{Channels: t.Channels, Height: t.Height, Width: t.Width, Data: make([]float64, len(t.Data))}
+		for i, val := range t.Data {
+			res.Data[i] = val / 255
+		}
+		return res, nil
+	case ImageNormalise:
+		if len(s.Mean) != t.Channels || len(s.Std) != t.Channels {
+			return nil, fmt.Errorf("image: normalise has %d means and %d stds for %d channels, it may not have been fitted", len(s.Mean), len(s.Std), t.Channels)
+		}
+		res := &ImageTensor{Channels: t.Channels, Height: t.Height, Width: t.Width, Data: make([]float64, len(t.Data))}
+		size := t.Height * t.Width
+		for c := 0; c < t.Channels; c++ {
+			std := s.Std[c]
+			if std == 0 {
+				std = 1
+			}
+			for i := c * size; i < (c+1)*size; i++ {
+				res.Data[i] = (t.Data[i] - s.Mean[c]) / std
+			}
+		}
+		return res, nil
+	default:
+		return nil, fmt.Errorf("image: unknown operation %s", s.Op)
+	}
+}
+
+// size returns the output size of a resize of a height x width image
+func (s ImageStep) size(height, width int) (int, int) {
+	if s.Height > 0 {
+		return s.Width, s.Height
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	if width < height {
+		return s.Width, int(math.Round(float64(height) * float64(s.Width) / float64(width)))
+	}
+	return int(math.Round(float64(width) * float64(s.Width) / float64(height))), s.Width
+}
+
+func (i Interpolation) resize(t *ImageTensor, width, height int) *ImageTensor {
+	res := &ImageTensor{Channels: t.Channels, Height: height, Width: width, Data: make([]float64, t.Channels*height*width)}
+	scaleX := float64(t.Width) / float64(width)
+	scaleY := float64(t.Height) / float64(height)
+	j := 0
+	for c := 0; c < t.Channels; c++ {
+		for y := 0; y < height; y++ {
+			sy := (float64(y)+0.5)*scaleY - 0.5
+			for x := 0; x < width; x++ {
+				res.Data[j] = i.sample(t, c, (float64(x)+0.5)*scaleX-0.5, sy)
+				j++
+			}
+		}
+	}
+	return res
+}
+
+func crop(t *ImageTensor, x0, y0, width, height int) *ImageTensor {
+	res := &ImageTensor{Channels: t.Channels, Height: height, Width: width, Data: make([]float64, 0, t.Channels*height*width)}
+	for c := 0; c < t.Channels; c++ {
+		for y := y0; y < y0+height; y++ {
+			row := (c*t.Height + y) * t.Width
+			res.Data = append(res.Data, t.Data[row+x0:row+x0+width]...)
+		}
+	}
+	return res
+}
+
+// grayscale uses the ITU-R BT.601 luma weights, single channel images are returned as they are
+func grayscale(t *ImageTensor) (*ImageTensor, error) {
+	if t.Channels == 1 {
+		return t, nil
+	}
+	if t.Channels != 3 {
+		return nil, fmt.Errorf("image: can't convert %d channels to grayscale", t.Channels)
+	}
+	size := t.Height * t.Width
+	res := &ImageTensor{Channels: 1, Height: t.Height, Width: t.Width, Data: make([]float64, size)}
+	for i := range res.Data {
+		res.Data[i] = 0.299*t.Data[i] + 0.587*t.Data[i+size] + 0.114*t.Data[i+2*size]
+	}
+	return res, nil
+}
+
+// ImagePipeline is a list of steps that turns an image into the features of the net. It's saved
+// with the net so that images are prepared the same way for predictions as for training.
+type ImagePipeline struct {
+	Steps []ImageStep
+}
+
+// NewImagePipeline returns a pipeline that runs the steps in order
+func NewImagePipeline(steps ...ImageStep) *ImagePipeline {
+	return &ImagePipeline{Steps: steps}
+}
+
+// Apply runs all steps on t, random crops are only random if rng isn't nil
+func (p *ImagePipeline) Apply(t *ImageTensor, rng *rand.Rand) (*ImageTensor, error) {
+	return p.apply(p.Steps, t, rng)
+}
+
+func (p *ImagePipeline) apply(steps []ImageStep, t *ImageTensor, rng *rand.Rand) (*ImageTensor, error) {
+	var err error
+	for _, step := range steps {
+		if t, err = step.apply(t, rng); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Transform prepares a decoded image for a prediction
+func (p *ImagePipeline) Transform(img image.Image) (*ImageTensor, error) {
+	return p.Apply(ImageTensorFrom(img), nil)
+}
+
+// Shape returns the shape of the output for an input of the given shape, a height or width of 0
+// is unknown and so is the output size if it depends on it
+func (p *ImagePipeline) Shape(channels, height, width int) (int, int, int) {
+	for _, step := range p.Steps {
+		switch step.Op {
+		case ImageResize:
+			width, height = step.size(height, width)
+		case ImageCenterCrop, ImageRandomCrop:
+			width, height = step.Width, step.Height
+		case ImageGrayscale:
+			channels = 1
+		}
+	}
+	return channels, height, width
+}
+
+// Fit learns the mean and std of each channel for the normalise steps without them, from the
+// output of the steps before. image returns input i of the n inputs to the pipeline.
+func (p *ImagePipeline) Fit(n int, image func(i int) (*ImageTensor, error)) error {
+	for k, step := range p.Steps {
+		if step.Op != ImageNormalise || len(step.Mean) > 0 {
+			continue
+		}
+		var stats *RunningStats
+		for i := 0; i < n; i++ {
+			t, err := image(i)
+			if err != nil {
+				return err
+			}
+			if t, err = p.apply(p.Steps[:k], t, nil); err != nil {
+				return err
+			}
+			if stats == nil {
+				stats = NewRunningStats(t.Channels)
+			}
+			if err := stats.Merge(channelStats(t)); err != nil {
+				return fmt.Errorf("image: input %d: %s", i, err)
+			}
+		}
+		if stats == nil || stats.Count == 0 {
+			return fmt.Errorf("image: no inputs to fit the pipeline on")
+		}
+		step.Mean = append([]float64(nil), stats.Means...)
+		step.Std = make([]float64, len(stats.Means))
+		for c := range step.Std {
+			step.Std[c] = math.Sqrt(stats.m2[c] / float64(stats.Count))
+		}
+		p.Steps[k] = step
+	}
+	return nil
+}
+
+// channelStats returns the mean and variance of the pixels in every channel of t
+func channelStats(t *ImageTensor) *RunningStats {
+	size := t.Height * t.Width
+	stats := NewRunningStats(t.Channels)
+	stats.Count = size
+	for c := range stats.Means {
+		pixels := t.Data[c*size : (c+1)*size]
+		for _, val := range pixels {
+			stats.Means[c] += val
+		}
+		stats.Means[c] /= float64(size)
+		for _, val := range pixels {
+			delta := val - stats.Means[c]
+			stats.m2[c] += delta * delta
+		}
+	}
+	return stats
+}
+
+// lockedSource makes a rand.Source safe to use from several goroutines
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func newLockedRand(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed)})
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// ImageDataset runs an ImagePipeline on the images of a set, either the examples of a Dataset of
+// images of a fixed shape, like CIFAR-10 or MNIST, or image files, see OpenImageFolder
+type ImageDataset struct {
+	images   imageSource
+	pipeline *ImagePipeline
+	// the shape of the images after the pipeline
+	channels, height, width int
+	rng                     *rand.Rand
+}
+
+// imageSource reads the images of an ImageDataset before the pipeline
+type imageSource interface {
+	Len() int
+	ClassNames() []string
+	numClasses() int
+	// image returns image i and copies its one-hot label into y, unless y is nil
+	image(i int, y []float64) (*ImageTensor, error)
+}
+
+// NewImageDataset wraps set, whose examples must be channels x height x width images in the CHW
+// layout. The pipeline must have a fixed output size.
+func NewImageDataset(set Dataset, channels, height, width int, pipeline *ImagePipeline) (*ImageDataset, error) {
+	if features, _ := set.Dims(); features != channels*height*width {
+		return nil, fmt.Errorf("image: the dataset has %d features, which isn't a %dx%dx%d image", features, channels, height, width)
+	}
+	return newImageDataset(&tensorImages{Dataset: set, channels: channels, height: height, width: width}, pipeline, channels, height, width)
+}
+
+// newImageDataset runs the images of the given shape through the pipeline, a height or width of 0
+// is unknown
+func newImageDataset(images imageSource, pipeline *ImagePipeline, channels, height, width int) (*ImageDataset, error) {
+	d := &ImageDataset{images: images, pipeline: pipeline}
+	d.channels, d.height, d.width = pipeline.Shape(channels, height, width)
+	if d.channels*d.height*d.width == 0 {
+		return nil, fmt.Errorf("image: the pipeline doesn't have a fixed output size")
+	}
+	return d, nil
+}
+
+// Randomise returns a view of the dataset where random crops are random, which should only be used
+// for the training examples
+func (d *ImageDataset) Randomise(seed int64) *ImageDataset {
+	view := *d
+	view.rng = newLockedRand(seed)
+	return &view
+}
+
+// ImagePipeline returns the pipeline, to be saved with the net
+func (d *ImageDataset) ImagePipeline() *ImagePipeline {
+	return d.pipeline
+}
+
+func (d *ImageDataset) Len() int {
+	return d.images.Len()
+}
+
+func (d *ImageDataset) Dims() (int, int) {
+	return d.channels * d.height * d.width, d.images.numClasses()
+}
+
+// ImageShape returns the channels, height and width of the images after the pipeline
+func (d *ImageDataset) ImageShape() (int, int, int) {
+	return d.channels, d.height, d.width
+}
+
+func (d *ImageDataset) ClassNames() []string {
+	return d.images.ClassNames()
+}
+
+func (d *ImageDataset) FeatureNames() []string {
+	return nil
+}
+
+func (d *ImageDataset) Example(i int, x, y []float64) error {
+	t, err := d.images.image(i, y)
+	if err != nil {
+		return err
+	}
+	if t, err = d.pipeline.Apply(t, d.rng); err != nil {
+		return fmt.Errorf("example %d: %s", i, err)
+	}
+	copy(x, t.Data)
+	return nil
+}
+
+// FitPipeline fits the normalise steps of the pipeline on the examples at idx
+func (d *ImageDataset) FitPipeline(idx []int) error {
+	return d.pipeline.Fit(len(idx), func(i int) (*ImageTensor, error) {
+		return d.images.image(idx[i], nil)
+	})
+}
+
+// tensorImages reads the images from the examples of a Dataset of images of a fixed shape
+type tensorImages struct {
+	Dataset
+	channels, height, width int
+}
+
+func (s *tensorImages) numClasses() int {
+	_, classes := s.Dims()
+	return classes
+}
+
+func (s *tensorImages) image(i int, y []float64) (*ImageTensor, error) {
+	raw := make([]float64, s.channels*s.height*s.width)
+	if y == nil {
+		y = make([]float64, s.numClasses())
+	}
+	if err := s.Example(i, raw, y); err != nil {
+		return nil, err
+	}
+	return NewImageTensor(s.channels, s.height, s.width, raw)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// testTensor returns a single channel tensor where each value is its index
+func testTensor(height, width int) *ImageTensor {
+	data := make([]float64, height*width)
+	for i := range data {
+		data[i] = float64(i)
+	}
+	t, _ := NewImageTensor(1, height, width, data)
+	return t
+}
+
+func TestImageTensorFrom(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{1, 2, 3, 255})
+	img.Set(1, 0, color.RGBA{4, 5, 6, 255})
+	tensor := ImageTensorFrom(img)
+	expected := []float64{1, 4, 2, 5, 3, 6}
+	for i := range expected {
+		if tensor.Data[i] != expected[i] {
+			t.Fatalf("expected the CHW layout %v, got %v", expected, tensor.Data)
+		}
+	}
+	if _, err := NewImageTensor(3, 2, 2, make([]float64, 11)); err == nil {
+		t.Errorf("expected an error for the wrong number of values")
+	}
+}
+
+func TestResizeInterpolations(t *testing.T) {
+	src := testTensor(2, 2)
+	for _, interpolation := range []Interpolation{NearestNeighbour, Bilinear, Bicubic} {
+		res, err := Resize(4, 4, interpolation).apply(src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Width != 4 || res.Height != 4 {
+			t.Fatalf("%s: expected a 4x4 image, got %dx%d", interpolation, res.Width, res.Height)
+		}
+		// the corners keep the values of the corners, bicubic overshoots a little
+		if math.Abs(res.Data[0]) > 0.25 || math.Abs(res.Data[15]-3) > 0.25 {
+			t.Errorf("%s: expected the corners 0 and 3, got %v", interpolation, res.Data)
+		}
+		between := res.Data[1]
+		if interpolation == NearestNeighbour && between != 0 {
+			t.Errorf("%s: expected no interpolation, got %f", interpolation, between)
+		}
+		if interpolation != NearestNeighbour && (between <= 0 || between >= 1) {
+			t.Errorf("%s: expected an interpolated value, got %f", interpolation, between)
+		}
+	}
+
+	// the shorter side is resized and the aspect ratio kept
+	res, err := Resize(2, 0, Bilinear).apply(testTensor(4, 8), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Width != 4 || res.Height != 2 {
+		t.Errorf("expected a 4x2 image, got %dx%d", res.Width, res.Height)
+	}
+}
+
+func TestCrops(t *testing.T) {
+	src := testTensor(4, 4)
+	res, err := CenterCrop(2, 2).apply(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{5, 6, 9, 10}
+	for i := range expected {
+		if res.Data[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, res.Data)
+		}
+	}
+
+	// without a rand.Rand random crops are center crops
+	if res, _ = RandomCrop(2, 2).apply(src, nil); res.Data[0] != 5 {
+		t.Errorf("expected a center crop, got %v", res.Data)
+	}
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[float64]bool)
+	for i := 0; i < 50; i++ {
+		res, _ = RandomCrop(2, 2).apply(src, rng)
+		seen[res.Data[0]] = true
+	}
+	if len(seen) < 5 {
+		t.Errorf("expected random crops at different positions, got %v", seen)
+	}
+
+	if _, err := CenterCrop(5, 2).apply(src, nil); err == nil {
+		t.Errorf("expected an error for a crop larger than the image")
+	}
+}
+
+func TestGrayscaleScaleNormalise(t *testing.T) {
+	src, _ := NewImageTensor(3, 1, 1, []float64{255, 255, 255})
+	pipeline := NewImagePipeline(Grayscale(), ScaleToUnit(), Normalise([]float64{0.5}, []float64{0.25}))
+	res, err := pipeline.Apply(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Channels != 1 || math.Abs(res.Data[0]-2) > 1e-9 {
+		t.Errorf("expected a single channel with the value 2, got %+v", res)
+	}
+
+	if _, err := NewImagePipeline(Normalise(nil, nil)).Apply(src, nil); err == nil {
+		t.Errorf("expected an error for an unfitted normalise step")
+	}
+}
+
+func TestImagePipelineFit(t *testing.T) {
+	inputs := []*ImageTensor{}
+	for _, vals := range [][]float64{{0, 0, 10, 10}, {4, 4, 30, 30}} {
+		tensor, _ := NewImageTensor(2, 1, 2, vals)
+		inputs = append(inputs, tensor)
+	}
+	pipeline := NewImagePipeline(Normalise(nil, nil))
+	err := pipeline.Fit(len(inputs), func(i int) (*ImageTensor, error) { return inputs[i], nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := pipeline.Steps[0]
+	if step.Mean[0] != 2 || step.Mean[1] != 20 || step.Std[0] != 2 || step.Std[1] != 10 {
+		t.Errorf("expected the means 2, 20 and stds 2, 10, got %v and %v", step.Mean, step.Std)
+	}
+
+	// the squares of large values lose the small differences between them, the running stats don't
+	for _, tensor := range inputs {
+		for i := range tensor.Data {
+			tensor.Data[i] += 1e9
+		}
+	}
+	pipeline = NewImagePipeline(Normalise(nil, nil))
+	if err := pipeline.Fit(len(inputs), func(i int) (*ImageTensor, error) { return inputs[i], nil }); err != nil {
+		t.Fatal(err)
+	}
+	if step := pipeline.Steps[0]; math.Abs(step.Std[0]-2) > 1e-6 || math.Abs(step.Std[1]-10) > 1e-6 {
+		t.Errorf("expected the stds 2 and 10 for large values, got %v", step.Std)
+	}
+}
+
+func TestImagePipelineJSON(t *testing.T) {
+	pipeline := NewImagePipeline(Resize(8, 0, Bicubic), RandomCrop(4, 4), Normalise([]float64{1, 2, 3}, []float64{4, 5, 6}))
+	data, err := json.Marshal(pipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &ImagePipeline{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Steps) != 3 || loaded.Steps[0].Interpolation != Bicubic || loaded.Steps[1].Op != ImageRandomCrop || loaded.Steps[2].Std[2] != 6 {
+		t.Errorf("expected %+v, got %+v", pipeline, loaded)
+	}
+	if c, h, w := loaded.Shape(3, 0, 0); c != 3 || h != 4 || w != 4 {
+		t.Errorf("expected the shape 3x4x4, got %dx%dx%d", c, h, w)
+	}
+}
+
+func TestImageDataset(t *testing.T) {
+	set := &SliceDataset{X: [][]float64{{0, 1, 2, 3}, {4, 5, 6, 7}}, Y: [][]float64{{1, 0}, {0, 1}}}
+	images, err := NewImageDataset(set, 1, 2, 2, NewImagePipeline(CenterCrop(1, 1), Normalise(nil, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := images.FitPipeline([]int{0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if features, classes := images.Dims(); features != 1 || classes != 2 {
+		t.Fatalf("expected 1 feature and 2 classes, got %d and %d", features, classes)
+	}
+	x := make([]float64, 1)
+	y := make([]float64, 2)
+	if err := images.Example(1, x, y); err != nil {
+		t.Fatal(err)
+	}
+	if x[0] != 1 || y[1] != 1 {
+		t.Errorf("expected the normalised value 1, got %v %v", x, y)
+	}
+
+	if _, err := NewImageDataset(set, 3, 2, 2, NewImagePipeline()); err == nil {
+		t.Errorf("expected an error when the shape doesn't match the dataset")
+	}
+}
+
+func TestSaveLoadImagePipeline(t *testing.T) {
+	nn := &NeuralNet{
+		HiddenNeurons: 2,
+		W1:            NewRandomMatrix(2, 5),
+		W2:            NewRandomMatrix(2, 3),
+		ImagePipeline: NewImagePipeline(Resize(2, 2, Bilinear), Grayscale(), ScaleToUnit()),
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 6, 6))
+	expected, err := nn.PredictImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "net.bin")
+	Save(fileName, nn)
+	actual, err := Load(fileName).PredictImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual[0] != expected[0] {
+		t.Errorf("expected the loaded net to predict %d, got %d", expected[0], actual[0])
+	}
+	if _, err := (&NeuralNet{}).PredictImage(img); err == nil {
+		t.Errorf("expected an error when the net has no image pipeline")
+	}
+}
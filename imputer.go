@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ImputeStrategy is how an Imputer picks the value that replaces missing values in a column
+type ImputeStrategy int
+
+const (
+	// ImputeMean uses the mean of the column
+	ImputeMean ImputeStrategy = iota
+	// ImputeMedian uses the median of the column
+	ImputeMedian
+	// ImputeMostFrequent uses the most common value in the column, the smallest one on ties
+	ImputeMostFrequent
+	// ImputeConstant uses the Constant of the Imputer
+	ImputeConstant
+)
+
+var imputeStrategyNames = []string{"mean", "median", "most_frequent", "constant"}
+
+func (s ImputeStrategy) String() string {
+	if s < 0 || int(s) >= len(imputeStrategyNames) {
+		return fmt.Sprintf("ImputeStrategy(%d)", int(s))
+	}
+	return imputeStrategyNames[s]
+}
+
+// MarshalText implements encoding.TextMarshaler so the strategy is readable in saved models
+func (s ImputeStrategy) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(imputeStrategyNames) {
+		return nil, fmt.Errorf("unknown impute strategy %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *ImputeStrategy) UnmarshalText(text []byte) error {
+	for i, name := range imputeStrategyNames {
+		if name == string(text) {
+			*s = ImputeStrategy(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown impute strategy %q", text)
+}
+
+// Imputer replaces missing values, which are NaN, with a value learnt from the training data. It's
+// saved with the net so that new examples are filled in the same way.
+type Imputer struct {
+	Strategy ImputeStrategy
+	// Constant is the fill value for ImputeConstant, and for columns that had no values at all
+	Constant float64
+	// Indicators adds a feature for every column that had missing values during Fit, which is 1
+	// when the value was missing and 0 otherwise
+	Indicators bool
+
+	// Fill is the fitted fill value of each column
+	Fill []float64
+	// Missing are the columns that had missing values during Fit
+	Missing []int
+}
+
+// Fit learns the fill value of each column from the training examples
+func (im *Imputer) Fit(x [][]float64) error {
+	if len(x) == 0 {
+		return fmt.Errorf("imputer: no examples to fit")
+	}
+	numFeatures := len(x[0])
+	im.Fill = make([]float64, numFeatures)
+	im.Missing = im.Missing[:0]
+
+	values := make([]float64, 0, len(x))
+	for col := 0; col < numFeatures; col++ {
+		values = values[:0]
+		for _, row := range x {
+			if len(row) != numFeatures {
+				return fmt.Errorf("imputer: expected %d features, got %d", numFeatures, len(row))
+			}
+			if math.IsNaN(row[col]) {
+				continue
+			}
+			values = append(values, row[col])
+		}
+		if len(values) < len(x) {
+			im.Missing = append(im.Missing, col)
+		}
+		im.Fill[col] = im.fillValue(values)
+	}
+	return nil
+}
+
+func (im *Imputer) fillValue(values []float64) float64 {
+	if len(values) == 0 || im.Strategy == ImputeConstant {
+		return im.Constant
+	}
+	switch im.Strategy {
+	case ImputeMedian:
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	case ImputeMostFrequent:
+		counts := make(map[float64]int)
+		best, bestCount := 0.0, 0
+		for _, val := range values {
+			counts[val]++
+			if c := counts[val]; c > bestCount || c == bestCount && val < best {
+				best, bestCount = val, c
+			}
+		}
+		return best
+	default:
+		var sum float64
+		for _, val := range values {
+			sum += val
+		}
+		return sum / float64(len(values))
+	}
+}
+
+// Width returns the number of features after the transform
+func (im *Imputer) Width() int {
+	if im.Indicators {
+		return len(im.Fill) + len(im.Missing)
+	}
+	return len(im.Fill)
+}
+
+// TransformRow returns a copy of row with the missing values filled in, followed by the indicators
+func (im *Imputer) TransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(im.Fill) {
+		return nil, fmt.Errorf("imputer: expected %d features, got %d", len(im.Fill), len(row))
+	}
+	res := make([]float64, im.Width())
+	for i, val := range row {
+		if math.IsNaN(val) {
+			val = im.Fill[i]
+		}
+		res[i] = val
+	}
+	if im.Indicators {
+		for i, col := range im.Missing {
+			if math.IsNaN(row[col]) {
+				res[len(row)+i] = 1
+			}
+		}
+	}
+	return res, nil
+}
+
+// Transform fills in the missing values of every example
+func (im *Imputer) Transform(x [][]float64) ([][]float64, error) {
+	res := make([][]float64, len(x))
+	for i := range x {
+		var err error
+		if res[i], err = im.TransformRow(x[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// FeatureNames returns the names after the transform given the names of the input features
+func (im *Imputer) FeatureNames(names []string) []string {
+	if !im.Indicators || names == nil {
+		return names
+	}
+	res := append([]string(nil), names...)
+	for _, col := range im.Missing {
+		res = append(res, names[col]+" missing")
+	}
+	return res
+}
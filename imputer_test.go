@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+var nan = math.NaN()
+
+var imputerTestData = [][]float64{
+	{1, 5, nan},
+	{2, nan, nan},
+	{2, 7, nan},
+	{nan, 9, nan},
+}
+
+func TestImputerStrategies(t *testing.T) {
+	tests := []struct {
+		strategy ImputeStrategy
+		expected []float64
+	}{
+		{ImputeMean, []float64{5.0 / 3, 7, -1}},
+		{ImputeMedian, []float64{2, 7, -1}},
+		{ImputeMostFrequent, []float64{2, 5, -1}},
+		{ImputeConstant, []float64{-1, -1, -1}},
+	}
+	for _, test := range tests {
+		im := &Imputer{Strategy: test.strategy, Constant: -1}
+		if err := im.Fit(imputerTestData); err != nil {
+			t.Fatal(err)
+		}
+		for i := range test.expected {
+			if math.Abs(im.Fill[i]-test.expected[i]) > 1e-12 {
+				t.Errorf("%s: expected column %d to be filled with %f, got %f", test.strategy, i, test.expected[i], im.Fill[i])
+			}
+		}
+		x, err := im.Transform(imputerTestData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x[3][0] != im.Fill[0] || x[1][1] != im.Fill[1] || x[0][0] != 1 {
+			t.Errorf("%s: unexpected transform %v", test.strategy, x)
+		}
+		if !math.IsNaN(imputerTestData[1][1]) {
+			t.Fatalf("%s: Transform modified the input", test.strategy)
+		}
+	}
+}
+
+func TestImputerIndicators(t *testing.T) {
+	train := [][]float64{{1, 2, 3}, {nan, 2, 3}, {3, 2, nan}}
+	im := &Imputer{Indicators: true}
+	if err := im.Fit(train); err != nil {
+		t.Fatal(err)
+	}
+	if im.Width() != 5 {
+		t.Fatalf("expected an indicator for the 2 columns with missing values, got width %d", im.Width())
+	}
+	row, err := im.TransformRow([]float64{nan, nan, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{2, 2, 4, 1, 0}
+	for i := range expected {
+		if row[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, row)
+		}
+	}
+	names := im.FeatureNames([]string{"a", "b", "c"})
+	if len(names) != 5 || names[3] != "a missing" || names[4] != "c missing" {
+		t.Errorf("unexpected feature names %v", names)
+	}
+	if _, err := im.TransformRow([]float64{1, 2}); err == nil {
+		t.Errorf("expected an error for the wrong number of features")
+	}
+}
+
+func TestImputerJSON(t *testing.T) {
+	im := &Imputer{Strategy: ImputeMedian, Indicators: true}
+	if err := im.Fit(imputerTestData[:3]); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(im)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Imputer{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Strategy != ImputeMedian || loaded.Width() != im.Width() || loaded.Fill[1] != im.Fill[1] {
+		t.Errorf("expected %+v, got %+v", im, loaded)
+	}
+	if err := json.Unmarshal([]byte(`{"Strategy":"mode"}`), loaded); err == nil {
+		t.Errorf("expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Loader opens a Dataset, depending on the loader path is a file or a glob pattern
+type Loader interface {
+	Load(path string) (Dataset, error)
+}
+
+// LoaderFunc lets an ordinary function be used as a Loader
+type LoaderFunc func(path string) (Dataset, error)
+
+// Load calls f(path)
+func (f LoaderFunc) Load(path string) (Dataset, error) {
+	return f(path)
+}
+
+var (
+	loadersMu      sync.RWMutex
+	loaders        = make(map[string]Loader)
+	loaderBySuffix = make(map[string]string)
+)
+
+// RegisterLoader makes a loader available by name and for files whose name ends with any of the
+// suffixes, like ".csv" or "idx3-ubyte.gz". It panics if the name or a suffix is already
+// registered.
+func RegisterLoader(name string, l Loader, suffixes ...string) {
+	loadersMu.Lock()
+	defer loadersMu.Unlock()
+	if _, exists := loaders[name]; exists {
+		panic("dataset: RegisterLoader called twice for " + name)
+	}
+	loaders[name] = l
+	for _, suffix := range suffixes {
+		suffix = strings.ToLower(suffix)
+		if other, exists := loaderBySuffix[suffix]; exists {
+			panic(fmt.Sprintf("dataset: suffix %s is registered to both %s and %s", suffix, other, name))
+		}
+		loaderBySuffix[suffix] = name
+	}
+}
+
+// Loaders returns the names of all registered loaders in sorted order
+func Loaders() []string {
+	loadersMu.RLock()
+	defer loadersMu.RUnlock()
+	var names []string
+	for name := range loaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadDataset loads path with the loader called name, if name is empty the loader is picked by the
+// suffix of the file name
+func LoadDataset(name, path string) (Dataset, error) {
+	l, err := FindLoader(name, path)
+	if err != nil {
+		return nil, err
+	}
+	return l.Load(path)
+}
+
+// FindLoader returns the loader called name, or if name is empty the loader with the longest
+// suffix that the file name of path ends with
+func FindLoader(name, path string) (Loader, error) {
+	loadersMu.RLock()
+	if name == "" {
+		base := strings.ToLower(filepath.Base(path))
+		longest := 0
+		for suffix, loader := range loaderBySuffix {
+			if len(suffix) > longest && strings.HasSuffix(base, suffix) {
+				name, longest = loader, len(suffix)
+			}
+		}
+	}
+	l, ok := loaders[name]
+	loadersMu.RUnlock()
+
+	if !ok {
+		if name == "" {
+			return nil, fmt.Errorf("dataset: no loader for %s, pick one of %s", path, strings.Join(Loaders(), ", "))
+		}
+		return nil, fmt.Errorf("dataset: unknown loader %q, pick one of %s", name, strings.Join(Loaders(), ", "))
+	}
+	return l, nil
+}
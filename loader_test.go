@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLoadDatasetByExtension(t *testing.T) {
+	set, err := LoadDataset("", "testdata/wine.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Len() != 178 {
+		t.Errorf("expected 178 examples, got %d", set.Len())
+	}
+	if _, classes := set.Dims(); classes != 3 || len(set.ClassNames()) != 3 {
+		t.Errorf("expected 3 classes, got %d (%v)", classes, set.ClassNames())
+	}
+}
+
+func TestLoadDatasetByName(t *testing.T) {
+	if _, err := LoadDataset("wine", "testdata/wine.data"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDataset("nope", "testdata/wine.data"); err == nil {
+		t.Errorf("expected an error for an unknown loader")
+	}
+	if _, err := LoadDataset("", "testdata/wine.names"); err == nil {
+		t.Errorf("expected an error for an unknown extension")
+	}
+}
+
+func TestRegisterLoader(t *testing.T) {
+	called := ""
+	RegisterLoader("test-loader", LoaderFunc(func(path string) (Dataset, error) {
+		called = path
+		return &SliceDataset{}, nil
+	}), ".TestExt")
+	defer func() {
+		delete(loaders, "test-loader")
+		delete(loaderBySuffix, ".testext")
+	}()
+
+	if _, err := LoadDataset("", "some/file.testext"); err != nil {
+		t.Fatal(err)
+	}
+	if called != "some/file.testext" {
+		t.Errorf("expected the registered loader to be called with the path, got %q", called)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected registering the same name twice to panic")
+		}
+	}()
+	RegisterLoader("test-loader", LoaderFunc(nil))
+}
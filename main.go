@@ -1,110 +1,430 @@
 package main
 
 import (
-	"encoding/json"
+	"flag"
+	"io"
 	"log"
 	"math/rand"
-	"os"
 	"runtime"
+	"strings"
 	"time"
 )
 
+var (
+	datasetName = flag.String("dataset", "", "name of the dataset loader, picked by the end of the -data file name if empty")
+	dataPath    = flag.String("data", "testdata/wine.data", "file or glob pattern to load the dataset from")
+	impute      = flag.String("impute", "mean", "how missing values are filled in: mean, median, most_frequent or constant")
+	imputeFill  = flag.Float64("impute-constant", 0, "the fill value for the constant imputation strategy")
+	indicators  = flag.Bool("impute-indicators", false, "add a feature for each column with missing values")
+	augment     = flag.Bool("augment", false, "randomly flip, crop, rotate and jitter the training images")
+	seed        = flag.Int64("seed", time.Now().UTC().UnixNano(), "seed for the random number generators")
+	valRatio    = flag.Float64("val", 0.4, "fraction of the examples used for validation")
+	testRatio   = flag.Float64("test", 0.2, "fraction of the examples used for testing")
+	stratify    = flag.Bool("stratify", true, "keep the class balance the same in every split")
+	scalers     = flag.String("scalers", "", "comma separated scalers applied in order: standard, minmax, maxabs, robust, l2, log, whitening, zca, pca or none, defaults to standard, or maxabs for sparse data")
+	pcaKeep     = flag.Float64("pca", 0, "reduce the features with PCA to this many components, or enough to explain this fraction of the variance when below 1")
+	folds       = flag.Int("folds", 0, "cross validate with this many folds on the training and validation examples instead of training once")
+	tune        = flag.String("tune", "", "search for hyperparameters instead of training once: grid, random, halving or hyperband")
+	trials      = flag.Int("trials", 20, "number of trials for the random search and successive halving")
+	minEpochs   = flag.Int("min-epochs", 10, "epochs before the poor trials are stopped by successive halving and hyperband")
+	tuneDir     = flag.String("tune-dir", "tuning", "directory for the tuning results, the best net and the state to resume halving and hyperband")
+	gradClip    = flag.Float64("clip", 0, "clip every element of the gradients to this magnitude, 0 doesn't clip")
+	nonFinite   = flag.String("on-nonfinite", "abort", "what to do when an epoch produces NaN or Inf values: abort or rollback")
+	curvesDir   = flag.String("curves", "", "directory to save the one-vs-rest ROC and precision-recall curves of the evaluated examples to as CSV and PNG")
+)
+
 func main() {
+	flag.Parse()
+
+	rand.Seed(*seed)
 
-	rand.Seed(time.Now().UTC().UnixNano())
+	// "evaluate [model]" measures a saved net on the -data instead of training one
+	if flag.Arg(0) == "evaluate" {
+		modelFile := "learned_net.bin"
+		if flag.NArg() > 1 {
+			modelFile = flag.Arg(1)
+		}
+		evaluate(modelFile)
+		return
+	}
 
-	rawX, rawY, err := wineLoader("testdata/wine.data")
+	// divide the examples into training, validation and test sets, the sets are views of the
+	// examples so a dataset on disk stays there
+	set, labels, split, err := loadSplit()
 	if err != nil {
 		panic(err)
 	}
+	if c, ok := set.(io.Closer); ok {
+		defer c.Close()
+	}
+	log.Printf("loaded %d examples from %s with the classes %s", set.Len(), *dataPath, strings.Join(set.ClassNames(), ", "))
+	log.Printf("examples per class:\n%s", split.Report(labels, set.ClassNames()))
 
-	// ensure that the data is randomised
-	for i := range rawX {
-		j := rand.Intn(i + 1)
-		rawX[i], rawX[j] = rawX[j], rawX[i]
-		rawY[i], rawY[j] = rawY[j], rawY[i]
+	// the normalisation of images is fitted on the training images, and only their crops are random
+	trainSet := set
+	if s, ok := set.(interface {
+		FitPipeline(idx []int) error
+		Randomise(seed int64) *ImageDataset
+	}); ok {
+		if err := s.FitPipeline(split.Train); err != nil {
+			panic(err)
+		}
+		trainSet = s.Randomise(*seed)
 	}
 
-	// normalise the data into a standard deviation (roughly between -1 to +1) with a gaussian distribution
-	log.Printf("normalising data")
-	n := Normaliser{}
-	normX := n.StdDev(rawX)
+	prep, err := fitPreprocessing(set, split.Train)
+	if err != nil {
+		panic(err)
+	}
+	trSet, err := Preprocessed(Subset(trainSet, split.Train), prep.Preprocess)
+	if err != nil {
+		panic(err)
+	}
+	numFeatures, numClasses := trSet.Dims()
+	log.Printf("training set contains %d examples of dimensions X: %d and Y: %d", trSet.Len(), numFeatures, numClasses)
+	cvSet, err := Preprocessed(Subset(set, split.Validation), prep.Preprocess)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("validation set contains %d examples", cvSet.Len())
+	teSet, err := Preprocessed(Subset(set, split.Test), prep.Preprocess)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("test set contains %d examples", teSet.Len())
 
-	// 80% goes to the training, 20% to test
-	trainingSize := int(float64(len(rawX)) * 0.8)
+	var onNonFinite NonFinitePolicy
+	if err := onNonFinite.UnmarshalText([]byte(*nonFinite)); err != nil {
+		log.Fatal(err)
+	}
+	newNet := func() *NeuralNet {
+		return &NeuralNet{
+			HiddenNeurons: 2000,
+			Alpha:         1e-3,
+			Lambda:        1e-2,
+			GradClip:      *gradClip,
+			OnNonFinite:   onNonFinite,
+			numBatches:    runtime.NumCPU(),
+			numEpochs:     1000,
+			log:           true,
+			plot:          true,
+		}
+	}
 
-	trX := normX[:trainingSize]
-	trY := rawY[:trainingSize]
-	log.Printf("training set contains %d examples of dimensions X: %d and Y: %d", len(trX), len(trX[0]), len(trY[0]))
+	// keepPreprocessing stores the class names and how the data was prepared with a trained net
+	keepPreprocessing := func(nn *NeuralNet) {
+		nn.Classes = set.ClassNames()
+		// the encoder, imputer and scalers are needed to predict from raw rows of tabular data
+		if s, ok := set.(*SliceDataset); ok {
+			nn.Encoder = s.Encoder
+		}
+		nn.Imputer = prep.Imputer
+		nn.Scaler = prep.Scaler
+		// and the image pipeline to predict from image files
+		if s, ok := set.(interface{ ImagePipeline() *ImagePipeline }); ok {
+			nn.ImagePipeline = s.ImagePipeline()
+		}
+	}
 
-	teX := normX[trainingSize:]
-	teY := rawY[trainingSize:]
-	log.Printf("test set contains %d examples of dimensions X: %d and Y: %d", len(teX), len(teX[0]), len(teY[0]))
+	if *folds > 0 {
+		idx := append(append([]int(nil), split.Train...), split.Validation...)
+		log.Printf("cross validating with %d folds", *folds)
+		res, err := CrossValidate(Subset(set, idx), func() *NeuralNet {
+			nn := newNet()
+			nn.log, nn.plot = false, false
+			return nn
+		}, CVOptions{
+			Folds:    *folds,
+			Stratify: *stratify,
+			Seed:     *seed,
+			Parallel: runtime.NumCPU(),
+			// the preprocessing is fitted again on the training examples of every fold
+			Prepare: func(foldSet Dataset, train []int) (Dataset, error) {
+				rows := make([]int, len(train))
+				for i, j := range train {
+					rows[i] = idx[j]
+				}
+				prep, err := fitPreprocessing(set, rows)
+				if err != nil {
+					return nil, err
+				}
+				return Preprocessed(foldSet, prep.Preprocess)
+			},
+		})
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("cross validation:\n%s", res)
+		return
+	}
 
-	nn := &NeuralNet{
-		HiddenNeurons: 2000,
-		Alpha:         1e-3,
-		Lambda:        1e-2,
-		numBatches:    runtime.NumCPU(),
-		numEpochs:     1000,
-		log:           true,
-		plot:          true,
+	if *tune != "" {
+		tuner := &Tuner{
+			NewNet: func() *NeuralNet {
+				nn := newNet()
+				nn.log, nn.plot = false, false
+				return nn
+			},
+			Train:      trSet,
+			Validation: cvSet,
+			Log:        true,
+		}
+		space := SearchSpace{
+			HiddenNeurons: []int{50, 200, 1000, 2000},
+			Alpha:         []float64{1e-4, 1e-3, 1e-2},
+			Lambda:        []float64{0, 1e-2, 1},
+			BatchSize:     []int{0, 128},
+			Activation:    []Activation{Sigmoid, Tanh, ReLU},
+			AlphaDist:     LogUniform{1e-5, 1e-1},
+			LambdaDist:    LogUniform{1e-4, 10},
+		}
+		// the seed has to be the same to resume an interrupted search
+		halving := HalvingOptions{MinEpochs: *minEpochs, MaxEpochs: newNet().numEpochs, Seed: *seed, Dir: *tuneDir}
+		var results []TrialResult
+		switch *tune {
+		case "grid":
+			results, err = tuner.GridSearch(space)
+		case "random":
+			results, err = tuner.RandomSearch(space, *trials, *seed)
+		case "halving":
+			results, err = tuner.SuccessiveHalving(space, *trials, halving)
+		case "hyperband":
+			results, err = tuner.Hyperband(space, halving)
+		default:
+			log.Fatalf("unknown search %q, use grid, random, halving or hyperband", *tune)
+		}
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("tuning results:\n%s", ResultsTable(results))
+		keepPreprocessing(Best(results))
+		if err := SaveResults(*tuneDir, results); err != nil {
+			panic(err)
+		}
+		return
 	}
 
-	// divide the training data into 50% training and 50% validation
-	trX, trY, cvX, cvY := nn.Divide(trX, trY)
+	nn := newNet()
+
+	if s, ok := set.(interface{ ImageShape() (int, int, int) }); ok && *augment {
+		channels, height, width := s.ImageShape()
+		nn.augment = NewAugmenter(channels, height, width, *seed)
+		nn.augment.FlipProb = 0.5
+		nn.augment.Pad = 4
+		nn.augment.MaxRotation = 10
+		nn.augment.Brightness = 0.2
+		nn.augment.Contrast = 0.2
+		nn.augment.CutoutSize = 8
+	}
 
 	log.Printf("training neural net")
-	trainingError, cvError := nn.Train(trX, trY, cvX, cvY)
+	trainingError, cvError, err := nn.TrainDataset(trSet, cvSet)
+	if err != nil {
+		panic(err)
+	}
 	log.Printf("final cost:\t%f\t%f", trainingError, cvError)
 
 	// use the learned the net to predict and print the accuracy
-	correct, acc := predict(nn, trX, trY)
-	log.Printf("training accuracy: %0.1f%% (%d / %d)", acc, correct, len(trY))
-	correct, acc = predict(nn, cvX, cvY)
-	log.Printf("validation accuracy: %0.1f%% (%d / %d)", acc, correct, len(cvY))
-	correct, acc = predict(nn, teX, teY)
-	log.Printf("test accuracy: %0.1f%% (%d / %d)", acc, correct, len(teY))
-	Save("learned_net.json", nn)
+	for _, s := range []struct {
+		name string
+		set  Dataset
+	}{{"training", trSet}, {"validation", cvSet}, {"test", teSet}} {
+		acc, err := accuracy(nn, s.set)
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("%s accuracy: %0.1f%% of %d", s.name, acc*100, s.set.Len())
+	}
+	scores, actual, err := nn.ScoreDataset(teSet)
+	if err != nil {
+		panic(err)
+	}
+	report("test", scores, actual, teSet.ClassNames())
+	keepPreprocessing(nn)
+	Save("learned_net.bin", nn)
 }
 
-func predict(nn *NeuralNet, X, Y [][]float64) (correct int, percent float64) {
-	for i := range X {
-		result := nn.Predict(X[i])
-		if Y[i][result[0]] > 0 {
-			correct++
+// evaluate runs every example of -data through the encoder and preprocessing saved with the net
+// and prints the metrics of its predictions, the labels are matched with the classes of the net by
+// name
+func evaluate(modelFile string) {
+	nn := Load(modelFile)
+	loader, err := FindLoader(*datasetName, *dataPath)
+	if err != nil {
+		panic(err)
+	}
+	var set Dataset
+	if tl, ok := loader.(TableLoader); ok && nn.Encoder != nil {
+		// encode the raw rows like PredictRaw rather than with an encoder fitted on this file
+		table, _, err := tl.LoadTable(*dataPath)
+		if err != nil {
+			panic(err)
+		}
+		if set, err = EncodeTable(table, nn.Encoder); err != nil {
+			panic(err)
+		}
+	} else {
+		if set, err = loader.Load(*dataPath); err != nil {
+			panic(err)
 		}
+		if c, ok := set.(io.Closer); ok {
+			defer c.Close()
+		}
+	}
+	view, err := Preprocessed(set, nn.Preprocess)
+	if err != nil {
+		panic(err)
+	}
+	log.Printf("evaluating %s on %d examples from %s", modelFile, view.Len(), *dataPath)
+	scores, actual, err := nn.ScoreDataset(view)
+	if err != nil {
+		panic(err)
 	}
-	if correct != 0 {
-		percent = (float64(correct) / float64(len(X))) * 100
+	classes := nn.Classes
+	if names := set.ClassNames(); names != nil {
+		outputs, err := nn.ClassIndices(names)
+		if err != nil {
+			panic(err)
+		}
+		for i, c := range actual {
+			actual[i] = outputs[c]
+		}
+		if classes == nil {
+			classes = names
+		}
 	}
-	return correct, percent
+	report("evaluation", scores, actual, classes)
 }
 
-func Save(fileName string, t *NeuralNet) {
-	out_f, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0777)
+// report logs the metrics of the scores of a net against the actual classes, and the AUC of every
+// class when the curves are saved to -curves
+func report(name string, scores [][]float64, actual []int, classes []string) {
+	metrics, err := Evaluate(scores, actual, classes)
 	if err != nil {
-		panic("failed to dump the network to " + fileName)
+		panic(err)
+	}
+	log.Printf("%s metrics:\n%s", name, metrics)
+	if *curvesDir == "" {
+		return
 	}
-	defer out_f.Close()
-	encoder := json.NewEncoder(out_f)
-	err = encoder.Encode(t)
+	curves, err := OneVsRest(scores, actual, classes)
 	if err != nil {
 		panic(err)
 	}
+	if err := SaveCurves(*curvesDir, curves); err != nil {
+		panic(err)
+	}
+	log.Printf("%s curves saved to %s:\n%s", name, *curvesDir, CurvesTable(curves))
 }
 
-func Load(fileName string) *NeuralNet {
-	in_f, err := os.Open(fileName)
+// loadSplit loads -data and splits the examples into training, validation and test sets. Tabular
+// files are split before they are encoded so that the encoder only learns the categories of the
+// training rows.
+func loadSplit() (Dataset, []int, *Split, error) {
+	loader, err := FindLoader(*datasetName, *dataPath)
 	if err != nil {
-		panic("failed to load " + fileName)
+		return nil, nil, nil, err
 	}
-	defer in_f.Close()
-	decoder := json.NewDecoder(in_f)
-	nn := &NeuralNet{}
-	err = decoder.Decode(nn)
+	opts := SplitOptions{Validation: *valRatio, Test: *testRatio, Stratify: *stratify, Seed: *seed}
+
+	tl, ok := loader.(TableLoader)
+	if !ok {
+		set, err := loader.Load(*dataPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		labels, err := datasetLabels(set)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		split, err := SplitLabels(labels, opts)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return set, labels, split, nil
+	}
+
+	table, enc, err := tl.LoadTable(*dataPath)
 	if err != nil {
-		panic(err)
+		return nil, nil, nil, err
+	}
+	labels, err := table.ClassIndices()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	split, err := SplitLabels(labels, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := enc.Fit(table, split.Train); err != nil {
+		return nil, nil, nil, err
+	}
+	set, err := EncodeTable(table, enc)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return set, labels, split, nil
+}
+
+// fitPreprocessing fits the imputer and scalers picked by the flags on the training examples of
+// set, each on the output of the one before, and returns them on an otherwise empty net whose
+// Preprocess runs them
+func fitPreprocessing(set Dataset, train []int) (*NeuralNet, error) {
+	prep := &NeuralNet{}
+	trainSet := Subset(set, train)
+
+	// fill in missing values, only tabular data held in memory can have them
+	if s, ok := set.(*SliceDataset); ok {
+		imputer := &Imputer{Constant: *imputeFill, Indicators: *indicators}
+		if err := imputer.Strategy.UnmarshalText([]byte(*impute)); err != nil {
+			return nil, err
+		}
+		if err := imputer.Fit(rowsAt(s.X, train)); err != nil {
+			return nil, err
+		}
+		prep.Imputer = imputer
+	}
+
+	// centring sparse data would make it dense, so it's only scaled by default
+	names := *scalers
+	if names == "" {
+		names = "standard"
+		if _, sparse := set.(*sparseDataset); sparse {
+			names = "maxabs"
+		}
+	}
+	if names == "none" && *pcaKeep <= 0 {
+		return prep, nil
+	}
+	scaler := &ScalerPipeline{}
+	if names != "none" {
+		var err error
+		if scaler, err = NewScalerPipeline(strings.Split(names, ",")...); err != nil {
+			return nil, err
+		}
+	}
+	if *pcaKeep >= 1 {
+		scaler.Steps = append(scaler.Steps, &PCA{Components: int(*pcaKeep)})
+	} else if *pcaKeep > 0 {
+		scaler.Steps = append(scaler.Steps, &PCA{Variance: *pcaKeep})
+	}
+	// the scalers are fitted on the training examples only so nothing leaks from the test set, and
+	// the examples are streamed through them rather than loaded into memory
+	log.Printf("scaling data")
+	imputed, err := Preprocessed(trainSet, prep.Preprocess)
+	if err != nil {
+		return nil, err
+	}
+	if err := scaler.FitDataset(imputed, 0); err != nil {
+		return nil, err
+	}
+	prep.Scaler = scaler
+	scaled, err := Preprocessed(trainSet, prep.Preprocess)
+	if err != nil {
+		return nil, err
 	}
-	return nn
+	before, _ := imputed.Dims()
+	after, _ := scaled.Dims()
+	log.Printf("scaled the data from %d to %d features", before, after)
+	return prep, nil
 }
@@ -12,29 +12,69 @@ type Matrix struct {
 	Data []float64
 }
 
-func NewMatrix(m [][]float64) *Matrix {
+// NewMatrix returns a matrix with a copy of the rows in m, it returns an error if m has no rows or
+// the rows aren't all the same length
+func NewMatrix(m [][]float64) (*Matrix, error) {
+	if len(m) == 0 {
+		return nil, fmt.Errorf("matrix.NewMatrix() needs at least one row")
+	}
 	// flatten
-	flattened := make([]float64, 0)
+	cols := len(m[0])
+	flattened := make([]float64, 0, len(m)*cols)
 	for i := range m {
+		if len(m[i]) != cols {
+			return nil, fmt.Errorf("matrix.NewMatrix() row %d has %d cols, expected %d", i, len(m[i]), cols)
+		}
 		flattened = append(flattened, m[i]...)
 	}
+	return NewMatrixF(flattened, len(m), cols)
+}
 
-	return &Matrix{
-		Rows: len(m),
-		Cols: len(m[0]),
-		Data: flattened,
+// MustNewMatrix is like NewMatrix but panics if m is empty or ragged
+func MustNewMatrix(m [][]float64) *Matrix {
+	A, err := NewMatrix(m)
+	if err != nil {
+		panic(err)
 	}
+	return A
 }
 
-func NewMatrixF(m []float64, rows, cols int) *Matrix {
+// ShapeError is returned when the dimensions of two matrices doesn't allow an operation between them
+type ShapeError struct {
+	Op    string
+	ARows int
+	ACols int
+	BRows int
+	BCols int
+}
+
+func (e *ShapeError) Error() string {
+	return fmt.Sprintf("matrix.%s() mismatched shapes A (%d X %d), B (%d X %d)", e.Op, e.ARows, e.ACols, e.BRows, e.BCols)
+}
+
+func newShapeError(op string, A, B *Matrix) *ShapeError {
+	return &ShapeError{Op: op, ARows: A.Rows, ACols: A.Cols, BRows: B.Rows, BCols: B.Cols}
+}
+
+// NewMatrixF returns a matrix that uses m as it's data, it returns an error if the length of m isn't rows * cols
+func NewMatrixF(m []float64, rows, cols int) (*Matrix, error) {
 	if len(m) != rows*cols {
-		panic(fmt.Sprintf("rows (%d) and cols (%d) dont match up with data length (%d)", rows, cols, len(m)))
+		return nil, fmt.Errorf("matrix.NewMatrixF() rows (%d) and cols (%d) dont match up with data length (%d)", rows, cols, len(m))
 	}
 	return &Matrix{
 		Rows: rows,
 		Cols: cols,
 		Data: m,
+	}, nil
+}
+
+// MustNewMatrixF is like NewMatrixF but panics if the data length is wrong
+func MustNewMatrixF(m []float64, rows, cols int) *Matrix {
+	A, err := NewMatrixF(m, rows, cols)
+	if err != nil {
+		panic(err)
 	}
+	return A
 }
 
 func NewRandomMatrix(rows, cols int) *Matrix {
@@ -42,12 +82,12 @@ func NewRandomMatrix(rows, cols int) *Matrix {
 	for i := range t {
 		t[i] = rand.NormFloat64()
 	}
-	return NewMatrixF(t, rows, cols)
+	return MustNewMatrixF(t, rows, cols)
 }
 
 func NewZeros(rows, cols int) *Matrix {
 	t := make([]float64, cols*rows)
-	return NewMatrixF(t, rows, cols)
+	return MustNewMatrixF(t, rows, cols)
 }
 
 func NewOnes(rows, cols int) *Matrix {
@@ -55,7 +95,7 @@ func NewOnes(rows, cols int) *Matrix {
 	for i := range t {
 		t[i] = 1
 	}
-	return NewMatrixF(t, rows, cols)
+	return MustNewMatrixF(t, rows, cols)
 }
 
 func (A *Matrix) At(row, col int) float64 {
@@ -63,11 +103,22 @@ func (A *Matrix) At(row, col int) float64 {
 }
 
 // SDot is Dot implementation that is faster on very small matrices
-func (A *Matrix) SDot(B *Matrix) *Matrix {
+func (A *Matrix) SDot(B *Matrix) (*Matrix, error) {
+	if A.Cols != B.Rows {
+		return nil, newShapeError("SDot", A, B)
+	}
+	return A.sdot(B), nil
+}
+
+// MustSDot is like SDot but panics if the shapes of A and B doesn't match
+func (A *Matrix) MustSDot(B *Matrix) *Matrix {
 	if A.Cols != B.Rows {
-		panic(fmt.Sprintf("matrix.Mul() A (%d X %d) * B (%d X %d)", A.Rows, A.Cols, B.Rows, B.Cols))
+		panic(newShapeError("SDot", A, B))
 	}
+	return A.sdot(B)
+}
 
+func (A *Matrix) sdot(B *Matrix) *Matrix {
 	result := make([]float64, A.Rows*B.Cols)
 	row := make([]float64, A.Cols)
 
@@ -83,14 +134,27 @@ func (A *Matrix) SDot(B *Matrix) *Matrix {
 			result[r*B.Cols+c] = v
 		}
 	}
-	return NewMatrixF(result, A.Rows, B.Cols)
+	return MustNewMatrixF(result, A.Rows, B.Cols)
 }
 
-func (A *Matrix) Dot(B *Matrix) *Matrix {
+// Dot returns the matrix product of A and B, or an error if the number of columns in A doesn't match
+// the number of rows in B
+func (A *Matrix) Dot(B *Matrix) (*Matrix, error) {
 	if A.Cols != B.Rows {
-		panic(fmt.Sprintf("matrix.Mul() A (%d X %d) * B (%d X %d)", A.Rows, A.Cols, B.Rows, B.Cols))
+		return nil, newShapeError("Dot", A, B)
 	}
+	return A.dot(B), nil
+}
 
+// MustDot is like Dot but panics if the shapes of A and B doesn't match
+func (A *Matrix) MustDot(B *Matrix) *Matrix {
+	if A.Cols != B.Rows {
+		panic(newShapeError("Dot", A, B))
+	}
+	return A.dot(B)
+}
+
+func (A *Matrix) dot(B *Matrix) *Matrix {
 	result := make([]float64, A.Rows*B.Cols)
 
 	in := make(chan int)
@@ -129,7 +193,7 @@ func (A *Matrix) Dot(B *Matrix) *Matrix {
 		quit <- true
 	}
 
-	return NewMatrixF(result, A.Rows, B.Cols)
+	return MustNewMatrixF(result, A.Rows, B.Cols)
 }
 
 func (A *Matrix) ArgMax() []int {
@@ -146,26 +210,56 @@ func (A *Matrix) ArgMax() []int {
 	return res
 }
 
-func (A *Matrix) Add(B *Matrix) *Matrix {
-	if A.Cols != B.Cols || A.Rows != B.Rows {
-		panic(fmt.Sprintf("matrix.Add() matrices must be the same size, A: %dX%d, B: %dX%d", A.Rows, A.Cols, B.Rows, B.Cols))
+// Add returns the element wise sum of A and B, or an error if they are not the same size
+func (A *Matrix) Add(B *Matrix) (*Matrix, error) {
+	if !A.sameShape(B) {
+		return nil, newShapeError("Add", A, B)
+	}
+	return A.add(B), nil
+}
+
+// MustAdd is like Add but panics if A and B are not the same size
+func (A *Matrix) MustAdd(B *Matrix) *Matrix {
+	if !A.sameShape(B) {
+		panic(newShapeError("Add", A, B))
 	}
+	return A.add(B)
+}
+
+func (A *Matrix) add(B *Matrix) *Matrix {
 	res := make([]float64, len(A.Data))
 	for i := range A.Data {
 		res[i] = A.Data[i] + B.Data[i]
 	}
-	return NewMatrixF(res, A.Rows, A.Cols)
+	return MustNewMatrixF(res, A.Rows, A.Cols)
+}
+
+// Sub returns the element wise difference of A and B, or an error if they are not the same size
+func (A *Matrix) Sub(B *Matrix) (*Matrix, error) {
+	if !A.sameShape(B) {
+		return nil, newShapeError("Sub", A, B)
+	}
+	return A.sub(B), nil
 }
 
-func (A *Matrix) Sub(B *Matrix) *Matrix {
-	if A.Cols != B.Cols || A.Rows != B.Rows {
-		panic(fmt.Sprintf("matrix.Sub() matrices must be the same size, A: %dX%d, B: %dX%d", A.Rows, A.Cols, B.Rows, B.Cols))
+// MustSub is like Sub but panics if A and B are not the same size
+func (A *Matrix) MustSub(B *Matrix) *Matrix {
+	if !A.sameShape(B) {
+		panic(newShapeError("Sub", A, B))
 	}
+	return A.sub(B)
+}
+
+func (A *Matrix) sub(B *Matrix) *Matrix {
 	res := make([]float64, len(A.Data))
 	for i := range A.Data {
 		res[i] = A.Data[i] - B.Data[i]
 	}
-	return NewMatrixF(res, A.Rows, A.Cols)
+	return MustNewMatrixF(res, A.Rows, A.Cols)
+}
+
+func (A *Matrix) sameShape(B *Matrix) bool {
+	return A.Rows == B.Rows && A.Cols == B.Cols
 }
 
 func (A *Matrix) Equals(B *Matrix) bool {
@@ -185,7 +279,7 @@ func (A *Matrix) ElementSquare() *Matrix {
 	for i := range res {
 		res[i] = A.Data[i] * A.Data[i]
 	}
-	return NewMatrixF(res, A.Rows, A.Cols)
+	return MustNewMatrixF(res, A.Rows, A.Cols)
 }
 
 func (A *Matrix) ElementLog() *Matrix {
@@ -193,7 +287,26 @@ func (A *Matrix) ElementLog() *Matrix {
 	for i := range res {
 		res[i] = math.Log(A.Data[i])
 	}
-	return NewMatrixF(res, A.Rows, A.Cols)
+	return MustNewMatrixF(res, A.Rows, A.Cols)
+}
+
+// Clip limits every element to be within min and max
+func (A *Matrix) Clip(min, max float64) *Matrix {
+	res := make([]float64, len(A.Data))
+	for i, val := range A.Data {
+		res[i] = math.Max(min, math.Min(max, val))
+	}
+	return MustNewMatrixF(res, A.Rows, A.Cols)
+}
+
+// IsFinite returns false if any element is NaN or Inf
+func (A *Matrix) IsFinite() bool {
+	for _, val := range A.Data {
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return false
+		}
+	}
+	return true
 }
 
 func (A *Matrix) Sum() float64 {
@@ -204,12 +317,28 @@ func (A *Matrix) Sum() float64 {
 	return sum
 }
 
-func (A *Matrix) ElementMul(B *Matrix) *Matrix {
+// ElementMul returns the element wise product of A and B, or an error if they are not the same size
+func (A *Matrix) ElementMul(B *Matrix) (*Matrix, error) {
+	if !A.sameShape(B) {
+		return nil, newShapeError("ElementMul", A, B)
+	}
+	return A.elementMul(B), nil
+}
+
+// MustElementMul is like ElementMul but panics if A and B are not the same size
+func (A *Matrix) MustElementMul(B *Matrix) *Matrix {
+	if !A.sameShape(B) {
+		panic(newShapeError("ElementMul", A, B))
+	}
+	return A.elementMul(B)
+}
+
+func (A *Matrix) elementMul(B *Matrix) *Matrix {
 	res := make([]float64, len(A.Data))
 	for i := range A.Data {
 		res[i] = A.Data[i] * B.Data[i]
 	}
-	return NewMatrixF(res, A.Rows, A.Cols)
+	return MustNewMatrixF(res, A.Rows, A.Cols)
 }
 
 func (A *Matrix) ScalarMul(val float64) *Matrix {
@@ -217,7 +346,7 @@ func (A *Matrix) ScalarMul(val float64) *Matrix {
 	for i := range res {
 		res[i] = A.Data[i] * val
 	}
-	return NewMatrixF(res, A.Rows, A.Cols)
+	return MustNewMatrixF(res, A.Rows, A.Cols)
 }
 
 func (A *Matrix) ScalarDiv(val float64) *Matrix {
@@ -225,13 +354,13 @@ func (A *Matrix) ScalarDiv(val float64) *Matrix {
 	for i := range res {
 		res[i] = A.Data[i] / val
 	}
-	return NewMatrixF(res, A.Rows, A.Cols)
+	return MustNewMatrixF(res, A.Rows, A.Cols)
 }
 
 func (A *Matrix) Clone() *Matrix {
 	clonedData := make([]float64, len(A.Data))
 	copy(clonedData, A.Data)
-	return NewMatrixF(clonedData, A.Rows, A.Cols)
+	return MustNewMatrixF(clonedData, A.Rows, A.Cols)
 }
 
 func (A *Matrix) AddBias() *Matrix {
@@ -244,7 +373,7 @@ func (A *Matrix) AddBias() *Matrix {
 		copy(res[stride+row:stride+length+row], A.Data[fromStride:fromStride+length])
 		res[row*A.Cols+row] = 1
 	}
-	return NewMatrixF(res, A.Rows, A.Cols+1)
+	return MustNewMatrixF(res, A.Rows, A.Cols+1)
 }
 
 func (A *Matrix) RemoveBias() *Matrix {
@@ -256,7 +385,7 @@ func (A *Matrix) RemoveBias() *Matrix {
 		length := A.Cols - 1
 		copy(res[stride-row:stride+length-row], A.Data[fromStride:fromStride+length])
 	}
-	return NewMatrixF(res, A.Rows, A.Cols-1)
+	return MustNewMatrixF(res, A.Rows, A.Cols-1)
 }
 
 func (A *Matrix) ZeroBias() *Matrix {
@@ -294,5 +423,5 @@ func (A *Matrix) T() *Matrix {
 			t[col*A.Rows+row] = A.Data[row*A.Cols+col]
 		}
 	}
-	return NewMatrixF(t, A.Cols, A.Rows)
+	return MustNewMatrixF(t, A.Cols, A.Rows)
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+// the binary matrix layout is, all numbers little-endian:
+//
+//	magic    [4]byte "MATX"
+//	version  uint8
+//	dtype    uint8
+//	reserved [2]byte
+//	rows     uint64
+//	cols     uint64
+//	data     rows * cols values of dtype
+//	checksum uint32 crc32 (IEEE) of everything before it
+const (
+	matrixMagic      = "MATX"
+	matrixVersion    = 1
+	matrixHeaderSize = 4 + 1 + 1 + 2 + 8 + 8
+
+	dtypeFloat64 = 1
+)
+
+var errMatrixChecksum = errors.New("matrix.UnmarshalBinary() checksum mismatch, the data is corrupt")
+
+// MarshalBinary implements encoding.BinaryMarshaler
+func (A *Matrix) MarshalBinary() ([]byte, error) {
+	if len(A.Data) != A.Rows*A.Cols {
+		return nil, fmt.Errorf("matrix.MarshalBinary() rows (%d) and cols (%d) dont match up with data length (%d)", A.Rows, A.Cols, len(A.Data))
+	}
+	buf := make([]byte, matrixHeaderSize+len(A.Data)*8+4)
+	copy(buf, matrixMagic)
+	buf[4] = matrixVersion
+	buf[5] = dtypeFloat64
+	binary.LittleEndian.PutUint64(buf[8:], uint64(A.Rows))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(A.Cols))
+
+	payload := buf[matrixHeaderSize:]
+	for i, val := range A.Data {
+		binary.LittleEndian.PutUint64(payload[i*8:], math.Float64bits(val))
+	}
+
+	end := len(buf) - 4
+	binary.LittleEndian.PutUint32(buf[end:], crc32.ChecksumIEEE(buf[:end]))
+	return buf, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler
+func (A *Matrix) UnmarshalBinary(data []byte) error {
+	if len(data) < matrixHeaderSize+4 {
+		return fmt.Errorf("matrix.UnmarshalBinary() need at least %d bytes, got %d", matrixHeaderSize+4, len(data))
+	}
+	if !bytes.Equal(data[:4], []byte(matrixMagic)) {
+		return fmt.Errorf("matrix.UnmarshalBinary() unknown magic %q", data[:4])
+	}
+	if data[4] != matrixVersion {
+		return fmt.Errorf("matrix.UnmarshalBinary() unsupported version %d", data[4])
+	}
+	if data[5] != dtypeFloat64 {
+		return fmt.Errorf("matrix.UnmarshalBinary() unsupported dtype %d", data[5])
+	}
+
+	end := len(data) - 4
+	if crc32.ChecksumIEEE(data[:end]) != binary.LittleEndian.Uint32(data[end:]) {
+		return errMatrixChecksum
+	}
+
+	rows := binary.LittleEndian.Uint64(data[8:])
+	cols := binary.LittleEndian.Uint64(data[16:])
+	payload := data[matrixHeaderSize:end]
+	if rows == 0 || cols == 0 || rows > math.MaxInt/8/cols {
+		return fmt.Errorf("matrix.UnmarshalBinary() invalid shape %d X %d", rows, cols)
+	}
+	if rows*cols*8 != uint64(len(payload)) {
+		return fmt.Errorf("matrix.UnmarshalBinary() rows (%d) and cols (%d) dont match up with data length (%d bytes)", rows, cols, len(payload))
+	}
+
+	values := make([]float64, rows*cols)
+	for i := range values {
+		values[i] = math.Float64frombits(binary.LittleEndian.Uint64(payload[i*8:]))
+	}
+	A.Rows = int(rows)
+	A.Cols = int(cols)
+	A.Data = values
+	return nil
+}
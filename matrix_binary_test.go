@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding"
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"testing"
+)
+
+var _ encoding.BinaryMarshaler = &Matrix{}
+var _ encoding.BinaryUnmarshaler = &Matrix{}
+
+func TestMatrixBinaryRoundTrip(t *testing.T) {
+	A := MustNewMatrix([][]float64{
+		[]float64{1, -2.5, math.Inf(1)},
+		[]float64{4, 5e-300, math.MaxFloat64},
+	})
+
+	data, err := A.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != matrixHeaderSize+6*8+4 {
+		t.Errorf("expected %d bytes, got %d", matrixHeaderSize+6*8+4, len(data))
+	}
+
+	actual := &Matrix{}
+	if err := actual.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !actual.Equals(A) {
+		t.Errorf("actual is not the same as expected")
+		actual.Print()
+		A.Print()
+	}
+}
+
+func TestMatrixBinaryCorrupt(t *testing.T) {
+	A := NewOnes(2, 3)
+	data, err := A.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte{}, data...)
+	corrupt[matrixHeaderSize+3] ^= 0xff
+	if err := (&Matrix{}).UnmarshalBinary(corrupt); err != errMatrixChecksum {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+
+	if err := (&Matrix{}).UnmarshalBinary(data[:10]); err == nil {
+		t.Errorf("expected an error for truncated data")
+	}
+
+	wrongMagic := append([]byte{}, data...)
+	copy(wrongMagic, "NOPE")
+	if err := (&Matrix{}).UnmarshalBinary(wrongMagic); err == nil {
+		t.Errorf("expected an error for an unknown magic")
+	}
+}
+
+func TestMatrixBinaryInvalidShape(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows, cols uint64
+	}{
+		{"no cols", 5, 0},
+		{"no rows", 0, 5},
+		{"overflow", 1 << 33, 1 << 33},
+	}
+	for _, test := range tests {
+		// a header with the shape and a valid checksum but no payload
+		data := make([]byte, matrixHeaderSize+4)
+		copy(data, matrixMagic)
+		data[4] = matrixVersion
+		data[5] = dtypeFloat64
+		binary.LittleEndian.PutUint64(data[8:], test.rows)
+		binary.LittleEndian.PutUint64(data[16:], test.cols)
+		binary.LittleEndian.PutUint32(data[matrixHeaderSize:], crc32.ChecksumIEEE(data[:matrixHeaderSize]))
+		if err := (&Matrix{}).UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: expected an error for a %d x %d matrix", test.name, test.rows, test.cols)
+		}
+	}
+}
+
+func BenchmarkMatrixMarshalBinary(b *testing.B) {
+	A := MustNewMatrixF(getMatrixData(256, 128), 256, 128)
+	for i := 0; i < b.N; i++ {
+		if _, err := A.MarshalBinary(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -13,15 +13,23 @@ func TestNewMatrix(t *testing.T) {
 		[]float64{2, 5, 8, 345},
 		[]float64{3, 6, 9, 214},
 	}
-	m := NewMatrix(input)
+	m := MustNewMatrix(input)
 	rows, cols := m.Rows, m.Cols
 	if rows != 3 && cols != 4 {
 		t.Errorf("expected correct dims")
 	}
 }
 
+func TestNewMatrixInvalid(t *testing.T) {
+	for _, m := range [][][]float64{nil, {{1, 2}, {3}}} {
+		if _, err := NewMatrix(m); err == nil {
+			t.Errorf("expected an error for %v", m)
+		}
+	}
+}
+
 func TestMatrixAt(t *testing.T) {
-	m := NewMatrix([][]float64{
+	m := MustNewMatrix([][]float64{
 		[]float64{0, 1},
 		[]float64{2, 3},
 	})
@@ -73,10 +81,13 @@ var matrixMulTestTable = [][][][]float64{
 
 func TestMatrixSDot(t *testing.T) {
 	for _, test := range matrixMulTestTable {
-		A := NewMatrix(test[0])
-		B := NewMatrix(test[1])
-		expected := NewMatrix(test[2])
-		actual := A.SDot(B)
+		A := MustNewMatrix(test[0])
+		B := MustNewMatrix(test[1])
+		expected := MustNewMatrix(test[2])
+		actual, err := A.SDot(B)
+		if err != nil {
+			t.Fatal(err)
+		}
 		if !actual.Equals(expected) {
 			t.Errorf("actual is not the same as expected")
 			actual.Print()
@@ -87,10 +98,13 @@ func TestMatrixSDot(t *testing.T) {
 
 func TestMatrixDot(t *testing.T) {
 	for _, test := range matrixMulTestTable {
-		A := NewMatrix(test[0])
-		B := NewMatrix(test[1])
-		expected := NewMatrix(test[2])
-		actual := A.Dot(B)
+		A := MustNewMatrix(test[0])
+		B := MustNewMatrix(test[1])
+		expected := MustNewMatrix(test[2])
+		actual, err := A.Dot(B)
+		if err != nil {
+			t.Fatal(err)
+		}
 		if !actual.Equals(expected) {
 			t.Errorf("actual is not the same as expected")
 			actual.Print()
@@ -99,50 +113,95 @@ func TestMatrixDot(t *testing.T) {
 	}
 }
 
+func TestMatrixDotShapeError(t *testing.T) {
+	A := MustNewMatrix([][]float64{
+		[]float64{1, 2, 3},
+	})
+	B := MustNewMatrix([][]float64{
+		[]float64{1, 2},
+	})
+	if _, err := A.Dot(B); err == nil {
+		t.Errorf("expected Dot to return an error for mismatched shapes")
+	}
+	_, err := A.SDot(B)
+	if err == nil {
+		t.Fatalf("expected SDot to return an error for mismatched shapes")
+	}
+	shapeErr, ok := err.(*ShapeError)
+	if !ok {
+		t.Fatalf("expected a *ShapeError, got %T", err)
+	}
+	if shapeErr.ACols != 3 || shapeErr.BRows != 1 {
+		t.Errorf("expected the error to describe A (1 X 3) and B (1 X 2), got %s", shapeErr)
+	}
+}
+
+func TestMatrixMustDotPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected MustDot to panic on mismatched shapes")
+		}
+	}()
+	A := NewOnes(2, 3)
+	A.MustDot(NewOnes(2, 3))
+}
+
+func TestNewMatrixFLength(t *testing.T) {
+	if _, err := NewMatrixF([]float64{1, 2, 3}, 2, 2); err == nil {
+		t.Errorf("expected an error when the data length doesn't match rows * cols")
+	}
+	if _, err := NewMatrixF([]float64{1, 2, 3, 4}, 2, 2); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+}
+
 func BenchmarkMatrixSDot(b *testing.B) {
 	aD := getMatrixData(256, 128)
-	A := NewMatrixF(aD, 256, 128)
+	A := MustNewMatrixF(aD, 256, 128)
 
 	bD := getMatrixData(128, 256)
-	B := NewMatrixF(bD, 128, 256)
+	B := MustNewMatrixF(bD, 128, 256)
 
 	var actual *Matrix
 	for i := 0; i < b.N; i++ {
-		actual = A.SDot(B)
+		actual = A.MustSDot(B)
 	}
 	bResult = actual
 }
 
 func BenchmarkMatrixDot(b *testing.B) {
 	aD := getMatrixData(256, 128)
-	A := NewMatrixF(aD, 256, 128)
+	A := MustNewMatrixF(aD, 256, 128)
 
 	bD := getMatrixData(128, 256)
-	B := NewMatrixF(bD, 128, 256)
+	B := MustNewMatrixF(bD, 128, 256)
 
 	var actual *Matrix
 	for i := 0; i < b.N; i++ {
-		actual = A.Dot(B)
+		actual = A.MustDot(B)
 	}
 	bResult = actual
 }
 
 func TestMatrixAdd(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3},
 		[]float64{4, 5, 6},
 	})
 
-	B := NewMatrix([][]float64{
+	B := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3},
 		[]float64{4, 5, 6},
 	})
 
-	expected := NewMatrix([][]float64{
+	expected := MustNewMatrix([][]float64{
 		[]float64{2, 4, 6},
 		[]float64{8, 10, 12},
 	})
-	actual := A.Add(B)
+	actual, err := A.Add(B)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !actual.Equals(expected) {
 		t.Errorf("actual is not the same as expected")
 		actual.Print()
@@ -151,21 +210,24 @@ func TestMatrixAdd(t *testing.T) {
 }
 
 func TestMatrixSub(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3},
 		[]float64{4, 5, 6},
 	})
 
-	B := NewMatrix([][]float64{
+	B := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3},
 		[]float64{4, 5, 6},
 	})
 
-	expected := NewMatrix([][]float64{
+	expected := MustNewMatrix([][]float64{
 		[]float64{0, 0, 0},
 		[]float64{0, 0, 0},
 	})
-	actual := A.Sub(B)
+	actual, err := A.Sub(B)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !actual.Equals(expected) {
 		t.Errorf("actual is not the same as expected")
 		actual.Print()
@@ -173,8 +235,39 @@ func TestMatrixSub(t *testing.T) {
 	}
 }
 
+func TestMatrixElementMul(t *testing.T) {
+	A := MustNewMatrix([][]float64{
+		[]float64{1, 2, 3},
+		[]float64{4, 5, 6},
+	})
+
+	expected := MustNewMatrix([][]float64{
+		[]float64{1, 4, 9},
+		[]float64{16, 25, 36},
+	})
+	actual, err := A.ElementMul(A)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.Equals(expected) {
+		t.Errorf("actual is not the same as expected")
+		actual.Print()
+		expected.Print()
+	}
+
+	if _, err := A.ElementMul(A.T()); err == nil {
+		t.Errorf("expected ElementMul to return an error for mismatched shapes")
+	}
+	if _, err := A.Add(A.T()); err == nil {
+		t.Errorf("expected Add to return an error for mismatched shapes")
+	}
+	if _, err := A.Sub(A.T()); err == nil {
+		t.Errorf("expected Sub to return an error for mismatched shapes")
+	}
+}
+
 func TestMatrixSum(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3},
 		[]float64{4, 5, 6},
 	})
@@ -189,7 +282,7 @@ func TestMatrixSum(t *testing.T) {
 func BenchmarkMatrixTranspose(b *testing.B) {
 
 	aD := getMatrixData(256, 128)
-	A := NewMatrixF(aD, 256, 128)
+	A := MustNewMatrixF(aD, 256, 128)
 
 	var actual *Matrix
 	for i := 0; i < b.N; i++ {
@@ -199,13 +292,13 @@ func BenchmarkMatrixTranspose(b *testing.B) {
 }
 
 func TestMatrixTranspose(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{1, 2},
 		[]float64{3, 4},
 		[]float64{5, 6},
 	})
 
-	expected := NewMatrix([][]float64{
+	expected := MustNewMatrix([][]float64{
 		[]float64{1, 3, 5},
 		[]float64{2, 4, 6},
 	})
@@ -220,7 +313,7 @@ func TestMatrixTranspose(t *testing.T) {
 }
 
 func TestArgMax(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{3, 9, 6},
 		[]float64{10, 8, 16},
 		[]float64{9, 8, 6},
@@ -240,29 +333,29 @@ func TestArgMax(t *testing.T) {
 }
 
 func BenchmarkMatrixAdd(b *testing.B) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3},
 		[]float64{4, 5, 6},
 	})
 
-	B := NewMatrix([][]float64{
+	B := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3},
 		[]float64{4, 5, 6},
 	})
 	var actual *Matrix
 	for i := 0; i < b.N; i++ {
-		actual = A.Add(B)
+		actual = A.MustAdd(B)
 	}
 	bResult = actual
 }
 
 func TestAddBias(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{2, 3, 4, 5},
 		[]float64{6, 7, 8, 9},
 		[]float64{10, 11, 12, 13},
 	})
-	expected := NewMatrix([][]float64{
+	expected := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3, 4, 5},
 		[]float64{1, 6, 7, 8, 9},
 		[]float64{1, 10, 11, 12, 13},
@@ -277,7 +370,7 @@ func TestAddBias(t *testing.T) {
 
 func BenchmarkAddBias(b *testing.B) {
 	aD := getMatrixData(256, 128)
-	A := NewMatrixF(aD, 256, 128)
+	A := MustNewMatrixF(aD, 256, 128)
 	var actual *Matrix
 	for i := 0; i < b.N; i++ {
 		actual = A.AddBias()
@@ -286,12 +379,12 @@ func BenchmarkAddBias(b *testing.B) {
 }
 
 func TestRemoveBias(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3, 4, 5},
 		[]float64{1, 6, 7, 8, 9},
 		[]float64{1, 10, 11, 12, 13},
 	})
-	expected := NewMatrix([][]float64{
+	expected := MustNewMatrix([][]float64{
 		[]float64{2, 3, 4, 5},
 		[]float64{6, 7, 8, 9},
 		[]float64{10, 11, 12, 13},
@@ -305,12 +398,12 @@ func TestRemoveBias(t *testing.T) {
 }
 
 func TestZeroBias(t *testing.T) {
-	A := NewMatrix([][]float64{
+	A := MustNewMatrix([][]float64{
 		[]float64{1, 2, 3, 4, 5},
 		[]float64{1, 6, 7, 8, 9},
 		[]float64{1, 10, 11, 12, 13},
 	})
-	expected := NewMatrix([][]float64{
+	expected := MustNewMatrix([][]float64{
 		[]float64{0, 2, 3, 4, 5},
 		[]float64{0, 6, 7, 8, 9},
 		[]float64{0, 10, 11, 12, 13},
@@ -325,7 +418,7 @@ func TestZeroBias(t *testing.T) {
 
 func BenchmarkRemoveBias(b *testing.B) {
 	aD := getMatrixData(256, 128)
-	A := NewMatrixF(aD, 256, 128)
+	A := MustNewMatrixF(aD, 256, 128)
 	var actual *Matrix
 	for i := 0; i < b.N; i++ {
 		actual = A.RemoveBias()
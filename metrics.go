@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+// ClassMetrics are the precision, recall and F1 score of a class, or an average over the classes
+type ClassMetrics struct {
+	Precision, Recall, F1 float64
+	// Support is the number of examples of the class
+	Support int
+}
+
+// Metrics measures the scores of a net against the actual classes
+type Metrics struct {
+	Classes []string
+	// Confusion counts the examples of each actual class, the rows, by their predicted class
+	Confusion [][]int
+	PerClass  []ClassMetrics
+	Accuracy  float64
+	// Macro averages the classes equally, Micro counts every example equally and Weighted averages
+	// the classes by their support
+	Macro, Micro, Weighted ClassMetrics
+	// TopK[k-1] is the fraction of examples where the actual class has one of the k highest scores
+	TopK    []float64
+	LogLoss float64
+	// Kappa is Cohen's kappa, the agreement between the predicted and actual classes beyond what
+	// would be expected by chance
+	Kappa float64
+}
+
+// maxTopK is the largest k that Evaluate reports the top-k accuracy for
+const maxTopK = 5
+
+// Evaluate computes the metrics from the scores of every example, like the output of
+// ScoreDataset, and the actual classes. classNames can be nil.
+func Evaluate(scores [][]float64, actual []int, classNames []string) (*Metrics, error) {
+	if len(scores) == 0 || len(scores) != len(actual) {
+		return nil, fmt.Errorf("metrics: expected a score for each of the %d examples, got %d", len(actual), len(scores))
+	}
+	numClasses := len(scores[0])
+	for i := range scores {
+		if len(scores[i]) != numClasses {
+			return nil, fmt.Errorf("metrics: expected %d scores for example %d, got %d", numClasses, i, len(scores[i]))
+		}
+		if actual[i] < 0 || actual[i] >= numClasses {
+			return nil, fmt.Errorf("metrics: class %d of example %d is out of range [0, %d)", actual[i], i, numClasses)
+		}
+	}
+
+	predicted := make([]int, len(scores))
+	for i := range scores {
+		predicted[i] = argMax(scores[i])
+	}
+	m := &Metrics{Classes: classNames}
+	m.Confusion = ConfusionMatrix(predicted, actual, numClasses)
+	m.PerClass, m.Macro, m.Micro, m.Weighted = classMetrics(m.Confusion)
+	m.Accuracy = m.Micro.Recall
+	for k := 1; k <= maxTopK && k <= numClasses; k++ {
+		m.TopK = append(m.TopK, TopKAccuracy(scores, actual, k))
+	}
+	m.LogLoss = LogLoss(scores, actual)
+	m.Kappa = CohenKappa(m.Confusion)
+	return m, nil
+}
+
+// ConfusionMatrix counts the examples of each actual class, the rows, by their predicted class
+func ConfusionMatrix(predicted, actual []int, numClasses int) [][]int {
+	confusion := make([][]int, numClasses)
+	for c := range confusion {
+		confusion[c] = make([]int, numClasses)
+	}
+	for i := range actual {
+		confusion[actual[i]][predicted[i]]++
+	}
+	return confusion
+}
+
+// classMetrics returns the metrics of every class and their macro, micro and weighted averages
+func classMetrics(confusion [][]int) (perClass []ClassMetrics, macro, micro, weighted ClassMetrics) {
+	numClasses := len(confusion)
+	perClass = make([]ClassMetrics, numClasses)
+	var truePos, predictedPos, total int
+	for c := range confusion {
+		var predicted int
+		for a := range confusion {
+			predicted += confusion[a][c]
+			perClass[c].Support += confusion[c][a]
+		}
+		tp := confusion[c][c]
+		perClass[c].Precision = ratio(tp, predicted)
+		perClass[c].Recall = ratio(tp, perClass[c].Support)
+		perClass[c].F1 = f1(perClass[c].Precision, perClass[c].Recall)
+
+		truePos += tp
+		predictedPos += predicted
+		total += perClass[c].Support
+	}
+
+	for _, cm := range perClass {
+		macro.Precision += cm.Precision / float64(numClasses)
+		macro.Recall += cm.Recall / float64(numClasses)
+		macro.F1 += cm.F1 / float64(numClasses)
+		if total > 0 {
+			w := float64(cm.Support) / float64(total)
+			weighted.Precision += cm.Precision * w
+			weighted.Recall += cm.Recall * w
+			weighted.F1 += cm.F1 * w
+		}
+	}
+	macro.Support, weighted.Support = total, total
+
+	// every example has exactly one predicted class so the micro precision and recall are the same
+	micro = ClassMetrics{Precision: ratio(truePos, predictedPos), Recall: ratio(truePos, total), Support: total}
+	micro.F1 = f1(micro.Precision, micro.Recall)
+	return perClass, macro, micro, weighted
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// TopKAccuracy returns the fraction of examples where the actual class has one of the k highest
+// scores
+func TopKAccuracy(scores [][]float64, actual []int, k int) float64 {
+	if len(actual) == 0 {
+		return 0
+	}
+	correct := 0
+	for i, row := range scores {
+		for _, c := range topK(row, k) {
+			if c == actual[i] {
+				correct++
+				break
+			}
+		}
+	}
+	return float64(correct) / float64(len(actual))
+}
+
+// topK returns the classes with the k highest scores, highest first
+func topK(scores []float64, k int) []int {
+	idx := make([]int, len(scores))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+	if k < len(idx) {
+		idx = idx[:k]
+	}
+	return idx
+}
+
+// logLossEpsilon keeps log loss finite when a score is 0 or 1
+const logLossEpsilon = 1e-15
+
+// LogLoss returns the cross entropy of the scores summed over the classes and averaged over the
+// examples, the same as the cost of the net without regularisation. Every score is the probability
+// of its class on its own, so both a low score for the actual class and a high score for any other
+// class add to the loss.
+func LogLoss(scores [][]float64, actual []int) float64 {
+	if len(actual) == 0 {
+		return 0
+	}
+	var loss float64
+	for i, row := range scores {
+		for c, p := range row {
+			p = math.Min(math.Max(p, logLossEpsilon), 1-logLossEpsilon)
+			if c == actual[i] {
+				loss -= math.Log(p)
+			} else {
+				loss -= math.Log(1 - p)
+			}
+		}
+	}
+	return loss / float64(len(actual))
+}
+
+// CohenKappa returns Cohen's kappa of a confusion matrix, 1 is perfect agreement and 0 is no better
+// than chance
+func CohenKappa(confusion [][]int) float64 {
+	var total, agree float64
+	rows := make([]float64, len(confusion))
+	cols := make([]float64, len(confusion))
+	for a := range confusion {
+		for p, count := range confusion[a] {
+			total += float64(count)
+			rows[a] += float64(count)
+			cols[p] += float64(count)
+			if a == p {
+				agree += float64(count)
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	var chance float64
+	for c := range rows {
+		chance += rows[c] * cols[c] / (total * total)
+	}
+	if chance == 1 {
+		// every example is the same class in both, there's nothing beyond chance to measure
+		return 0
+	}
+	return (agree/total - chance) / (1 - chance)
+}
+
+// className returns the name of class c, or its index if it has no name
+func (m *Metrics) className(c int) string {
+	if c < len(m.Classes) {
+		return m.Classes[c]
+	}
+	return fmt.Sprintf("%d", c)
+}
+
+// String returns the metrics of every class, the averages and the confusion matrix as tables
+func (m *Metrics) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "class\tprecision\trecall\tf1\tsupport\t")
+	for c, cm := range m.PerClass {
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%.3f\t%d\t\n", m.className(c), cm.Precision, cm.Recall, cm.F1, cm.Support)
+	}
+	for _, avg := range []struct {
+		name string
+		cm   ClassMetrics
+	}{{"macro avg", m.Macro}, {"micro avg", m.Micro}, {"weighted avg", m.Weighted}} {
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%.3f\t%d\t\n", avg.name, avg.cm.Precision, avg.cm.Recall, avg.cm.F1, avg.cm.Support)
+	}
+	w.Flush()
+
+	fmt.Fprintf(&buf, "\naccuracy: %.1f%%\n", m.Accuracy*100)
+	for k := 2; k <= len(m.TopK); k++ {
+		fmt.Fprintf(&buf, "top-%d accuracy: %.1f%%\n", k, m.TopK[k-1]*100)
+	}
+	fmt.Fprintf(&buf, "log loss: %.4f\n", m.LogLoss)
+	fmt.Fprintf(&buf, "cohen's kappa: %.4f\n\n", m.Kappa)
+
+	w = tabwriter.NewWriter(&buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "actual \\ predicted\t")
+	for c := range m.Confusion {
+		fmt.Fprintf(w, "%s\t", m.className(c))
+	}
+	fmt.Fprintln(w)
+	for a, row := range m.Confusion {
+		fmt.Fprintf(w, "%s\t", m.className(a))
+		for _, count := range row {
+			fmt.Fprintf(w, "%d\t", count)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// Evaluate scores every example in the set, which must already be preprocessed, and measures the
+// scores against the actual classes
+func (t *NeuralNet) Evaluate(set Dataset) (*Metrics, error) {
+	scores, actual, err := t.ScoreDataset(set)
+	if err != nil {
+		return nil, err
+	}
+	return Evaluate(scores, actual, set.ClassNames())
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// metricsTestScores has 3 classes, the predicted classes are 0, 0, 1, 1, 2, 0 against the actual
+// classes 0, 0, 1, 2, 2, 2
+var metricsTestScores = [][]float64{
+	{0.9, 0.05, 0.05},
+	{0.6, 0.3, 0.1},
+	{0.2, 0.7, 0.1},
+	{0.1, 0.5, 0.4},
+	{0.1, 0.1, 0.8},
+	{0.5, 0.1, 0.4},
+}
+
+var metricsTestActual = []int{0, 0, 1, 2, 2, 2}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestEvaluate(t *testing.T) {
+	m, err := Evaluate(metricsTestScores, metricsTestActual, []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedConfusion := [][]int{{2, 0, 0}, {0, 1, 0}, {1, 1, 1}}
+	for a := range expectedConfusion {
+		for p := range expectedConfusion[a] {
+			if m.Confusion[a][p] != expectedConfusion[a][p] {
+				t.Fatalf("expected the confusion matrix %v, got %v", expectedConfusion, m.Confusion)
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		actual   ClassMetrics
+		expected ClassMetrics
+	}{
+		{"a", m.PerClass[0], ClassMetrics{Precision: 2.0 / 3, Recall: 1, F1: 0.8, Support: 2}},
+		{"b", m.PerClass[1], ClassMetrics{Precision: 0.5, Recall: 1, F1: 2.0 / 3, Support: 1}},
+		{"c", m.PerClass[2], ClassMetrics{Precision: 1, Recall: 1.0 / 3, F1: 0.5, Support: 3}},
+		{"macro", m.Macro, ClassMetrics{Precision: 13.0 / 18, Recall: 7.0 / 9, F1: (0.8 + 2.0/3 + 0.5) / 3, Support: 6}},
+		{"micro", m.Micro, ClassMetrics{Precision: 4.0 / 6, Recall: 4.0 / 6, F1: 4.0 / 6, Support: 6}},
+		{"weighted", m.Weighted, ClassMetrics{Precision: (2*2.0/3 + 0.5 + 3) / 6, Recall: 4.0 / 6, F1: (1.6 + 2.0/3 + 1.5) / 6, Support: 6}},
+	}
+	for _, test := range tests {
+		a, e := test.actual, test.expected
+		if !almostEqual(a.Precision, e.Precision) || !almostEqual(a.Recall, e.Recall) || !almostEqual(a.F1, e.F1) || a.Support != e.Support {
+			t.Errorf("%s: expected %+v, got %+v", test.name, e, a)
+		}
+	}
+
+	if !almostEqual(m.Accuracy, 4.0/6) {
+		t.Errorf("expected an accuracy of 4/6, got %f", m.Accuracy)
+	}
+	if len(m.TopK) != 3 || !almostEqual(m.TopK[1], 1) || m.TopK[0] != m.Accuracy {
+		t.Errorf("unexpected top-k accuracies %v", m.TopK)
+	}
+	// the chance agreement is (2*3 + 1*2 + 3*1) / 36
+	if expected := (4.0/6 - 11.0/36) / (1 - 11.0/36); !almostEqual(m.Kappa, expected) {
+		t.Errorf("expected a kappa of %f, got %f", expected, m.Kappa)
+	}
+
+	report := m.String()
+	for _, want := range []string{"weighted avg", "top-2 accuracy: 100.0%", "cohen's kappa", "actual \\ predicted"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected %q in the report\n%s", want, report)
+		}
+	}
+}
+
+func TestLogLoss(t *testing.T) {
+	// -(log(0.5) + log(0.5)) for the first row and -(log(0.8) + log(1 - 0.2)) for the second
+	loss := LogLoss([][]float64{{0.5, 0.5}, {0.8, 0.2}}, []int{0, 0})
+	if expected := math.Log(2) - math.Log(0.8); !almostEqual(loss, expected) {
+		t.Errorf("expected %f, got %f", expected, loss)
+	}
+	if loss := LogLoss([][]float64{{1, 0}}, []int{1}); math.IsInf(loss, 0) || loss < 30 {
+		t.Errorf("expected a large but finite loss, got %f", loss)
+	}
+}
+
+func TestCohenKappa(t *testing.T) {
+	if kappa := CohenKappa([][]int{{5, 0}, {0, 5}}); kappa != 1 {
+		t.Errorf("expected 1 for perfect agreement, got %f", kappa)
+	}
+	if kappa := CohenKappa([][]int{{5, 5}, {5, 5}}); kappa != 0 {
+		t.Errorf("expected 0 for chance agreement, got %f", kappa)
+	}
+	if kappa := CohenKappa([][]int{{4, 0}, {0, 0}}); kappa != 0 {
+		t.Errorf("expected 0 when there's a single class, got %f", kappa)
+	}
+}
+
+func TestEvaluateInvalid(t *testing.T) {
+	tests := []struct {
+		scores [][]float64
+		actual []int
+	}{
+		{nil, nil},
+		{[][]float64{{1, 0}}, []int{0, 1}},
+		{[][]float64{{1, 0}, {1}}, []int{0, 1}},
+		{[][]float64{{1, 0}}, []int{2}},
+	}
+	for _, test := range tests {
+		if _, err := Evaluate(test.scores, test.actual, nil); err == nil {
+			t.Errorf("expected an error for %v and %v", test.scores, test.actual)
+		}
+	}
+}
+
+func TestNeuralNetEvaluate(t *testing.T) {
+	train, validation := separableTestSets()
+	nn := &NeuralNet{HiddenNeurons: 4, Alpha: 0.5, numBatches: 1, numEpochs: 200}
+	if _, _, err := nn.TrainDataset(train, validation); err != nil {
+		t.Fatal(err)
+	}
+	m, err := nn.Evaluate(validation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	predicted, actual, err := nn.PredictDataset(validation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Micro.Support != len(actual) {
+		t.Fatalf("expected %d examples, got %d", len(actual), m.Micro.Support)
+	}
+	correct := 0
+	for i := range actual {
+		if predicted[i] == actual[i] {
+			correct++
+		}
+	}
+	if !almostEqual(m.Accuracy, float64(correct)/float64(len(actual))) || m.Accuracy < 0.9 {
+		t.Errorf("expected the accuracy of the predictions, got %f", m.Accuracy)
+	}
+}
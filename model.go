@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// the binary model container layout is, all numbers little-endian:
+//
+//	magic    [4]byte "ICNN"
+//	version  uint8
+//	sections repeated until the checksum:
+//	  name   uint8 length followed by the name
+//	  data   uint64 length followed by the section data
+//	checksum uint32 crc32 (IEEE) of everything before it
+//
+// the weights are stored as binary matrices while the small sections, like the encoder, are JSON.
+// unknown sections are ignored when loading so newer files can still be read by older code
+const (
+	modelMagic   = "ICNN"
+	modelVersion = 1
+)
+
+// Save writes the net to fileName, files ending in .json are JSON encoded and all others use the
+// binary model container
+func Save(fileName string, t *NeuralNet) {
+	out_f, err := os.Create(fileName)
+	if err != nil {
+		panic("failed to dump the network to " + fileName)
+	}
+	defer out_f.Close()
+
+	if filepath.Ext(fileName) == ".json" {
+		encoder := json.NewEncoder(out_f)
+		if err = encoder.Encode(t); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	data, err := t.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	if _, err = out_f.Write(data); err != nil {
+		panic(err)
+	}
+}
+
+// Load reads a net saved by Save, the format is detected from the content rather than the file name
+func Load(fileName string) *NeuralNet {
+	in_f, err := os.Open(fileName)
+	if err != nil {
+		panic("failed to load " + fileName)
+	}
+	defer in_f.Close()
+
+	r := bufio.NewReader(in_f)
+	nn := &NeuralNet{}
+	if isBinaryModel(r) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			panic(err)
+		}
+		if err = nn.UnmarshalBinary(data); err != nil {
+			panic(err)
+		}
+		return nn
+	}
+
+	decoder := json.NewDecoder(r)
+	err = decoder.Decode(nn)
+	if err != nil {
+		panic(err)
+	}
+	return nn
+}
+
+func isBinaryModel(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(modelMagic))
+	return err == nil && string(magic) == modelMagic
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the model container format
+func (t *NeuralNet) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(modelMagic)
+	buf.WriteByte(modelVersion)
+
+	params := make([]byte, 24)
+	binary.LittleEndian.PutUint64(params[0:], uint64(t.HiddenNeurons))
+	binary.LittleEndian.PutUint64(params[8:], math.Float64bits(t.Alpha))
+	binary.LittleEndian.PutUint64(params[16:], math.Float64bits(t.Lambda))
+	writeSection(&buf, "params", params)
+	if t.Activation != Sigmoid {
+		activation, err := t.Activation.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("model: %s", err)
+		}
+		writeSection(&buf, "activation", activation)
+	}
+
+	weights := []struct {
+		name string
+		m    *Matrix
+	}{
+		{"W1", t.W1},
+		{"W2", t.W2},
+	}
+	for _, w := range weights {
+		if w.m == nil {
+			continue
+		}
+		data, err := w.m.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("model: %s: %s", w.name, err)
+		}
+		writeSection(&buf, w.name, data)
+	}
+
+	preprocessing := []struct {
+		name string
+		v    interface{}
+		set  bool
+	}{
+		{"encoder", t.Encoder, t.Encoder != nil},
+		{"imputer", t.Imputer, t.Imputer != nil},
+		{"normaliser", t.Normaliser, t.Normaliser != nil},
+		{"scaler", t.Scaler, t.Scaler != nil},
+		{"image_pipeline", t.ImagePipeline, t.ImagePipeline != nil},
+		{"classes", t.Classes, t.Classes != nil},
+	}
+	for _, p := range preprocessing {
+		if !p.set {
+			continue
+		}
+		data, err := json.Marshal(p.v)
+		if err != nil {
+			return nil, fmt.Errorf("model: %s: %s", p.name, err)
+		}
+		writeSection(&buf, p.name, data)
+	}
+
+	checksum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(checksum)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler for the model container format
+func (t *NeuralNet) UnmarshalBinary(data []byte) error {
+	sections, err := readSections(data)
+	if err != nil {
+		return err
+	}
+
+	params, ok := sections["params"]
+	if !ok || len(params) != 24 {
+		return fmt.Errorf("model: missing or invalid params section")
+	}
+	t.HiddenNeurons = int(binary.LittleEndian.Uint64(params[0:]))
+	t.Alpha = math.Float64frombits(binary.LittleEndian.Uint64(params[8:]))
+	t.Lambda = math.Float64frombits(binary.LittleEndian.Uint64(params[16:]))
+	t.Activation = Sigmoid
+	if data, ok := sections["activation"]; ok {
+		if err := t.Activation.UnmarshalText(data); err != nil {
+			return fmt.Errorf("model: %s", err)
+		}
+	}
+
+	for name, dst := range map[string]**Matrix{"W1": &t.W1, "W2": &t.W2} {
+		data, ok := sections[name]
+		if !ok {
+			continue
+		}
+		m := &Matrix{}
+		if err := m.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("model: %s: %s", name, err)
+		}
+		*dst = m
+	}
+
+	if data, ok := sections["encoder"]; ok {
+		t.Encoder = &TabularEncoder{}
+		if err := json.Unmarshal(data, t.Encoder); err != nil {
+			return fmt.Errorf("model: encoder: %s", err)
+		}
+	}
+	if data, ok := sections["imputer"]; ok {
+		t.Imputer = &Imputer{}
+		if err := json.Unmarshal(data, t.Imputer); err != nil {
+			return fmt.Errorf("model: imputer: %s", err)
+		}
+	}
+	if data, ok := sections["normaliser"]; ok {
+		t.Normaliser = &Normaliser{}
+		if err := json.Unmarshal(data, t.Normaliser); err != nil {
+			return fmt.Errorf("model: normaliser: %s", err)
+		}
+	}
+	if data, ok := sections["scaler"]; ok {
+		t.Scaler = &ScalerPipeline{}
+		if err := json.Unmarshal(data, t.Scaler); err != nil {
+			return fmt.Errorf("model: scaler: %s", err)
+		}
+	}
+	if data, ok := sections["image_pipeline"]; ok {
+		t.ImagePipeline = &ImagePipeline{}
+		if err := json.Unmarshal(data, t.ImagePipeline); err != nil {
+			return fmt.Errorf("model: image_pipeline: %s", err)
+		}
+	}
+	if data, ok := sections["classes"]; ok {
+		if err := json.Unmarshal(data, &t.Classes); err != nil {
+			return fmt.Errorf("model: classes: %s", err)
+		}
+	}
+	return nil
+}
+
+func writeSection(buf *bytes.Buffer, name string, data []byte) {
+	buf.WriteByte(byte(len(name)))
+	buf.WriteString(name)
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len(data)))
+	buf.Write(size)
+	buf.Write(data)
+}
+
+func readSections(data []byte) (map[string][]byte, error) {
+	if len(data) < len(modelMagic)+1+4 || string(data[:len(modelMagic)]) != modelMagic {
+		return nil, fmt.Errorf("model: not a binary model file")
+	}
+	if data[len(modelMagic)] != modelVersion {
+		return nil, fmt.Errorf("model: unsupported version %d", data[len(modelMagic)])
+	}
+	end := len(data) - 4
+	if crc32.ChecksumIEEE(data[:end]) != binary.LittleEndian.Uint32(data[end:]) {
+		return nil, fmt.Errorf("model: checksum mismatch, the file is corrupt")
+	}
+
+	sections := make(map[string][]byte)
+	pos := len(modelMagic) + 1
+	for pos < end {
+		nameLen := int(data[pos])
+		pos++
+		if pos+nameLen+8 > end {
+			return nil, fmt.Errorf("model: truncated section header at byte %d", pos)
+		}
+		name := string(data[pos : pos+nameLen])
+		pos += nameLen
+		size := binary.LittleEndian.Uint64(data[pos:])
+		pos += 8
+		if size > uint64(end-pos) {
+			return nil, fmt.Errorf("model: section %q is truncated", name)
+		}
+		sections[name] = data[pos : pos+int(size)]
+		pos += int(size)
+	}
+	return sections, nil
+}
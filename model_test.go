@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	nn := &NeuralNet{
+		HiddenNeurons: 3,
+		Alpha:         1e-3,
+		Lambda:        1e-2,
+		W1:            NewRandomMatrix(3, 5),
+		W2:            NewRandomMatrix(2, 4),
+		Classes:       []string{"cat", "dog"},
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"net.json", "net.bin"} {
+		fileName := filepath.Join(dir, name)
+		Save(fileName, nn)
+		actual := Load(fileName)
+
+		if actual.HiddenNeurons != nn.HiddenNeurons || actual.Alpha != nn.Alpha || actual.Lambda != nn.Lambda {
+			t.Errorf("%s: expected the hyper parameters to be restored, got %d %f %f", name, actual.HiddenNeurons, actual.Alpha, actual.Lambda)
+		}
+		if !actual.W1.Equals(nn.W1) || !actual.W2.Equals(nn.W2) {
+			t.Errorf("%s: expected the weights to be restored", name)
+		}
+		if len(actual.Classes) != 2 || actual.Classes[0] != "cat" || actual.Classes[1] != "dog" {
+			t.Errorf("%s: expected the class names to be restored, got %v", name, actual.Classes)
+		}
+	}
+
+	jsonInfo, _ := os.Stat(filepath.Join(dir, "net.json"))
+	binInfo, _ := os.Stat(filepath.Join(dir, "net.bin"))
+	if binInfo.Size() >= jsonInfo.Size() {
+		t.Errorf("expected the binary file (%d bytes) to be smaller than the JSON (%d bytes)", binInfo.Size(), jsonInfo.Size())
+	}
+}
+
+func TestNeuralNetUnmarshalCorrupt(t *testing.T) {
+	nn := &NeuralNet{HiddenNeurons: 1, W1: NewOnes(1, 2), W2: NewOnes(1, 2)}
+	data, err := nn.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := (&NeuralNet{}).UnmarshalBinary(data); err == nil {
+		t.Errorf("expected an error when loading a corrupt model")
+	}
+}
+
+func TestSaveLoadEncoder(t *testing.T) {
+	enc := NewTabularEncoder(encoderTestTable, 4)
+	if err := enc.Fit(encoderTestTable, []int{0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	nn := &NeuralNet{
+		HiddenNeurons: 2,
+		W1:            NewRandomMatrix(2, enc.Width()+1),
+		W2:            NewRandomMatrix(2, 3),
+		Encoder:       enc,
+	}
+	row := []string{"2", "red", "north", "a"}
+	expected, err := nn.PredictRaw(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"net.json", "net.bin"} {
+		fileName := filepath.Join(dir, name)
+		Save(fileName, nn)
+		actual, err := Load(fileName).PredictRaw(row)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if actual[0] != expected[0] {
+			t.Errorf("%s: expected the loaded net to predict %d, got %d", name, expected[0], actual[0])
+		}
+	}
+
+	if _, err := (&NeuralNet{}).PredictRaw(row); err == nil {
+		t.Errorf("expected an error when the net has no encoder")
+	}
+}
+
+func TestSaveLoadImputer(t *testing.T) {
+	nn := &NeuralNet{
+		HiddenNeurons: 2,
+		W1:            NewRandomMatrix(2, 5),
+		W2:            NewRandomMatrix(2, 3),
+		Imputer:       &Imputer{Strategy: ImputeMedian, Indicators: true},
+	}
+	if err := nn.Imputer.Fit([][]float64{{1, 2, 3}, {math.NaN(), 4, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	row := []float64{math.NaN(), 3, 3}
+
+	dir := t.TempDir()
+	for _, name := range []string{"net.json", "net.bin"} {
+		fileName := filepath.Join(dir, name)
+		Save(fileName, nn)
+		loaded := Load(fileName)
+		if loaded.Imputer == nil {
+			t.Fatalf("%s: the imputer wasn't saved", name)
+		}
+		input, err := loaded.Preprocess(row)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if len(input) != 4 || input[0] != 1 || input[3] != 1 {
+			t.Errorf("%s: unexpected preprocessed input %v", name, input)
+		}
+		if _, err := loaded.Predict(input); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+}
+
+func TestSaveLoadNormaliser(t *testing.T) {
+	nn := &NeuralNet{
+		HiddenNeurons: 2,
+		W1:            NewRandomMatrix(2, 3),
+		W2:            NewRandomMatrix(2, 3),
+		Imputer:       &Imputer{},
+		Normaliser:    &Normaliser{},
+	}
+	train := [][]float64{{1, 10}, {3, math.NaN()}, {5, 30}}
+	if err := nn.Imputer.Fit(train); err != nil {
+		t.Fatal(err)
+	}
+	filled, _ := nn.Imputer.Transform(train)
+	if err := nn.Normaliser.Fit(filled); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"net.json", "net.bin"} {
+		fileName := filepath.Join(dir, name)
+		Save(fileName, nn)
+		loaded := Load(fileName)
+		if loaded.Normaliser == nil {
+			t.Fatalf("%s: the normaliser wasn't saved", name)
+		}
+		// the missing value is filled in with the mean before it's normalised
+		input, err := loaded.Preprocess([]float64{5, math.NaN()})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if input[0] != 1 || input[1] != 0 {
+			t.Errorf("%s: unexpected preprocessed input %v", name, input)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bitbucket.org/binet/go-gnuplot/pkg/gnuplot"
 	"fmt"
+	"image"
 	"log"
 	"math"
 	"math/rand"
@@ -14,36 +15,144 @@ type NeuralNet struct {
 	HiddenNeurons int
 	Alpha         float64
 	Lambda        float64
+	// Activation of the hidden layer
+	Activation Activation `json:",omitempty"`
 
 	sync.Mutex
 	W1 *Matrix
 	W2 *Matrix
 
+	// Encoder turns raw tabular rows into the features the net was trained on
+	Encoder *TabularEncoder `json:",omitempty"`
+	// Imputer fills in missing features the same way as for the training data
+	Imputer *Imputer `json:",omitempty"`
+	// Normaliser scales the features with the statistics of the training data
+	Normaliser *Normaliser `json:",omitempty"`
+	// Scaler runs after the Normaliser for any further scaling of the features
+	Scaler *ScalerPipeline `json:",omitempty"`
+	// ImagePipeline turns images into features the same way as for the training data
+	ImagePipeline *ImagePipeline `json:",omitempty"`
+	// Classes are the names of the outputs in order, so the labels of new data can be matched
+	// by name
+	Classes []string `json:",omitempty"`
+
+	// GradClip limits every element of the gradients to [-GradClip, GradClip] before the weights
+	// are updated, 0 turns clipping off
+	GradClip float64 `json:"-"`
+	// OnNonFinite decides what training does when an epoch produces NaN or Inf values
+	OnNonFinite NonFinitePolicy `json:"-"`
+
 	numBatches int
 	numEpochs  int
 	log        bool
 	plot       bool
 	costPlot   *gnuplot.Plotter
+	batchSize  int
+	// augment changes the raw training images of every mini-batch before they are preprocessed,
+	// it's not used for the cost
+	augment *Augmenter
+	// keepWeights continues training from the current weights instead of random ones if they fit
+	keepWeights bool
+	// rng is the source of the initial weights and the batch shuffling, see random
+	rng *rand.Rand
+}
+
+// NonFinitePolicy decides what Train does when an epoch produces NaN or Inf values
+type NonFinitePolicy int
+
+const (
+	// AbortOnNonFinite restores the weights from before the offending epoch and returns an error
+	AbortOnNonFinite NonFinitePolicy = iota
+	// RollbackOnNonFinite discards the offending epoch and carries on training, it will still abort
+	// after maxRollbacks consecutive failed epochs
+	RollbackOnNonFinite
+)
+
+var nonFiniteNames = []string{"abort", "rollback"}
+
+func (p NonFinitePolicy) String() string {
+	if p < 0 || int(p) >= len(nonFiniteNames) {
+		return fmt.Sprintf("NonFinitePolicy(%d)", int(p))
+	}
+	return nonFiniteNames[p]
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (p NonFinitePolicy) MarshalText() ([]byte, error) {
+	if p < 0 || int(p) >= len(nonFiniteNames) {
+		return nil, fmt.Errorf("unknown non finite policy %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, so the policy can be parsed from a flag
+func (p *NonFinitePolicy) UnmarshalText(text []byte) error {
+	for i, name := range nonFiniteNames {
+		if name == string(text) {
+			*p = NonFinitePolicy(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown non finite policy %q, use abort or rollback", text)
+}
+
+// maxRollbacks is how many epochs in a row that can be rolled back before Train gives up
+const maxRollbacks = 10
+
+// NonFiniteError is returned by Train when the weights, gradients or cost becomes NaN or Inf
+type NonFiniteError struct {
+	Epoch int
+	What  string
+}
+
+func (e *NonFiniteError) Error() string {
+	return fmt.Sprintf("epoch %d: %s contains NaN or Inf values, try lowering alpha or setting GradClip", e.Epoch, e.What)
 }
 
 // @todo add more depth with convnets for image processing
 // @todo use all CPU cores
 // @todo link with a proper C lib for faster linear algebra (e.g. https://github.com/gonum/blas)
-func (t *NeuralNet) Train(xTr, yTr, xCv, yCv [][]float64) (float64, float64) {
+func (t *NeuralNet) Train(xTr, yTr, xCv, yCv [][]float64) (float64, float64, error) {
+	return t.TrainDataset(&SliceDataset{X: xTr, Y: yTr}, &SliceDataset{X: xCv, Y: yCv})
+}
+
+// TrainSparse is the same as Train but for inputs where most features are zero, only the non zero
+// values are multiplied with the first layer weights
+func (t *NeuralNet) TrainSparse(xTr []SparseVector, yTr [][]float64, xCv []SparseVector, yCv [][]float64) (float64, float64, error) {
+	sTr, err := NewSparseMatrix(xTr)
+	if err != nil {
+		return 0, 0, err
+	}
+	sCv, err := NewSparseMatrix(xCv)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.TrainDataset(&sparseDataset{x: sTr, y: yTr}, &sparseDataset{x: sCv, y: yCv})
+}
+
+// TrainDataset is the same as Train but reads the examples from the datasets as they are needed.
+// If batchSize is set only that many examples are decoded at a time, otherwise every example is
+// used for each update. The validation cost is skipped, and returned as 0, when cvSet is empty.
+func (t *NeuralNet) TrainDataset(trSet, cvSet Dataset) (float64, float64, error) {
+	if trSet.Len() == 0 {
+		return 0, 0, fmt.Errorf("there are no training examples")
+	}
+	validate := cvSet.Len() > 0
 
 	if t.plot {
 		t.initPlots()
 		defer t.costPlot.Close()
 	}
 
-	inputNeurons := len(xTr[0])
-	outputNeurons := len(yTr[0])
+	inputNeurons, outputNeurons := trSet.Dims()
 
-	// initialize parameters (weights) randomly for input -> hidden layer
-	t.W1 = NewRandomMatrix(t.HiddenNeurons, inputNeurons+1).ScalarMul(0.12)
+	if !t.keepWeights || !t.weightsFit(inputNeurons, outputNeurons) {
+		// initialize parameters (weights) randomly for input -> hidden layer
+		t.W1 = t.randomMatrix(t.HiddenNeurons, inputNeurons+1).ScalarMul(0.12)
 
-	// initialize parameters (weights) randomly for hidden-> output layer
-	t.W2 = NewRandomMatrix(outputNeurons, t.HiddenNeurons+1).ScalarMul(0.12)
+		// initialize parameters (weights) randomly for hidden-> output layer
+		t.W2 = t.randomMatrix(outputNeurons, t.HiddenNeurons+1).ScalarMul(0.12)
+	}
 
 	// these are used so that we can update gnuplots with the data
 	var (
@@ -56,50 +165,56 @@ func (t *NeuralNet) Train(xTr, yTr, xCv, yCv [][]float64) (float64, float64) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	var wg sync.WaitGroup
+	var rollbacks int
 
 	for epoch := 1; epoch < t.numEpochs+1; epoch++ {
-		xBatches, yBatches := t.randomisedBatches(t.numBatches, xTr, yTr)
-		dW1 := NewZeros(t.W1.Rows, t.W1.Cols)
-		dW2 := NewZeros(t.W2.Rows, t.W2.Cols)
-
-		aChan := make(chan *Matrix, len(xBatches))
-		bChan := make(chan *Matrix, len(xBatches))
-
-		for i := range xBatches {
-			// calculate each batch in it's own go routine so we utilize as many CPU resources as possible
-			wg.Add(1)
-			go func(idx int) {
-				_, a, b := t.costFunction(xBatches[idx], yBatches[idx], t.Lambda)
-				aChan <- a
-				bChan <- b
-			}(i)
-			// drain each batch in it's own go routine
-			go func() {
-				dW1 = dW1.Add(<-aChan)
-				dW2 = dW2.Add(<-bChan)
-				wg.Done()
-			}()
+		// the weights from before the epoch are kept so that a non finite epoch can be undone
+		prevW1, prevW2 := t.W1, t.W2
+
+		err := t.epoch(epoch, trSet)
+		if _, ok := err.(*NonFiniteError); ok {
+			t.W1, t.W2 = prevW1, prevW2
+			rollbacks++
+			if t.OnNonFinite != RollbackOnNonFinite || rollbacks > maxRollbacks {
+				return 0, 0, err
+			}
+			if t.log {
+				log.Printf("rolling back %s", err)
+			}
+			continue
+		} else if err != nil {
+			return 0, 0, err
 		}
-		wg.Wait()
-
-		// parameter updates
-		t.W2 = t.W2.Sub(dW2.ScalarMul(t.Alpha))
-		t.W1 = t.W1.Sub(dW1.ScalarMul(t.Alpha))
+		rollbacks = 0
 
 		select {
 		case <-ticker.C:
 
-			jTrain, _, _ := t.costFunction(NewMatrix(xTr), NewMatrix(yTr), 0)
+			jTrain, err := t.cost(trSet)
+			if err != nil {
+				return 0, 0, err
+			}
+			if !isFinite(jTrain) {
+				return 0, 0, &NonFiniteError{Epoch: epoch, What: "training cost"}
+			}
 			trainingCosts = append(trainingCosts, jTrain)
 			trainingEpochs = append(trainingEpochs, float64(epoch))
 
-			jValidation, _, _ := t.costFunction(NewMatrix(xCv), NewMatrix(yCv), 0)
+			if !validate {
+				if t.log {
+					log.Printf("epoch %d:\t%f", epoch, jTrain)
+				}
+				break
+			}
+			jValidation, err := t.cost(cvSet)
+			if err != nil {
+				return 0, 0, err
+			}
 			validationCosts = append(validationCosts, jValidation)
 			validationEpochs = append(validationEpochs, float64(epoch))
 
 			if t.log {
-				log.Printf("epoch %d:\t%f\t%f", epoch, trainingCosts[len(trainingCosts)-1], validationCosts[len(validationCosts)-1])
+				log.Printf("epoch %d:\t%f\t%f", epoch, jTrain, jValidation)
 			}
 			if t.plot {
 				t.plotCost(trainingCosts, trainingEpochs, validationCosts, validationEpochs)
@@ -108,27 +223,268 @@ func (t *NeuralNet) Train(xTr, yTr, xCv, yCv [][]float64) (float64, float64) {
 		}
 	}
 
-	jTrain, _, _ := t.costFunction(NewMatrix(xTr), NewMatrix(yTr), 0)
+	jTrain, err := t.cost(trSet)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isFinite(jTrain) {
+		return 0, 0, &NonFiniteError{Epoch: t.numEpochs, What: "training cost"}
+	}
 	trainingCosts = append(trainingCosts, jTrain)
+	if !validate {
+		return jTrain, 0, nil
+	}
 
 	// check the cost for the validation set
-	jValidation, _, _ := t.costFunction(NewMatrix(xCv), NewMatrix(yCv), 0)
+	jValidation, err := t.cost(cvSet)
+	if err != nil {
+		return 0, 0, err
+	}
 	validationCosts = append(validationCosts, jValidation)
 
 	if len(validationCosts) != 0 && len(trainingCosts) != 0 && t.plot {
 		t.plotCost(trainingCosts, trainingEpochs, validationCosts, validationEpochs)
 	}
-	return trainingCosts[len(trainingCosts)-1], validationCosts[len(validationCosts)-1]
+	return jTrain, jValidation, nil
 }
 
-func (t *NeuralNet) Predict(input []float64) []int {
-	xTe := NewMatrixF(input, 1, len(input))
-	a1 := xTe.AddBias()
-	z2 := a1.Dot(t.W1.T())
-	a2 := t.sigmoid(z2).AddBias()
-	z3 := a2.Dot(t.W2.T())
-	a3 := t.sigmoid(z3)
-	return a3.ArgMax()
+// weightsFit checks that the weights are set and have the shapes for the number of neurons
+func (t *NeuralNet) weightsFit(inputNeurons, outputNeurons int) bool {
+	return t.W1 != nil && t.W2 != nil &&
+		t.W1.Rows == t.HiddenNeurons && t.W1.Cols == inputNeurons+1 &&
+		t.W2.Rows == outputNeurons && t.W2.Cols == t.HiddenNeurons+1
+}
+
+// epoch makes one pass over the training set and updates the weights after every mini-batch
+func (t *NeuralNet) epoch(epoch int, set Dataset) error {
+	for _, idx := range t.miniBatches(set.Len()) {
+		dW1, dW2, err := t.gradients(set, idx)
+		if err != nil {
+			return err
+		}
+
+		if t.GradClip > 0 {
+			dW1 = dW1.Clip(-t.GradClip, t.GradClip)
+			dW2 = dW2.Clip(-t.GradClip, t.GradClip)
+		}
+
+		// parameter updates
+		t.W2 = t.W2.MustSub(dW2.ScalarMul(t.Alpha))
+		t.W1 = t.W1.MustSub(dW1.ScalarMul(t.Alpha))
+
+		if err := t.checkFinite(epoch, dW1, dW2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// random returns the source of the random numbers of the net. A net without its own seeded source
+// gets one seeded from the global source, so rand.Seed still decides how it's trained.
+func (t *NeuralNet) random() *rand.Rand {
+	if t.rng == nil {
+		t.rng = rand.New(rand.NewSource(rand.Int63()))
+	}
+	return t.rng
+}
+
+// randomMatrix returns a matrix of normally distributed values from the source of the net
+func (t *NeuralNet) randomMatrix(rows, cols int) *Matrix {
+	m := NewZeros(rows, cols)
+	rng := t.random()
+	for i := range m.Data {
+		m.Data[i] = rng.NormFloat64()
+	}
+	return m
+}
+
+// miniBatches shuffles the indices of n examples and splits them into batches of batchSize, if
+// batchSize isn't set all examples are in the same batch
+func (t *NeuralNet) miniBatches(n int) [][]int {
+	perm := t.random().Perm(n)
+	if t.batchSize <= 0 || t.batchSize >= n {
+		return [][]int{perm}
+	}
+	var batches [][]int
+	for start := 0; start < n; start += t.batchSize {
+		end := start + t.batchSize
+		if end > n {
+			end = n
+		}
+		batches = append(batches, perm[start:end])
+	}
+	return batches
+}
+
+// gradients returns the sum of the gradients for the examples in idx, which are split over
+// numBatches go routines
+func (t *NeuralNet) gradients(set Dataset, idx []int) (dW1, dW2 *Matrix, err error) {
+	xBatches, yBatches, err := t.trainingBatches(set, idx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dW1 = NewZeros(t.W1.Rows, t.W1.Cols)
+	dW2 = NewZeros(t.W2.Rows, t.W2.Cols)
+
+	aChan := make(chan *Matrix, len(xBatches))
+	bChan := make(chan *Matrix, len(xBatches))
+
+	for i := range xBatches {
+		// calculate each batch in it's own go routine so we utilize as many CPU resources as possible
+		go func(idx int) {
+			_, a, b := t.costFunction(xBatches[idx], yBatches[idx], t.Lambda)
+			aChan <- a
+			bChan <- b
+		}(i)
+	}
+	for range xBatches {
+		dW1 = dW1.MustAdd(<-aChan)
+		dW2 = dW2.MustAdd(<-bChan)
+	}
+	return dW1, dW2, nil
+}
+
+// trainingBatches deals the examples at idx into numBatches batches and augments them. The
+// augmenter works on raw images, so the examples of a Preprocessed set are read raw and only
+// preprocessed after they are augmented.
+func (t *NeuralNet) trainingBatches(set Dataset, idx []int) (X []features, Y []*Matrix, err error) {
+	if t.augment == nil {
+		return t.randomisedBatches(t.numBatches, set, idx)
+	}
+	pre, preprocessed := set.(*preprocessedDataset)
+	if preprocessed {
+		set = pre.set
+	}
+	if X, Y, err = t.randomisedBatches(t.numBatches, set, idx); err != nil {
+		return nil, nil, err
+	}
+	for i, x := range X {
+		m, ok := x.(*Matrix)
+		if !ok {
+			return nil, nil, fmt.Errorf("augmentation needs dense features")
+		}
+		if err := t.augment.AugmentBatch(m); err != nil {
+			return nil, nil, err
+		}
+		if preprocessed {
+			if X[i], err = pre.transformBatch(m); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return X, Y, nil
+}
+
+// checkFinite ensures that neither the gradients nor the updated weights have blown up
+func (t *NeuralNet) checkFinite(epoch int, dW1, dW2 *Matrix) error {
+	switch {
+	case !dW1.IsFinite():
+		return &NonFiniteError{Epoch: epoch, What: "gradient W1"}
+	case !dW2.IsFinite():
+		return &NonFiniteError{Epoch: epoch, What: "gradient W2"}
+	case !t.W1.IsFinite():
+		return &NonFiniteError{Epoch: epoch, What: "W1"}
+	case !t.W2.IsFinite():
+		return &NonFiniteError{Epoch: epoch, What: "W2"}
+	}
+	return nil
+}
+
+// Predict returns the index of the most likely class for the input, an error is returned if the
+// input doesn't have the same number of features that the net was trained on. PredictProbabilities
+// and PredictTopK keep the scores of the classes.
+func (t *NeuralNet) Predict(input []float64) ([]int, error) {
+	return t.predict(MustNewMatrixF(input, 1, len(input)))
+}
+
+// PredictRaw encodes the raw fields of a row with the Encoder and runs Preprocess before
+// predicting, missing fields should be ""
+func (t *NeuralNet) PredictRaw(fields []string) ([]int, error) {
+	if t.Encoder == nil {
+		return nil, fmt.Errorf("the net has no encoder for raw input")
+	}
+	input, err := t.Encoder.Encode(fields)
+	if err != nil {
+		return nil, err
+	}
+	if input, err = t.Preprocess(input); err != nil {
+		return nil, err
+	}
+	return t.Predict(input)
+}
+
+// PredictImage runs a decoded image through the ImagePipeline and Preprocess before predicting
+func (t *NeuralNet) PredictImage(img image.Image) ([]int, error) {
+	if t.ImagePipeline == nil {
+		return nil, fmt.Errorf("the net has no image pipeline")
+	}
+	tensor, err := t.ImagePipeline.Transform(img)
+	if err != nil {
+		return nil, err
+	}
+	input, err := t.Preprocess(tensor.Data)
+	if err != nil {
+		return nil, err
+	}
+	return t.Predict(input)
+}
+
+// Preprocess applies the preprocessing that was fitted on the training data to a new example,
+// the result can be passed to Predict
+func (t *NeuralNet) Preprocess(input []float64) ([]float64, error) {
+	var err error
+	if t.Imputer != nil {
+		if input, err = t.Imputer.TransformRow(input); err != nil {
+			return nil, err
+		}
+	}
+	if t.Normaliser != nil {
+		if input, err = t.Normaliser.TransformRow(input); err != nil {
+			return nil, err
+		}
+	}
+	if t.Scaler != nil {
+		if input, err = t.Scaler.TransformRow(input); err != nil {
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+// PredictSparse is the same as Predict but for a sparse input
+func (t *NeuralNet) PredictSparse(input SparseVector) ([]int, error) {
+	xTe, err := NewSparseMatrix([]SparseVector{input})
+	if err != nil {
+		return nil, err
+	}
+	return t.predict(xTe)
+}
+
+func (t *NeuralNet) predict(xTe features) ([]int, error) {
+	a3, err := t.forward(xTe)
+	if err != nil {
+		return nil, err
+	}
+	return a3.ArgMax(), nil
+}
+
+// forward returns the output layer activations, a score between 0 and 1 for each class, with a row
+// per example
+func (t *NeuralNet) forward(xTe features) (*Matrix, error) {
+	if t.W1 == nil || t.W2 == nil {
+		return nil, fmt.Errorf("the net has no weights, train or load it first")
+	}
+	if _, cols := xTe.dims(); cols+1 != t.W1.Cols {
+		return nil, &ShapeError{Op: "Predict", ARows: 1, ACols: cols + 1, BRows: t.W1.Cols, BCols: t.W1.Rows}
+	}
+	z2 := xTe.biasDotT(t.W1)
+	a2 := t.Activation.apply(z2).AddBias()
+	z3, err := a2.Dot(t.W2.T())
+	if err != nil {
+		return nil, err
+	}
+	return t.sigmoid(z3), nil
 }
 
 func (t *NeuralNet) Divide(xIn [][]float64, yIn [][]float64) (x, y, xPred, yPred [][]float64) {
@@ -140,7 +496,7 @@ func (t *NeuralNet) Divide(xIn [][]float64, yIn [][]float64) (x, y, xPred, yPred
 	return x, y, xPred, yPred
 }
 
-func (t *NeuralNet) costFunction(x, y *Matrix, lambda float64) (J float64, gradW1 *Matrix, gradW2 *Matrix) {
+func (t *NeuralNet) costFunction(x features, y *Matrix, lambda float64) (J float64, gradW1 *Matrix, gradW2 *Matrix) {
 
 	gradW1 = NewZeros(t.W1.Rows, t.W1.Cols)
 	gradW2 = NewZeros(t.W2.Rows, t.W2.Cols)
@@ -150,86 +506,196 @@ func (t *NeuralNet) costFunction(x, y *Matrix, lambda float64) (J float64, gradW
 	W2 := t.W2.Clone()
 	t.Unlock()
 
-	// input
-	a1 := x.AddBias()
-	z2 := a1.Dot(W1.T())
-	a2 := t.sigmoid(z2).AddBias()
-	z3 := a2.Dot(W2.T())
+	// input, the bias column is added by the features themselves
+	z2 := x.biasDotT(W1)
+	a2 := t.Activation.apply(z2).AddBias()
+	z3 := a2.MustDot(W2.T())
 	a3 := t.sigmoid(z3)
 
-	J1 := y.ScalarMul(-1).ElementMul(a3.ElementLog())
-
-	ones1 := NewOnes(y.Rows, y.Cols)
-	ones2 := NewOnes(a3.Rows, a3.Cols)
-	J2 := ones1.Sub(y).ElementMul(ones2.Sub(a3).ElementLog())
+	// the cross entropy is calculated from the logits z3 instead of log(a3) and log(1-a3), since
+	// log(sigmoid(z)) = -softplus(-z) and log(1-sigmoid(z)) = -softplus(z) this never takes log(0)
+	// when the sigmoid saturates
+	var crossEntropy float64
+	for i, z := range z3.Data {
+		crossEntropy += y.Data[i]*softplus(-z) + (1-y.Data[i])*softplus(z)
+	}
 
 	Jreg1 := W1.RemoveBias().ElementSquare().Sum()
 	Jreg2 := W2.RemoveBias().ElementSquare().Sum()
 
-	m := float64(x.Rows)
-	J = (J1.Sub(J2).Sum() / m) + (lambda * (Jreg1 + Jreg2) / (2 * m))
+	m := float64(y.Rows)
+	J = (crossEntropy / m) + (lambda * (Jreg1 + Jreg2) / (2 * m))
 
-	d3 := a3.Sub(y)
-	d2 := d3.Dot(W2.RemoveBias()).ElementMul(t.sigmoidPrime(z2))
+	d3 := a3.MustSub(y)
+	d2 := d3.MustDot(W2.RemoveBias()).MustElementMul(t.Activation.prime(z2))
 
-	gradW1 = d2.T().Dot(a1).ScalarDiv(m)
-	gradW2 = d3.T().Dot(a2).ScalarDiv(m)
+	gradW1 = x.tDotBias(d2).ScalarDiv(m)
+	gradW2 = d3.T().MustDot(a2).ScalarDiv(m)
 
 	// add regularisation to gradients
 	if lambda != 0 {
-		gradW1Reg := W1.ZeroBias().ScalarDiv(lambda / m)
-		gradW2Reg := W2.ZeroBias().ScalarDiv(lambda / m)
-		gradW1.Add(gradW1Reg)
-		gradW2.Add(gradW2Reg)
+		gradW1 = gradW1.MustAdd(W1.ZeroBias().ScalarMul(lambda / m))
+		gradW2 = gradW2.MustAdd(W2.ZeroBias().ScalarMul(lambda / m))
 	}
 
 	return J, gradW1, gradW2
 }
 
-func (t *NeuralNet) randomisedBatches(batchSize int, xAll [][]float64, yAll [][]float64) (X, Y []*Matrix) {
-	for i := range xAll {
-		j := rand.Intn(i + 1)
-		xAll[i], xAll[j] = xAll[j], xAll[i]
-		yAll[i], yAll[j] = yAll[j], yAll[i]
+// costChunkSize is how many examples cost decodes at a time
+const costChunkSize = 1024
+
+// cost returns the unregularised cost over every example in the set, it's calculated in chunks so
+// that large datasets don't have to fit in memory
+func (t *NeuralNet) cost(set Dataset) (float64, error) {
+	var total float64
+	n := set.Len()
+	if n == 0 {
+		return 0, fmt.Errorf("there are no examples to compute the cost of")
+	}
+	for start := 0; start < n; start += costChunkSize {
+		end := start + costChunkSize
+		if end > n {
+			end = n
+		}
+		idx := make([]int, end-start)
+		for i := range idx {
+			idx[i] = start + i
+		}
+		x, y, err := batchOf(set, idx)
+		if err != nil {
+			return 0, err
+		}
+		J, _, _ := t.costFunction(x, y, 0)
+		total += J * float64(len(idx))
 	}
+	return total / float64(n), nil
+}
 
-	xBatch := make([][][]float64, batchSize)
-	yBatch := make([][][]float64, batchSize)
+// PredictDataset returns the predicted and the actual class of every example in the set, it works
+// in chunks like cost
+func (t *NeuralNet) PredictDataset(set Dataset) (predicted, actual []int, err error) {
+	scores, actual, err := t.ScoreDataset(set)
+	if err != nil {
+		return nil, nil, err
+	}
+	predicted = make([]int, len(scores))
+	for i := range scores {
+		predicted[i] = argMax(scores[i])
+	}
+	return predicted, actual, nil
+}
 
-	for i := range xAll {
-		bIdx := rand.Intn(batchSize)
-		xBatch[bIdx] = append(xBatch[bIdx], xAll[i])
-		yBatch[bIdx] = append(yBatch[bIdx], yAll[i])
+// ScoreDataset returns the output of the net, a score between 0 and 1 for each class, and the
+// actual class of every example in the set. It works in chunks like cost.
+func (t *NeuralNet) ScoreDataset(set Dataset) (scores [][]float64, actual []int, err error) {
+	n := set.Len()
+	for start := 0; start < n; start += costChunkSize {
+		end := start + costChunkSize
+		if end > n {
+			end = n
+		}
+		idx := make([]int, end-start)
+		for i := range idx {
+			idx[i] = start + i
+		}
+		x, y, err := batchOf(set, idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		a3, err := t.forward(x)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < a3.Rows; i++ {
+			scores = append(scores, a3.Data[i*a3.Cols:(i+1)*a3.Cols])
+		}
+		actual = append(actual, y.ArgMax()...)
 	}
+	return scores, actual, nil
+}
 
-	for i := range xBatch {
-		if len(xBatch[i]) == 0 {
-			continue
+// ClassIndices returns the output of the net for each of the class names, so the labels of a set
+// can be matched with the classes the net was trained on even when the set has them in another
+// order or only some of them. A net without Classes is assumed to have the same order.
+func (t *NeuralNet) ClassIndices(names []string) ([]int, error) {
+	idx := make([]int, len(names))
+	if len(t.Classes) == 0 {
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx, nil
+	}
+	outputs := make(map[string]int, len(t.Classes))
+	for i, name := range t.Classes {
+		outputs[name] = i
+	}
+	for i, name := range names {
+		c, ok := outputs[name]
+		if !ok {
+			return nil, fmt.Errorf("the net wasn't trained on the class %q", name)
 		}
-		X = append(X, NewMatrix(xBatch[i]))
+		idx[i] = c
+	}
+	return idx, nil
+}
+
+// randomisedBatches deals the examples at idx randomly into numBatches batches
+func (t *NeuralNet) randomisedBatches(numBatches int, set Dataset, idx []int) (X []features, Y []*Matrix, err error) {
+	batches := make([][]int, numBatches)
+	for _, row := range idx {
+		bIdx := t.random().Intn(numBatches)
+		batches[bIdx] = append(batches[bIdx], row)
 	}
 
-	for i := range yBatch {
-		if len(yBatch[i]) == 0 {
+	for i := range batches {
+		if len(batches[i]) == 0 {
 			continue
 		}
-		Y = append(Y, NewMatrix(yBatch[i]))
+		x, y, err := batchOf(set, batches[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		X = append(X, x)
+		Y = append(Y, y)
 	}
-
-	return X, Y
+	return X, Y, nil
 }
 
 func (t *NeuralNet) sigmoid(A *Matrix) *Matrix {
 	res := A.Clone()
 	for i := range A.Data {
-		res.Data[i] = 1.0 / (1.0 + math.Exp(-res.Data[i]))
+		res.Data[i] = sigmoid(res.Data[i])
 	}
 	return res
 }
 
-func (t *NeuralNet) sigmoidPrime(A *Matrix) *Matrix {
-	ones := NewOnes(A.Rows, A.Cols)
-	return t.sigmoid(A).ElementMul(ones.Sub(t.sigmoid(A)))
+// sigmoid only ever calls math.Exp with a non positive argument so it can't overflow
+func sigmoid(x float64) float64 {
+	if x >= 0 {
+		return 1.0 / (1.0 + math.Exp(-x))
+	}
+	e := math.Exp(x)
+	return e / (1.0 + e)
+}
+
+// softplus is log(1 + e^x) calculated as the log-sum-exp of 0 and x
+func softplus(x float64) float64 {
+	return logSumExp(0, x)
+}
+
+// logSumExp calculates log(e^a + e^b) without overflowing by factoring out the largest exponent
+func logSumExp(a, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+	if math.IsInf(a, -1) {
+		return a
+	}
+	return a + math.Log1p(math.Exp(b-a))
+}
+
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
 }
 
 // setup gnuplot for plotting the loss and accuracy (brew install gnuplot)
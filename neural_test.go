@@ -1,6 +1,9 @@
 package main
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 var trailResult *Matrix
 
@@ -35,7 +38,11 @@ func BenchmarkTrailGradientDescent(b *testing.B) {
 	// initialize parameters (weights) randomly for hidden-> output layer
 	neuro.W2 = NewRandomMatrix(2, neuro.HiddenNeurons+1).ScalarMul(0.12)
 
-	xBatches, yBatches := neuro.randomisedBatches(1, trX, trY)
+	set := &SliceDataset{X: trX, Y: trY}
+	xBatches, yBatches, err := neuro.randomisedBatches(1, set, allRows(set))
+	if err != nil {
+		b.Fatal(err)
+	}
 
 	var catch *Matrix
 	for i := 0; i < b.N; i++ {
@@ -75,3 +82,200 @@ func BenchmarkTrailTrain(b *testing.B) {
 	}
 	trailResult = catch
 }
+
+func TestSigmoidSaturated(t *testing.T) {
+	neuro := &NeuralNet{}
+	A := MustNewMatrixF([]float64{-1000, -40, 0, 40, 1000}, 1, 5)
+	actual := neuro.sigmoid(A)
+	if !actual.IsFinite() {
+		t.Errorf("expected sigmoid to be finite for large inputs")
+		actual.Print()
+	}
+	if actual.At(0, 0) != 0 {
+		t.Errorf("expected sigmoid(-1000) to be 0, got %f", actual.At(0, 0))
+	}
+	if actual.At(0, 2) != 0.5 {
+		t.Errorf("expected sigmoid(0) to be 0.5, got %f", actual.At(0, 2))
+	}
+	if actual.At(0, 4) != 1 {
+		t.Errorf("expected sigmoid(1000) to be 1, got %f", actual.At(0, 4))
+	}
+}
+
+func TestSoftplus(t *testing.T) {
+	if softplus(1000) != 1000 {
+		t.Errorf("expected softplus(1000) to be 1000, got %f", softplus(1000))
+	}
+	if softplus(-1000) != 0 {
+		t.Errorf("expected softplus(-1000) to be 0, got %f", softplus(-1000))
+	}
+	if math.Abs(softplus(0)-math.Ln2) > 1e-12 {
+		t.Errorf("expected softplus(0) to be ln(2), got %f", softplus(0))
+	}
+}
+
+func TestCostFunctionSaturated(t *testing.T) {
+	neuro := &NeuralNet{HiddenNeurons: 1}
+	// weights large enough to saturate every sigmoid with the wrong answer
+	neuro.W1 = MustNewMatrixF([]float64{0, 1000}, 1, 2)
+	neuro.W2 = MustNewMatrixF([]float64{0, 1000, 0, -1000}, 2, 2)
+
+	x := MustNewMatrixF([]float64{1}, 1, 1)
+	y := MustNewMatrixF([]float64{0, 1}, 1, 2)
+
+	J, gradW1, gradW2 := neuro.costFunction(x, y, 0)
+	if !isFinite(J) {
+		t.Errorf("expected a finite cost, got %f", J)
+	}
+	if math.Abs(J-2000) > 1e-6 {
+		t.Errorf("expected a cost of 2000, got %f", J)
+	}
+	if !gradW1.IsFinite() || !gradW2.IsFinite() {
+		t.Errorf("expected finite gradients")
+	}
+}
+
+func TestCostFunctionRegularisation(t *testing.T) {
+	neuro := &NeuralNet{HiddenNeurons: 1}
+	neuro.W1 = MustNewMatrixF([]float64{0.5, 2}, 1, 2)
+	neuro.W2 = MustNewMatrixF([]float64{0.5, 3}, 1, 2)
+	x := MustNewMatrixF([]float64{1, 2}, 2, 1)
+	y := MustNewMatrixF([]float64{1, 0}, 2, 1)
+
+	_, gradW1, gradW2 := neuro.costFunction(x, y, 0)
+	_, regW1, regW2 := neuro.costFunction(x, y, 1)
+	// lambda / m times the weights is added to the gradients, except for the bias
+	if !almostEqual(regW1.Data[0], gradW1.Data[0]) || !almostEqual(regW1.Data[1], gradW1.Data[1]+2.0/2) {
+		t.Errorf("expected W1 to be regularised, got %v and %v", gradW1.Data, regW1.Data)
+	}
+	if !almostEqual(regW2.Data[0], gradW2.Data[0]) || !almostEqual(regW2.Data[1], gradW2.Data[1]+3.0/2) {
+		t.Errorf("expected W2 to be regularised, got %v and %v", gradW2.Data, regW2.Data)
+	}
+}
+
+func TestTrainNonFinite(t *testing.T) {
+	trX := [][]float64{
+		[]float64{0, 1},
+		[]float64{1, 0},
+	}
+	trY := [][]float64{
+		[]float64{1, 0},
+		[]float64{0, 1},
+	}
+
+	neuro := &NeuralNet{
+		HiddenNeurons: 2,
+		Alpha:         math.Inf(1),
+		numBatches:    1,
+		numEpochs:     5,
+	}
+
+	_, _, err := neuro.Train(trX, trY, trX, trY)
+	if err == nil {
+		t.Fatalf("expected an error when alpha is Inf")
+	}
+	if _, ok := err.(*NonFiniteError); !ok {
+		t.Errorf("expected a *NonFiniteError, got %T", err)
+	}
+	if !neuro.W1.IsFinite() || !neuro.W2.IsFinite() {
+		t.Errorf("expected the weights to be rolled back to finite values")
+	}
+
+	neuro.OnNonFinite = RollbackOnNonFinite
+	if _, _, err = neuro.Train(trX, trY, trX, trY); err != nil {
+		t.Errorf("expected the non finite epochs to be rolled back, got %s", err)
+	}
+}
+
+func TestTrainRollback(t *testing.T) {
+	set := &SliceDataset{X: [][]float64{{0, 1}, {1, 0}}, Y: [][]float64{{1, 0}, {0, 1}}}
+	neuro := &NeuralNet{
+		HiddenNeurons: 2,
+		Alpha:         math.NaN(),
+		OnNonFinite:   RollbackOnNonFinite,
+		numBatches:    1,
+		numEpochs:     3,
+		keepWeights:   true,
+	}
+	W1, W2 := NewRandomMatrix(2, 3), NewRandomMatrix(2, 3)
+	neuro.W1, neuro.W2 = W1, W2
+	if _, _, err := neuro.TrainDataset(set, set); err != nil {
+		t.Fatal(err)
+	}
+	if !neuro.W1.Equals(W1) || !neuro.W2.Equals(W2) {
+		t.Errorf("expected every NaN epoch to be rolled back to the weights from before it")
+	}
+
+	neuro.numEpochs = maxRollbacks + 2
+	if _, _, err := neuro.TrainDataset(set, set); err == nil {
+		t.Errorf("expected an error after %d epochs in a row were rolled back", maxRollbacks)
+	}
+}
+
+func TestTrainGradClip(t *testing.T) {
+	set := &SliceDataset{X: [][]float64{{0, 100}, {100, 0}}, Y: [][]float64{{1, 0}, {0, 1}}}
+	neuro := &NeuralNet{HiddenNeurons: 2, Alpha: 10, GradClip: 0.01, numBatches: 1}
+	neuro.W1, neuro.W2 = NewRandomMatrix(2, 3), NewRandomMatrix(2, 3)
+	W1, W2 := neuro.W1, neuro.W2
+	if err := neuro.epoch(1, set); err != nil {
+		t.Fatal(err)
+	}
+	// no weight can move more than alpha times the clip
+	for _, pair := range [][2]*Matrix{{W1, neuro.W1}, {W2, neuro.W2}} {
+		for i := range pair[0].Data {
+			if diff := math.Abs(pair[1].Data[i] - pair[0].Data[i]); diff > 0.1+1e-12 {
+				t.Errorf("expected the update to be clipped to 0.1, got %f", diff)
+			}
+		}
+	}
+}
+
+func TestNonFinitePolicyText(t *testing.T) {
+	var p NonFinitePolicy
+	if err := p.UnmarshalText([]byte("rollback")); err != nil || p != RollbackOnNonFinite {
+		t.Errorf("expected rollback, got %s and %v", p, err)
+	}
+	if err := p.UnmarshalText([]byte("ignore")); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
+
+func TestPredictWrongInputSize(t *testing.T) {
+	neuro := &NeuralNet{HiddenNeurons: 2}
+	neuro.W1 = NewRandomMatrix(2, 3)
+	neuro.W2 = NewRandomMatrix(2, 3)
+
+	if _, err := neuro.Predict([]float64{1, 2}); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if _, err := neuro.Predict([]float64{1, 2, 3}); err == nil {
+		t.Errorf("expected an error when predicting with the wrong number of features")
+	}
+}
+
+func TestPredictUntrained(t *testing.T) {
+	neuro := &NeuralNet{}
+	if _, err := neuro.Predict([]float64{1, 2}); err == nil {
+		t.Errorf("expected an error when predicting without weights")
+	}
+	if _, err := neuro.PredictProbabilities([]float64{1, 2}); err == nil {
+		t.Errorf("expected an error when predicting without weights")
+	}
+}
+
+func TestClassIndices(t *testing.T) {
+	neuro := &NeuralNet{Classes: []string{"cat", "dog", "bird"}}
+	idx, err := neuro.ClassIndices([]string{"bird", "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx) != 2 || idx[0] != 2 || idx[1] != 0 {
+		t.Errorf("expected the classes to be matched by name, got %v", idx)
+	}
+	if _, err := neuro.ClassIndices([]string{"cat", "fish"}); err == nil {
+		t.Errorf("expected an error for a class the net wasn't trained on")
+	}
+	if idx, err := (&NeuralNet{}).ClassIndices([]string{"b", "a"}); err != nil || idx[0] != 0 || idx[1] != 1 {
+		t.Errorf("expected the same order without class names, got %v", idx)
+	}
+}
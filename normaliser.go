@@ -1,66 +1,94 @@
 package main
 
 import (
-	"math"
+	"fmt"
 )
 
+// Normaliser standardises every feature to zero mean and unit standard deviation. The means and
+// standard deviations are learnt by Fit on the training data and saved with the net so that new
+// examples are normalised the same way.
 type Normaliser struct {
+	Means   []float64
+	StdDevs []float64
 }
 
+// StdDev fits the normaliser on input and returns it normalised
 func (n *Normaliser) StdDev(input [][]float64) [][]float64 {
-	br := n.sliceTranspose(input)
-	var resultX [][]float64
-	for i := range br {
-		resultX = append(resultX, n.numeric(br[i]))
+	if err := n.Fit(input); err != nil {
+		panic(err)
 	}
-	return n.sliceTranspose(resultX)
+	res, err := n.Transform(input)
+	if err != nil {
+		panic(err)
+	}
+	return res
 }
 
-func (n *Normaliser) sliceTranspose(input [][]float64) [][]float64 {
-	b := make([][]float64, len(input[0]))
-	for i := 0; i < len(input[0]); i++ {
-		b[i] = make([]float64, len(input))
+// Fit learns the mean and standard deviation of each feature from the training examples, features
+// without any variance get a standard deviation of 1 so they become 0
+func (n *Normaliser) Fit(x [][]float64) error {
+	numFeatures, err := checkFitRows(x, "normaliser")
+	if err != nil {
+		return err
 	}
-	for i := 0; i < len(input); i++ {
-		for j := 0; j < len(input[0]); j++ {
-			b[j][i] = input[i][j]
-		}
+	stats := NewRunningStats(numFeatures)
+	if err := stats.AddBatch(x); err != nil {
+		return err
 	}
-	return b
+	n.setStats(stats)
+	return nil
 }
 
-func (n *Normaliser) numeric(values []float64) []float64 {
-	var result []float64
-
-	mean := n.sum(values) / float64(len(values))
-
-	stdDev := n.stdDev(values, mean)
-	for k := range values {
-		result = append(result, (values[k]-mean)/stdDev)
+// FitDataset is the same as Fit but reads the examples from the set batchSize at a time, so a
+// dataset that doesn't fit in memory can be normalised
+func (n *Normaliser) FitDataset(set Dataset, batchSize int) error {
+	if set.Len() == 0 {
+		return fmt.Errorf("normaliser: no examples to fit")
 	}
-	return result
+	numFeatures, _ := set.Dims()
+	stats := NewRunningStats(numFeatures)
+	if err := eachBatch(set, batchSize, stats.AddBatch); err != nil {
+		return err
+	}
+	n.setStats(stats)
+	return nil
 }
 
-func (n *Normaliser) sum(numbers []float64) (total float64) {
-	for _, x := range numbers {
-		total += x
-	}
-	return total
+func (n *Normaliser) setStats(stats *RunningStats) {
+	n.Means = append([]float64(nil), stats.Means...)
+	n.StdDevs = stats.StdDevs()
 }
 
-func (n *Normaliser) stdDev(numbers []float64, mean float64) float64 {
-	if len(numbers) == 1 {
-		return 1.0
+// TransformRow returns a normalised copy of row
+func (n *Normaliser) TransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(n.Means) {
+		return nil, fmt.Errorf("normaliser: expected %d features, got %d", len(n.Means), len(row))
 	}
-	total := 0.0
-	for _, number := range numbers {
-		total += math.Pow(number-mean, 2)
+	res := make([]float64, len(row))
+	for i, val := range row {
+		res[i] = (val - n.Means[i]) / n.StdDevs[i]
 	}
-	var variance float64
-	variance = total / float64(len(numbers)-1)
-	if variance == 0 {
-		return 1.0
+	return res, nil
+}
+
+// InverseTransformRow returns a copy of a normalised row in the original scale
+func (n *Normaliser) InverseTransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(n.Means) {
+		return nil, fmt.Errorf("normaliser: expected %d features, got %d", len(n.Means), len(row))
+	}
+	res := make([]float64, len(row))
+	for i, val := range row {
+		res[i] = val*n.StdDevs[i] + n.Means[i]
 	}
+	return res, nil
+}
+
+// Transform normalises every example
+func (n *Normaliser) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, n.TransformRow)
+}
 
-	return math.Sqrt(variance)
+// InverseTransform returns every normalised example in the original scale
+func (n *Normaliser) InverseTransform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, n.InverseTransformRow)
 }
@@ -1,43 +1,10 @@
 package main
 
 import (
+	"math"
 	"testing"
 )
 
-func TestTranspose(t *testing.T) {
-
-	input := [][]float64{
-		[]float64{1, 4, 7},
-		[]float64{2, 5, 8},
-		[]float64{3, 6, 9},
-	}
-
-	expected := [][]float64{
-		[]float64{1, 2, 3},
-		[]float64{4, 5, 6},
-		[]float64{7, 8, 9},
-	}
-
-	norm := &Normaliser{}
-
-	actual := norm.sliceTranspose(input)
-
-	if len(actual) != len(expected) {
-		t.Errorf("wanted input to be the same length as output, wanted %d, got %d", len(expected), len(actual))
-	}
-
-	for i := range actual {
-		if len(actual[i]) != len(expected[i]) {
-			t.Errorf("wanted input to be the same length as output, wanted %d, got %d", len(expected[i]), len(actual[i]))
-		}
-		for j := range actual[i] {
-			if actual[i][j] != expected[i][j] {
-				t.Errorf("wanted %f, got %f", expected[i][j], actual[i][j])
-			}
-		}
-	}
-}
-
 func TestNormalising(t *testing.T) {
 
 	norm := &Normaliser{}
@@ -105,3 +72,103 @@ func TestNormalisingNoVariance(t *testing.T) {
 		}
 	}
 }
+
+func TestNormaliserFitTransform(t *testing.T) {
+	norm := &Normaliser{}
+	if err := norm.Fit([][]float64{{1, 10}, {2, 20}, {3, 30}}); err != nil {
+		t.Fatal(err)
+	}
+	if norm.Means[0] != 2 || norm.Means[1] != 20 || norm.StdDevs[0] != 1 || norm.StdDevs[1] != 10 {
+		t.Fatalf("unexpected statistics %v %v", norm.Means, norm.StdDevs)
+	}
+
+	// examples that weren't part of the fit use the same statistics
+	input := [][]float64{{4, 0}, {2, 25}}
+	actual, err := norm.Transform(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]float64{{2, -2}, {0, 0.5}}
+	for i := range expected {
+		for j := range expected[i] {
+			if actual[i][j] != expected[i][j] {
+				t.Errorf("wanted %f, got %f", expected[i][j], actual[i][j])
+			}
+		}
+	}
+
+	restored, err := norm.InverseTransform(actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range input {
+		for j := range input[i] {
+			if restored[i][j] != input[i][j] {
+				t.Errorf("wanted %f, got %f", input[i][j], restored[i][j])
+			}
+		}
+	}
+}
+
+func TestNormaliserErrors(t *testing.T) {
+	norm := &Normaliser{}
+	if err := norm.Fit(nil); err == nil {
+		t.Errorf("expected an error without examples")
+	}
+	if err := norm.Fit([][]float64{{1, 2}, {3}}); err == nil {
+		t.Errorf("expected an error for ragged examples")
+	}
+	if err := norm.Fit([][]float64{{1, 2}, {3, 4}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := norm.TransformRow([]float64{1}); err == nil {
+		t.Errorf("expected an error for the wrong number of features")
+	}
+	if _, err := norm.InverseTransformRow([]float64{1, 2, 3}); err == nil {
+		t.Errorf("expected an error for the wrong number of features")
+	}
+}
+
+func TestNormaliserFitDataset(t *testing.T) {
+	x := randomTestX(50, 70)
+	set := &SliceDataset{X: x, Y: make([][]float64, len(x))}
+	for i := range set.Y {
+		set.Y[i] = []float64{1}
+	}
+
+	expected := &Normaliser{}
+	if err := expected.Fit(x); err != nil {
+		t.Fatal(err)
+	}
+	actual := &Normaliser{}
+	if err := actual.FitDataset(set, 16); err != nil {
+		t.Fatal(err)
+	}
+	for j := range expected.Means {
+		if math.Abs(actual.Means[j]-expected.Means[j]) > 1e-9 || math.Abs(actual.StdDevs[j]-expected.StdDevs[j]) > 1e-9 {
+			t.Fatalf("feature %d: expected %f ± %f, got %f ± %f", j, expected.Means[j], expected.StdDevs[j], actual.Means[j], actual.StdDevs[j])
+		}
+	}
+	if err := actual.FitDataset(&SliceDataset{}, 16); err == nil {
+		t.Errorf("expected an error without examples")
+	}
+}
+
+func BenchmarkNormaliserFit(b *testing.B) {
+	x := randomTestX(1000, 3072)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		norm := &Normaliser{}
+		norm.Fit(x)
+	}
+}
+
+func BenchmarkNormaliserTransform(b *testing.B) {
+	x := randomTestX(1000, 3072)
+	norm := &Normaliser{}
+	norm.Fit(x)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		norm.Transform(x)
+	}
+}
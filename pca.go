@@ -0,0 +1,149 @@
+package main
+
+import "fmt"
+
+// PCA reduces the number of features by projecting them onto the directions with the most variance
+// in the training data, the principal components
+type PCA struct {
+	// Components is the number of components to keep, when it's zero just enough are kept to
+	// explain the Variance
+	Components int
+	// Variance is the fraction of the total variance the components should explain, defaults to
+	// 0.99
+	Variance float64
+
+	Means []float64
+	// Basis has a row per component, ordered by the variance they explain
+	Basis *Matrix
+	// Explained is the variance along each component
+	Explained []float64
+	// TotalVariance is the variance of all the features together
+	TotalVariance float64
+}
+
+// Fit finds the principal components of the training examples from the eigenvectors of their
+// covariance
+func (p *PCA) Fit(x [][]float64) error {
+	if _, err := checkFitRows(x, "pca"); err != nil {
+		return err
+	}
+	return p.FitDataset(unlabelled(x), 0)
+}
+
+// FitDataset is the same as Fit but reads the examples from the set batchSize at a time
+func (p *PCA) FitDataset(set Dataset, batchSize int) error {
+	if set.Len() == 0 {
+		return fmt.Errorf("pca: no examples to fit")
+	}
+	numFeatures, _ := set.Dims()
+	if p.Components > numFeatures {
+		return fmt.Errorf("pca: %d components is more than the %d features", p.Components, numFeatures)
+	}
+	variance := p.Variance
+	if variance == 0 {
+		variance = 0.99
+	}
+	if p.Components == 0 && (variance < 0 || variance > 1) {
+		return fmt.Errorf("pca: the variance %g must be between 0 and 1", variance)
+	}
+
+	var cov *Matrix
+	var err error
+	if p.Means, cov, err = covariance(set, batchSize); err != nil {
+		return err
+	}
+	p.TotalVariance = 0
+	for i := 0; i < numFeatures; i++ {
+		p.TotalVariance += cov.At(i, i)
+	}
+	values, vectors, err := symmetricEigen(cov)
+	if err != nil {
+		return fmt.Errorf("pca: %s", err)
+	}
+
+	var basis []float64
+	p.Explained = p.Explained[:0]
+	var explained float64
+	for k := range values {
+		if p.Components > 0 && k == p.Components {
+			break
+		}
+		if p.Components == 0 && (explained >= variance*p.TotalVariance || values[k] <= 0) {
+			// enough variance is explained or only directions without variance are left
+			break
+		}
+		basis = append(basis, vectors[k]...)
+		p.Explained = append(p.Explained, values[k])
+		explained += values[k]
+	}
+	if len(p.Explained) == 0 {
+		return fmt.Errorf("pca: the features have no variance")
+	}
+	p.Basis = MustNewMatrixF(basis, len(p.Explained), numFeatures)
+	return nil
+}
+
+// ExplainedRatio returns the fraction of the total variance explained by the kept components
+func (p *PCA) ExplainedRatio() float64 {
+	var explained float64
+	for _, val := range p.Explained {
+		explained += val
+	}
+	if p.TotalVariance == 0 {
+		return 0
+	}
+	return explained / p.TotalVariance
+}
+
+// TransformRow returns the projection of row onto the components
+func (p *PCA) TransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(p.Means) {
+		return nil, fmt.Errorf("pca: expected %d features, got %d", len(p.Means), len(row))
+	}
+	res := make([]float64, p.Basis.Rows)
+	for k := range res {
+		component := p.Basis.Data[k*p.Basis.Cols : (k+1)*p.Basis.Cols]
+		for i, val := range row {
+			res[k] += (val - p.Means[i]) * component[i]
+		}
+	}
+	return res, nil
+}
+
+// Transform projects every example onto the components as a single matrix product
+func (p *PCA) Transform(x [][]float64) ([][]float64, error) {
+	if len(x) == 0 {
+		return nil, nil
+	}
+	centred := make([]float64, 0, len(x)*len(p.Means))
+	for _, row := range x {
+		if len(row) != len(p.Means) {
+			return nil, fmt.Errorf("pca: expected %d features, got %d", len(p.Means), len(row))
+		}
+		for i, val := range row {
+			centred = append(centred, val-p.Means[i])
+		}
+	}
+	projected := MustNewMatrixF(centred, len(x), len(p.Means)).MustDot(p.Basis.T())
+	res := make([][]float64, len(x))
+	for i := range res {
+		res[i] = projected.Data[i*projected.Cols : (i+1)*projected.Cols]
+	}
+	return res, nil
+}
+
+// InverseTransformRow maps a projected row back to the original features, the variance that
+// wasn't kept is lost
+func (p *PCA) InverseTransformRow(row []float64) ([]float64, error) {
+	if len(row) != p.Basis.Rows {
+		return nil, fmt.Errorf("pca: expected %d components, got %d", p.Basis.Rows, len(row))
+	}
+	res := append([]float64(nil), p.Means...)
+	for k, val := range row {
+		component := p.Basis.Data[k*p.Basis.Cols : (k+1)*p.Basis.Cols]
+		for i := range res {
+			res[i] += val * component[i]
+		}
+	}
+	return res, nil
+}
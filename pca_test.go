@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestPCAComponents(t *testing.T) {
+	x := correlatedTestX()
+	_, cov, err := covariance(unlabelled(x), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &PCA{Components: 3}
+	if err := p.Fit(x); err != nil {
+		t.Fatal(err)
+	}
+	if p.Basis.Rows != 3 || p.Basis.Cols != 3 {
+		t.Fatalf("expected a 3 x 3 basis, got %d x %d", p.Basis.Rows, p.Basis.Cols)
+	}
+	for k := range p.Explained {
+		if k > 0 && p.Explained[k] > p.Explained[k-1] {
+			t.Errorf("expected the components ordered by variance, got %v", p.Explained)
+		}
+		// each component is an eigenvector of the covariance with its variance as the eigenvalue
+		for i := 0; i < cov.Rows; i++ {
+			var cv float64
+			for j := 0; j < cov.Cols; j++ {
+				cv += cov.At(i, j) * p.Basis.At(k, j)
+			}
+			if math.Abs(cv-p.Explained[k]*p.Basis.At(k, i)) > 1e-6 {
+				t.Errorf("component %d doesn't match its variance %f", k, p.Explained[k])
+				break
+			}
+		}
+	}
+	if ratio := p.ExplainedRatio(); math.Abs(ratio-1) > 1e-9 {
+		t.Errorf("expected every component to explain all the variance, got %f", ratio)
+	}
+
+	// all the components can reconstruct the examples
+	projected, err := p.TransformRow(x[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := p.InverseTransformRow(projected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range restored {
+		if math.Abs(restored[i]-x[0][i]) > 1e-9 {
+			t.Errorf("expected %v to be restored, got %v", x[0], restored)
+			break
+		}
+	}
+}
+
+func TestPCAVariance(t *testing.T) {
+	x := correlatedTestX()
+	p := &PCA{Variance: 0.9}
+	if err := p.Fit(x); err != nil {
+		t.Fatal(err)
+	}
+	// the first two features are nearly the same direction which has most of the variance
+	if len(p.Explained) != 1 || p.ExplainedRatio() < 0.9 {
+		t.Fatalf("expected a single component to explain 90%%, got %d explaining %f", len(p.Explained), p.ExplainedRatio())
+	}
+
+	batch, err := p.Transform(x[:10])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range batch {
+		row, _ := p.TransformRow(x[i])
+		if len(batch[i]) != 1 || math.Abs(batch[i][0]-row[0]) > 1e-9 {
+			t.Errorf("expected the batch and row transforms to agree, got %v and %v", batch[i], row)
+		}
+	}
+}
+
+func TestPCAPipelineJSON(t *testing.T) {
+	x := correlatedTestX()
+	pipeline, err := NewScalerPipeline("standard", "pca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline.Steps[1].(*PCA).Components = 2
+	if err := pipeline.Fit(x); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(pipeline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &ScalerPipeline{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := pipeline.TransformRow(x[1])
+	actual, err := loaded.TransformRow(x[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 2 || !equalSlices(actual, expected) {
+		t.Errorf("expected %v from the loaded pipeline, got %v", expected, actual)
+	}
+}
+
+func TestPCAInvalid(t *testing.T) {
+	x := correlatedTestX()
+	for _, p := range []*PCA{{Components: 4}, {Variance: 1.5}} {
+		if err := p.Fit(x); err == nil {
+			t.Errorf("expected an error for %+v", p)
+		}
+	}
+	if err := (&PCA{}).Fit([][]float64{{1, 2}, {1, 2}}); err == nil {
+		t.Errorf("expected an error without variance")
+	}
+	p := &PCA{Components: 1}
+	if err := p.Fit(x); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.TransformRow([]float64{1}); err == nil {
+		t.Errorf("expected an error for the wrong number of features")
+	}
+}
+
+// pcaTestImages returns n examples of size features which are blurred noise, so like images the
+// neighbouring features are correlated
+func pcaTestImages(n, size int) [][]float64 {
+	rng := rand.New(rand.NewSource(1))
+	x := make([][]float64, n)
+	for i := range x {
+		noise := make([]float64, size)
+		for j := range noise {
+			noise[j] = rng.Float64()
+		}
+		x[i] = make([]float64, size)
+		for j := range x[i] {
+			for k := j - 4; k <= j+4; k++ {
+				if k >= 0 && k < size {
+					x[i][j] += noise[k] / 9
+				}
+			}
+		}
+	}
+	return x
+}
+
+func TestPCAManyFeatures(t *testing.T) {
+	x := pcaTestImages(200, 512)
+	p := &PCA{Variance: 0.95}
+	if err := p.Fit(x); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Explained) == 0 || len(p.Explained) >= 200 || p.ExplainedRatio() < 0.95 {
+		t.Errorf("expected fewer components than examples to explain 95%%, got %d explaining %f", len(p.Explained), p.ExplainedRatio())
+	}
+	// the basis is orthonormal
+	for _, pair := range [][2]int{{0, 0}, {0, 1}, {1, len(p.Explained) - 1}} {
+		var dot float64
+		for i := 0; i < p.Basis.Cols; i++ {
+			dot += p.Basis.At(pair[0], i) * p.Basis.At(pair[1], i)
+		}
+		expected := 0.0
+		if pair[0] == pair[1] {
+			expected = 1
+		}
+		if math.Abs(dot-expected) > 1e-9 {
+			t.Errorf("expected the components %d and %d to have a dot product of %f, got %f", pair[0], pair[1], expected, dot)
+		}
+	}
+}
+
+// BenchmarkPCAFit fits the features of a 32x32 RGB image, as in CIFAR-10
+func BenchmarkPCAFit(b *testing.B) {
+	x := pcaTestImages(100, 3072)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := (&PCA{Components: 100}).Fit(x); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import "fmt"
+
+// Unknown is the class of a prediction that isn't confident enough
+const Unknown = -1
+
+// ClassScore is the probability of a class
+type ClassScore struct {
+	Class int
+	Score float64
+}
+
+// Prediction is the most likely class of an example with the probability of every class
+type Prediction struct {
+	// Class is Unknown when the probability of the most likely class is below the threshold
+	Class         int
+	Score         float64
+	Probabilities []float64
+}
+
+// PredictProbabilities returns the probability of every class for the input, which are the sigmoid
+// outputs of the net as they are. Each output is trained with its own cross entropy to tell its
+// class from the rest, so the probabilities of an example don't sum to 1. They aren't normalised as
+// that would make two classes that both score 0.1 look like a confident 0.5 each and turn outputs
+// that are all low into a uniform distribution, hiding the examples a threshold should reject.
+func (t *NeuralNet) PredictProbabilities(input []float64) ([]float64, error) {
+	probs, err := t.PredictProbabilitiesBatch(MustNewMatrixF(input, 1, len(input)))
+	if err != nil {
+		return nil, err
+	}
+	return probs[0], nil
+}
+
+// PredictProbabilitiesBatch returns the probability of every class for each row of X in a single
+// pass through the net
+func (t *NeuralNet) PredictProbabilitiesBatch(X *Matrix) ([][]float64, error) {
+	a3, err := t.forward(X)
+	if err != nil {
+		return nil, err
+	}
+	res := make([][]float64, a3.Rows)
+	for i := range res {
+		res[i] = append([]float64(nil), a3.Data[i*a3.Cols:(i+1)*a3.Cols]...)
+	}
+	return res, nil
+}
+
+// PredictBatch returns the most likely class for each row of X
+func (t *NeuralNet) PredictBatch(X *Matrix) ([]int, error) {
+	return t.predict(X)
+}
+
+// PredictTopK returns the k most likely classes for the input, most likely first
+func (t *NeuralNet) PredictTopK(input []float64, k int) ([]ClassScore, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("predict: k must be at least 1, got %d", k)
+	}
+	probs, err := t.PredictProbabilities(input)
+	if err != nil {
+		return nil, err
+	}
+	return topKScores(probs, k), nil
+}
+
+// topKScores returns the classes with the k highest probabilities, highest first
+func topKScores(probs []float64, k int) []ClassScore {
+	classes := topK(probs, k)
+	res := make([]ClassScore, len(classes))
+	for i, c := range classes {
+		res[i] = ClassScore{Class: c, Score: probs[c]}
+	}
+	return res
+}
+
+// PredictWithThreshold returns the most likely class for the input, or Unknown when its probability
+// is below minScore. Since the probabilities aren't normalised an input that looks like none of the
+// classes is rejected.
+func (t *NeuralNet) PredictWithThreshold(input []float64, minScore float64) (Prediction, error) {
+	res, err := t.PredictBatchWithThreshold(MustNewMatrixF(input, 1, len(input)), minScore)
+	if err != nil {
+		return Prediction{}, err
+	}
+	return res[0], nil
+}
+
+// PredictBatchWithThreshold is PredictWithThreshold for each row of X
+func (t *NeuralNet) PredictBatchWithThreshold(X *Matrix, minScore float64) ([]Prediction, error) {
+	probs, err := t.PredictProbabilitiesBatch(X)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Prediction, len(probs))
+	for i := range probs {
+		res[i] = threshold(probs[i], minScore)
+	}
+	return res, nil
+}
+
+// threshold picks the most likely class, or Unknown when it's less likely than minScore
+func threshold(probs []float64, minScore float64) Prediction {
+	best := argMax(probs)
+	p := Prediction{Class: best, Score: probs[best], Probabilities: probs}
+	if p.Score < minScore {
+		p.Class = Unknown
+	}
+	return p
+}
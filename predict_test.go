@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+func TestPredictProbabilities(t *testing.T) {
+	// the net ignores its 2 inputs and outputs sigmoid(2), sigmoid(0) and sigmoid(-2) from the biases
+	nn := &NeuralNet{HiddenNeurons: 2, W1: NewZeros(2, 3), W2: MustNewMatrix([][]float64{{2, 0, 0}, {0, 0, 0}, {-2, 0, 0}})}
+	probs, err := nn.PredictProbabilities([]float64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{sigmoid(2), 0.5, sigmoid(-2)}
+	if !equalSlices(probs, expected) {
+		t.Errorf("expected the sigmoid outputs %v, got %v", expected, probs)
+	}
+
+	if _, err := nn.PredictProbabilities([]float64{1, 2, 3}); err == nil {
+		t.Error("expected an error when predicting with the wrong number of features")
+	}
+}
+
+func TestPredictBatch(t *testing.T) {
+	nn := &NeuralNet{HiddenNeurons: 2}
+	nn.W1 = MustNewMatrix([][]float64{{0, 1, 0}, {0, 0, 1}})
+	nn.W2 = MustNewMatrix([][]float64{{0, 4, -4}, {0, -4, 4}})
+	X := MustNewMatrix([][]float64{{3, -3}, {-3, 3}, {2, -1}})
+
+	classes, err := nn.PredictBatch(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probs, err := nn.PredictProbabilitiesBatch(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) != 3 || len(probs) != 3 {
+		t.Fatalf("expected 3 predictions, got %d and %d", len(classes), len(probs))
+	}
+	for i, expected := range []int{0, 1, 0} {
+		if classes[i] != expected {
+			t.Errorf("expected class %d for row %d, got %d", expected, i, classes[i])
+		}
+		single, err := nn.PredictProbabilities(X.Data[i*X.Cols : (i+1)*X.Cols])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalSlices(single, probs[i]) {
+			t.Errorf("expected the batch to match a single prediction for row %d, got %v and %v", i, probs[i], single)
+		}
+	}
+}
+
+func TestPredictTopK(t *testing.T) {
+	nn := &NeuralNet{HiddenNeurons: 2, W1: NewZeros(2, 3), W2: MustNewMatrix([][]float64{{2, 0, 0}, {0, 0, 0}, {-2, 0, 0}})}
+	top, err := nn.PredictTopK([]float64{1, 2}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Class != 0 || top[1].Class != 1 {
+		t.Fatalf("expected the classes 0 and 1, got %+v", top)
+	}
+	if !almostEqual(top[0].Score, sigmoid(2)) || !almostEqual(top[1].Score, 0.5) {
+		t.Errorf("expected the scores %f and 0.5, got %+v", sigmoid(2), top)
+	}
+
+	if top, err := nn.PredictTopK([]float64{1, 2}, 10); err != nil || len(top) != 3 {
+		t.Errorf("expected every class when k is larger than the number of classes, got %+v and %v", top, err)
+	}
+	if _, err := nn.PredictTopK([]float64{1, 2}, 0); err == nil {
+		t.Error("expected an error for k = 0")
+	}
+}
+
+func TestPredictWithThreshold(t *testing.T) {
+	// the outputs are sigmoid(2) = 0.88, 0.5 and sigmoid(-2) = 0.12, normalised the first would be 0.59
+	nn := &NeuralNet{HiddenNeurons: 2, W1: NewZeros(2, 3), W2: MustNewMatrix([][]float64{{2, 0, 0}, {0, 0, 0}, {-2, 0, 0}})}
+
+	p, err := nn.PredictWithThreshold([]float64{1, 2}, 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Class != 0 || !almostEqual(p.Score, sigmoid(2)) || len(p.Probabilities) != 3 {
+		t.Errorf("expected class 0 with a score of %f, got %+v", sigmoid(2), p)
+	}
+
+	p, err = nn.PredictWithThreshold([]float64{1, 2}, 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Class != Unknown || !almostEqual(p.Score, sigmoid(2)) {
+		t.Errorf("expected an unknown class below the threshold, got %+v", p)
+	}
+
+	preds, err := nn.PredictBatchWithThreshold(MustNewMatrix([][]float64{{1, 2}, {3, 4}}), 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preds) != 2 || preds[0].Class != Unknown || preds[1].Class != Unknown {
+		t.Errorf("expected 2 unknown predictions, got %+v", preds)
+	}
+
+	// an input that looks like none of the classes is rejected rather than spread evenly over them
+	nn.W2 = MustNewMatrix([][]float64{{-5, 0, 0}, {-5, 0, 0}, {-5, 0, 0}})
+	if p, err = nn.PredictWithThreshold([]float64{1, 2}, 0.5); err != nil || p.Class != Unknown {
+		t.Errorf("expected an unknown class when every output is low, got %+v and %v", p, err)
+	}
+}
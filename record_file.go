@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// recordFile gives random access to a file made up of fixed size records. The file is memory
+// mapped where the platform supports it and read with ReadAt otherwise, either way only the
+// records that are asked for are read into memory.
+type recordFile struct {
+	f          *os.File
+	data       []byte
+	recordSize int
+	numRecords int
+}
+
+func openRecordFile(name string, recordSize int) (*recordFile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size()%int64(recordSize) != 0 {
+		f.Close()
+		return nil, fmt.Errorf("%s: size %d is not a multiple of the record size %d", name, info.Size(), recordSize)
+	}
+
+	r := &recordFile{
+		f:          f,
+		recordSize: recordSize,
+		numRecords: int(info.Size() / int64(recordSize)),
+	}
+	if info.Size() > 0 {
+		if r.data, err = mmap(f, int(info.Size())); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Len returns the number of records in the file
+func (r *recordFile) Len() int {
+	return r.numRecords
+}
+
+// record returns record i, buf is used if the file isn't memory mapped. The returned slice must
+// not be modified.
+func (r *recordFile) record(i int, buf []byte) ([]byte, error) {
+	if cap(buf) < r.recordSize {
+		buf = make([]byte, r.recordSize)
+	}
+	if i < 0 || i >= r.numRecords {
+		return nil, fmt.Errorf("%s: record %d out of range [0, %d)", r.f.Name(), i, r.numRecords)
+	}
+	offset := i * r.recordSize
+	if r.data != nil {
+		return r.data[offset : offset+r.recordSize], nil
+	}
+	buf = buf[:r.recordSize]
+	if _, err := r.f.ReadAt(buf, int64(offset)); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (r *recordFile) Close() error {
+	if r.data != nil {
+		if err := munmap(r.data); err != nil {
+			return err
+		}
+		r.data = nil
+	}
+	return r.f.Close()
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Scaler learns a transform of the features from the training examples so that it can be applied
+// the same way to new examples
+type Scaler interface {
+	Fit(x [][]float64) error
+	TransformRow(row []float64) ([]float64, error)
+	Transform(x [][]float64) ([][]float64, error)
+}
+
+// DatasetScaler is a Scaler that can also be fitted by streaming the examples of a dataset
+// batchSize at a time, so the training set doesn't have to fit in memory
+type DatasetScaler interface {
+	Scaler
+	FitDataset(set Dataset, batchSize int) error
+}
+
+// maxFitSamples limits how many examples are read into memory to fit a scaler that can't stream
+const maxFitSamples = 10000
+
+// FitScaler fits s on the examples of set, streaming them when s is a DatasetScaler. The other
+// scalers are fitted on at most maxFitSamples examples spread evenly over the set.
+func FitScaler(s Scaler, set Dataset, batchSize int) error {
+	if d, ok := s.(DatasetScaler); ok {
+		return d.FitDataset(set, batchSize)
+	}
+	x, err := sampleSlices(set, maxFitSamples)
+	if err != nil {
+		return err
+	}
+	return s.Fit(x)
+}
+
+// scalerTypes are the scalers that can be saved in a ScalerPipeline by name
+var scalerTypes = map[string]func() Scaler{
+	"standard":  func() Scaler { return &Normaliser{} },
+	"minmax":    func() Scaler { return &MinMaxScaler{} },
+	"maxabs":    func() Scaler { return &MaxAbsScaler{} },
+	"robust":    func() Scaler { return &RobustScaler{} },
+	"l2":        func() Scaler { return &L2Normaliser{} },
+	"log":       func() Scaler { return &LogScaler{} },
+	"whitening": func() Scaler { return &Whitening{} },
+	"zca":       func() Scaler { return &Whitening{ZCA: true} },
+	"pca":       func() Scaler { return &PCA{} },
+}
+
+// NewScaler returns an unfitted scaler by name: standard, minmax, maxabs, robust, l2, log,
+// whitening, zca or pca
+func NewScaler(name string) (Scaler, error) {
+	newScaler, ok := scalerTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("scaler: unknown scaler %q", name)
+	}
+	return newScaler(), nil
+}
+
+// scalerName returns the first name of the type of s, the parameters tell the variants apart
+func scalerName(s Scaler) (string, error) {
+	names := make([]string, 0, len(scalerTypes))
+	for name := range scalerTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if reflect.TypeOf(scalerTypes[name]()) == reflect.TypeOf(s) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("scaler: %T isn't a known scaler", s)
+}
+
+// transformRows applies row to every example
+func transformRows(x [][]float64, row func([]float64) ([]float64, error)) ([][]float64, error) {
+	res := make([][]float64, len(x))
+	for i := range x {
+		var err error
+		if res[i], err = row(x[i]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// checkFitRows returns the number of features of x, or an error if x is empty or ragged
+func checkFitRows(x [][]float64, name string) (int, error) {
+	if len(x) == 0 {
+		return 0, fmt.Errorf("%s: no examples to fit", name)
+	}
+	for _, row := range x {
+		if len(row) != len(x[0]) {
+			return 0, fmt.Errorf("%s: expected %d features, got %d", name, len(x[0]), len(row))
+		}
+	}
+	return len(x[0]), nil
+}
+
+// ScalerPipeline chains scalers, each step is fitted on the output of the step before
+type ScalerPipeline struct {
+	Steps []Scaler
+}
+
+// NewScalerPipeline returns a pipeline of unfitted scalers by name
+func NewScalerPipeline(names ...string) (*ScalerPipeline, error) {
+	p := &ScalerPipeline{}
+	for _, name := range names {
+		s, err := NewScaler(name)
+		if err != nil {
+			return nil, err
+		}
+		p.Steps = append(p.Steps, s)
+	}
+	return p, nil
+}
+
+// Fit fits every step in order
+func (p *ScalerPipeline) Fit(x [][]float64) error {
+	for _, s := range p.Steps {
+		if err := s.Fit(x); err != nil {
+			return err
+		}
+		var err error
+		if x, err = s.Transform(x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FitDataset fits every step in order on a view of the set through the steps before it, see
+// FitScaler
+func (p *ScalerPipeline) FitDataset(set Dataset, batchSize int) error {
+	for i, s := range p.Steps {
+		before := &ScalerPipeline{Steps: p.Steps[:i]}
+		view, err := Preprocessed(set, before.TransformRow)
+		if err != nil {
+			return err
+		}
+		if err := FitScaler(s, view, batchSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TransformRow runs row through every step
+func (p *ScalerPipeline) TransformRow(row []float64) ([]float64, error) {
+	for _, s := range p.Steps {
+		var err error
+		if row, err = s.TransformRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// Transform runs every example through every step
+func (p *ScalerPipeline) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, p.TransformRow)
+}
+
+// scalerStep is how a step is stored, the type is needed to decode the parameters
+type scalerStep struct {
+	Type   string
+	Params json.RawMessage
+}
+
+// MarshalJSON implements json.Marshaler
+func (p *ScalerPipeline) MarshalJSON() ([]byte, error) {
+	steps := make([]scalerStep, len(p.Steps))
+	for i, s := range p.Steps {
+		name, err := scalerName(s)
+		if err != nil {
+			return nil, err
+		}
+		params, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = scalerStep{Type: name, Params: params}
+	}
+	return json.Marshal(struct{ Steps []scalerStep }{steps})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *ScalerPipeline) UnmarshalJSON(data []byte) error {
+	var v struct{ Steps []scalerStep }
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	p.Steps = make([]Scaler, len(v.Steps))
+	for i, step := range v.Steps {
+		s, err := NewScaler(step.Type)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(step.Params, s); err != nil {
+			return fmt.Errorf("scaler: %s: %s", step.Type, err)
+		}
+		p.Steps[i] = s
+	}
+	return nil
+}
+
+// MinMaxScaler scales every feature linearly so the training examples are between Low and High,
+// which default to 0 and 1
+type MinMaxScaler struct {
+	Low, High  float64
+	Mins, Maxs []float64
+}
+
+// Fit learns the smallest and largest value of each feature
+func (s *MinMaxScaler) Fit(x [][]float64) error {
+	numFeatures, err := checkFitRows(x, "minmax scaler")
+	if err != nil {
+		return err
+	}
+	if err := s.reset(numFeatures); err != nil {
+		return err
+	}
+	return s.add(x)
+}
+
+// FitDataset is the same as Fit but reads the examples from the set batchSize at a time
+func (s *MinMaxScaler) FitDataset(set Dataset, batchSize int) error {
+	if set.Len() == 0 {
+		return fmt.Errorf("minmax scaler: no examples to fit")
+	}
+	numFeatures, _ := set.Dims()
+	if err := s.reset(numFeatures); err != nil {
+		return err
+	}
+	return eachBatch(set, batchSize, s.add)
+}
+
+// reset checks the range and clears the smallest and largest values
+func (s *MinMaxScaler) reset(numFeatures int) error {
+	if s.Low == 0 && s.High == 0 {
+		s.High = 1
+	}
+	if s.High <= s.Low {
+		return fmt.Errorf("minmax scaler: the range [%g, %g] is empty", s.Low, s.High)
+	}
+	s.Mins = make([]float64, numFeatures)
+	s.Maxs = make([]float64, numFeatures)
+	for i := range s.Mins {
+		s.Mins[i], s.Maxs[i] = math.Inf(1), math.Inf(-1)
+	}
+	return nil
+}
+
+// add widens the smallest and largest values to include the examples in x
+func (s *MinMaxScaler) add(x [][]float64) error {
+	for _, row := range x {
+		for i, val := range row {
+			s.Mins[i] = math.Min(s.Mins[i], val)
+			s.Maxs[i] = math.Max(s.Maxs[i], val)
+		}
+	}
+	return nil
+}
+
+// TransformRow returns a scaled copy of row, values outside of the training range end up outside
+// of [Low, High]. Features that were constant become Low.
+func (s *MinMaxScaler) TransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(s.Mins) {
+		return nil, fmt.Errorf("minmax scaler: expected %d features, got %d", len(s.Mins), len(row))
+	}
+	res := make([]float64, len(row))
+	for i, val := range row {
+		span := s.Maxs[i] - s.Mins[i]
+		if span == 0 {
+			span = 1
+		}
+		res[i] = s.Low + (val-s.Mins[i])/span*(s.High-s.Low)
+	}
+	return res, nil
+}
+
+// Transform scales every example
+func (s *MinMaxScaler) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, s.TransformRow)
+}
+
+// MaxAbsScaler divides every feature by its largest absolute value so the training examples are
+// between -1 and 1. Nothing is centred, so zeros stay zero and sparse data stays sparse.
+type MaxAbsScaler struct {
+	MaxAbs []float64
+}
+
+// Fit learns the largest absolute value of each feature, features that are always zero get 1
+func (s *MaxAbsScaler) Fit(x [][]float64) error {
+	numFeatures, err := checkFitRows(x, "maxabs scaler")
+	if err != nil {
+		return err
+	}
+	s.MaxAbs = make([]float64, numFeatures)
+	s.add(x)
+	s.fillZeros()
+	return nil
+}
+
+// FitDataset is the same as Fit but reads the examples from the set batchSize at a time
+func (s *MaxAbsScaler) FitDataset(set Dataset, batchSize int) error {
+	if set.Len() == 0 {
+		return fmt.Errorf("maxabs scaler: no examples to fit")
+	}
+	numFeatures, _ := set.Dims()
+	s.MaxAbs = make([]float64, numFeatures)
+	if err := eachBatch(set, batchSize, func(x [][]float64) error {
+		s.add(x)
+		return nil
+	}); err != nil {
+		return err
+	}
+	s.fillZeros()
+	return nil
+}
+
+// add widens the largest absolute values to include the examples in x
+func (s *MaxAbsScaler) add(x [][]float64) {
+	for _, row := range x {
+		for i, val := range row {
+			s.MaxAbs[i] = math.Max(s.MaxAbs[i], math.Abs(val))
+		}
+	}
+}
+
+// fillZeros gives the features that are always zero a scale of 1
+func (s *MaxAbsScaler) fillZeros() {
+	for i, val := range s.MaxAbs {
+		if val == 0 {
+			s.MaxAbs[i] = 1
+		}
+	}
+}
+
+// TransformRow returns a scaled copy of row
+func (s *MaxAbsScaler) TransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(s.MaxAbs) {
+		return nil, fmt.Errorf("maxabs scaler: expected %d features, got %d", len(s.MaxAbs), len(row))
+	}
+	res := make([]float64, len(row))
+	for i, val := range row {
+		res[i] = val / s.MaxAbs[i]
+	}
+	return res, nil
+}
+
+// Transform scales every example
+func (s *MaxAbsScaler) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, s.TransformRow)
+}
+
+// RobustScaler centres every feature on the median and divides by the interquartile range, which
+// unlike the mean and standard deviation isn't thrown off by outliers. The quantiles need every
+// example at once, so FitScaler fits it on a sample of a large dataset.
+type RobustScaler struct {
+	Medians []float64
+	IQRs    []float64
+}
+
+// Fit learns the median and interquartile range of each feature, features with no spread between
+// the quartiles get a range of 1
+func (s *RobustScaler) Fit(x [][]float64) error {
+	numFeatures, err := checkFitRows(x, "robust scaler")
+	if err != nil {
+		return err
+	}
+	s.Medians = make([]float64, numFeatures)
+	s.IQRs = make([]float64, numFeatures)
+	values := make([]float64, len(x))
+	for col := 0; col < numFeatures; col++ {
+		for i, row := range x {
+			values[i] = row[col]
+		}
+		sort.Float64s(values)
+		s.Medians[col] = quantile(values, 0.5)
+		s.IQRs[col] = quantile(values, 0.75) - quantile(values, 0.25)
+		if s.IQRs[col] == 0 {
+			s.IQRs[col] = 1
+		}
+	}
+	return nil
+}
+
+// quantile interpolates linearly between the closest ranks of the sorted values
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	frac := pos - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
+
+// TransformRow returns a scaled copy of row
+func (s *RobustScaler) TransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(s.Medians) {
+		return nil, fmt.Errorf("robust scaler: expected %d features, got %d", len(s.Medians), len(row))
+	}
+	res := make([]float64, len(row))
+	for i, val := range row {
+		res[i] = (val - s.Medians[i]) / s.IQRs[i]
+	}
+	return res, nil
+}
+
+// Transform scales every example
+func (s *RobustScaler) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, s.TransformRow)
+}
+
+// L2Normaliser scales every example to unit length, it works per example so there's nothing to fit
+type L2Normaliser struct{}
+
+// Fit does nothing, it's there for the Scaler interface
+func (s *L2Normaliser) Fit(x [][]float64) error {
+	return nil
+}
+
+// FitDataset does nothing either, so no examples are read
+func (s *L2Normaliser) FitDataset(set Dataset, batchSize int) error {
+	return nil
+}
+
+// TransformRow returns a copy of row with a length of 1, an all zero row stays zero
+func (s *L2Normaliser) TransformRow(row []float64) ([]float64, error) {
+	var norm float64
+	for _, val := range row {
+		norm += val * val
+	}
+	norm = math.Sqrt(norm)
+	res := make([]float64, len(row))
+	for i, val := range row {
+		if norm > 0 {
+			res[i] = val / norm
+		}
+	}
+	return res, nil
+}
+
+// Transform normalises every example
+func (s *L2Normaliser) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, s.TransformRow)
+}
+
+// LogScaler compresses large values with sign(x) * log(1 + |x|), which keeps zero at zero and works
+// for negative values. It has nothing to fit.
+type LogScaler struct{}
+
+// Fit does nothing, it's there for the Scaler interface
+func (s *LogScaler) Fit(x [][]float64) error {
+	return nil
+}
+
+// FitDataset does nothing either, so no examples are read
+func (s *LogScaler) FitDataset(set Dataset, batchSize int) error {
+	return nil
+}
+
+// TransformRow returns a log scaled copy of row
+func (s *LogScaler) TransformRow(row []float64) ([]float64, error) {
+	res := make([]float64, len(row))
+	for i, val := range row {
+		res[i] = math.Copysign(math.Log1p(math.Abs(val)), val)
+	}
+	return res, nil
+}
+
+// Transform log scales every example
+func (s *LogScaler) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, s.TransformRow)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+var scalerTestX = [][]float64{{1, -4}, {2, 0}, {3, 4}, {4, 8}, {100, 12}}
+
+func TestMinMaxScaler(t *testing.T) {
+	s := &MinMaxScaler{}
+	if err := s.Fit(scalerTestX); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := s.TransformRow([]float64{50.5, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual[0] != 0.5 || actual[1] != 0.5 {
+		t.Errorf("expected 0.5 and 0.5, got %v", actual)
+	}
+
+	s = &MinMaxScaler{Low: -1, High: 1}
+	if err := s.Fit([][]float64{{5}, {5}}); err != nil {
+		t.Fatal(err)
+	}
+	if actual, _ := s.TransformRow([]float64{5}); actual[0] != -1 {
+		t.Errorf("expected a constant feature to become the low end, got %v", actual)
+	}
+	if err := (&MinMaxScaler{Low: 1, High: 1}).Fit(scalerTestX); err == nil {
+		t.Errorf("expected an error for an empty range")
+	}
+}
+
+func TestMaxAbsScaler(t *testing.T) {
+	s := &MaxAbsScaler{}
+	if err := s.Fit([][]float64{{1, 0, 2}, {-4, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := s.TransformRow([]float64{2, 5, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an always zero feature isn't scaled and zeros stay zero
+	if actual[0] != 0.5 || actual[1] != 5 || actual[2] != 0 {
+		t.Errorf("expected 0.5, 5 and 0, got %v", actual)
+	}
+}
+
+func TestRobustScaler(t *testing.T) {
+	s := &RobustScaler{}
+	if err := s.Fit(scalerTestX); err != nil {
+		t.Fatal(err)
+	}
+	// the outlier of 100 doesn't move the median or the quartiles
+	if s.Medians[0] != 3 || s.IQRs[0] != 2 || s.Medians[1] != 4 || s.IQRs[1] != 8 {
+		t.Fatalf("unexpected statistics %v %v", s.Medians, s.IQRs)
+	}
+	actual, err := s.TransformRow([]float64{5, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual[0] != 1 || actual[1] != -0.5 {
+		t.Errorf("expected 1 and -0.5, got %v", actual)
+	}
+	if _, err := s.TransformRow([]float64{1}); err == nil {
+		t.Errorf("expected an error for the wrong number of features")
+	}
+}
+
+func TestL2AndLogScalers(t *testing.T) {
+	actual, _ := (&L2Normaliser{}).TransformRow([]float64{3, -4})
+	if actual[0] != 0.6 || actual[1] != -0.8 {
+		t.Errorf("expected a unit vector, got %v", actual)
+	}
+	if actual, _ := (&L2Normaliser{}).TransformRow([]float64{0, 0}); actual[0] != 0 || actual[1] != 0 {
+		t.Errorf("expected zero to stay zero, got %v", actual)
+	}
+	actual, _ = (&LogScaler{}).TransformRow([]float64{math.E - 1, 0, 1 - math.E})
+	if math.Abs(actual[0]-1) > 1e-12 || actual[1] != 0 || math.Abs(actual[2]+1) > 1e-12 {
+		t.Errorf("expected 1, 0 and -1, got %v", actual)
+	}
+}
+
+func TestScalerPipeline(t *testing.T) {
+	p, err := NewScalerPipeline("log", "standard", "minmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Fit(scalerTestX); err != nil {
+		t.Fatal(err)
+	}
+	x, err := p.Transform(scalerTestX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last step is fitted on the output of the others so the training examples span [0, 1]
+	for col := 0; col < 2; col++ {
+		low, high := math.Inf(1), math.Inf(-1)
+		for _, row := range x {
+			low, high = math.Min(low, row[col]), math.Max(high, row[col])
+		}
+		if math.Abs(low) > 1e-12 || math.Abs(high-1) > 1e-12 {
+			t.Errorf("column %d: expected [0, 1], got [%f, %f]", col, low, high)
+		}
+	}
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &ScalerPipeline{}
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range scalerTestX {
+		actual, err := loaded.TransformRow(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalSlices(actual, x[i]) {
+			t.Errorf("expected the loaded pipeline to give %v, got %v", x[i], actual)
+		}
+	}
+
+	if _, err := NewScalerPipeline("standard", "cubic"); err == nil {
+		t.Errorf("expected an error for an unknown scaler")
+	}
+	if err := loaded.UnmarshalJSON([]byte(`{"Steps":[{"Type":"cubic"}]}`)); err == nil {
+		t.Errorf("expected an error for an unknown scaler")
+	}
+}
+
+func TestScalerPipelineFitDataset(t *testing.T) {
+	set := &SliceDataset{X: scalerTestX, Y: make([][]float64, len(scalerTestX))}
+	for i := range set.Y {
+		set.Y[i] = []float64{1}
+	}
+	expected, _ := NewScalerPipeline("log", "standard", "robust", "minmax")
+	if err := expected.Fit(scalerTestX); err != nil {
+		t.Fatal(err)
+	}
+	// streamed 2 examples at a time, the robust scaler is fitted on a sample of all 5
+	actual, _ := NewScalerPipeline("log", "standard", "robust", "minmax")
+	if err := actual.FitDataset(set, 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range scalerTestX {
+		a, _ := actual.TransformRow(row)
+		e, _ := expected.TransformRow(row)
+		for i := range e {
+			if !almostEqual(a[i], e[i]) {
+				t.Errorf("expected the streamed pipeline to give %v, got %v", e, a)
+			}
+		}
+	}
+	if err := actual.FitDataset(&SliceDataset{}, 2); err == nil {
+		t.Errorf("expected an error without examples")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SparseVector is a single example where only the non zero values are stored. Len is the number
+// of features the dense vector would have and Indices must be increasing.
+type SparseVector struct {
+	Len     int
+	Indices []int
+	Values  []float64
+}
+
+// ToSparseVector returns the non zero values of dense as a SparseVector
+func ToSparseVector(dense []float64) SparseVector {
+	v := SparseVector{Len: len(dense)}
+	for i, val := range dense {
+		if val != 0 {
+			v.Indices = append(v.Indices, i)
+			v.Values = append(v.Values, val)
+		}
+	}
+	return v
+}
+
+// SparseMatrix is a compressed sparse row (CSR) matrix. The non zero values of row r are
+// Data[Indptr[r]:Indptr[r+1]] and their columns are the same range in Indices.
+type SparseMatrix struct {
+	Rows    int
+	Cols    int
+	Indptr  []int
+	Indices []int
+	Data    []float64
+}
+
+// NewSparseMatrix creates a matrix with one row per vector, it returns an error if the vectors are
+// of different lengths or have out of range or unordered indices
+func NewSparseMatrix(rows []SparseVector) (*SparseMatrix, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("sparse.NewSparseMatrix() needs at least one row")
+	}
+	nnz := 0
+	for _, row := range rows {
+		nnz += len(row.Indices)
+	}
+	S := &SparseMatrix{
+		Rows:    len(rows),
+		Cols:    rows[0].Len,
+		Indptr:  make([]int, 1, len(rows)+1),
+		Indices: make([]int, 0, nnz),
+		Data:    make([]float64, 0, nnz),
+	}
+	for r, row := range rows {
+		if row.Len != S.Cols {
+			return nil, fmt.Errorf("sparse.NewSparseMatrix() row %d has length %d, expected %d", r, row.Len, S.Cols)
+		}
+		if len(row.Indices) != len(row.Values) {
+			return nil, fmt.Errorf("sparse.NewSparseMatrix() row %d has %d indices but %d values", r, len(row.Indices), len(row.Values))
+		}
+		for i, col := range row.Indices {
+			if col < 0 || col >= S.Cols || i > 0 && col <= row.Indices[i-1] {
+				return nil, fmt.Errorf("sparse.NewSparseMatrix() row %d has an out of range or unordered index %d", r, col)
+			}
+		}
+		S.Indices = append(S.Indices, row.Indices...)
+		S.Data = append(S.Data, row.Values...)
+		S.Indptr = append(S.Indptr, len(S.Data))
+	}
+	return S, nil
+}
+
+// NewSparseMatrixFromDense converts A into a SparseMatrix, dropping all zeros
+func NewSparseMatrixFromDense(A *Matrix) *SparseMatrix {
+	S := &SparseMatrix{
+		Rows:   A.Rows,
+		Cols:   A.Cols,
+		Indptr: make([]int, 1, A.Rows+1),
+	}
+	for r := 0; r < A.Rows; r++ {
+		for c := 0; c < A.Cols; c++ {
+			if val := A.Data[r*A.Cols+c]; val != 0 {
+				S.Indices = append(S.Indices, c)
+				S.Data = append(S.Data, val)
+			}
+		}
+		S.Indptr = append(S.Indptr, len(S.Data))
+	}
+	return S
+}
+
+// At returns the value at row and col, which is zero unless it's stored
+func (S *SparseMatrix) At(row, col int) float64 {
+	start, end := S.Indptr[row], S.Indptr[row+1]
+	i := start + sort.SearchInts(S.Indices[start:end], col)
+	if i < end && S.Indices[i] == col {
+		return S.Data[i]
+	}
+	return 0
+}
+
+// NNZ returns the number of stored values
+func (S *SparseMatrix) NNZ() int {
+	return len(S.Data)
+}
+
+// Dense returns S as a dense Matrix
+func (S *SparseMatrix) Dense() *Matrix {
+	res := NewZeros(S.Rows, S.Cols)
+	for r := 0; r < S.Rows; r++ {
+		for i := S.Indptr[r]; i < S.Indptr[r+1]; i++ {
+			res.Data[r*S.Cols+S.Indices[i]] = S.Data[i]
+		}
+	}
+	return res
+}
+
+// Dot returns the dense product S * B
+func (S *SparseMatrix) Dot(B *Matrix) (*Matrix, error) {
+	if S.Cols != B.Rows {
+		return nil, &ShapeError{Op: "SparseDot", ARows: S.Rows, ACols: S.Cols, BRows: B.Rows, BCols: B.Cols}
+	}
+	res := make([]float64, S.Rows*B.Cols)
+	for r := 0; r < S.Rows; r++ {
+		out := res[r*B.Cols : (r+1)*B.Cols]
+		for i := S.Indptr[r]; i < S.Indptr[r+1]; i++ {
+			val := S.Data[i]
+			bRow := B.Data[S.Indices[i]*B.Cols : (S.Indices[i]+1)*B.Cols]
+			for c, b := range bRow {
+				out[c] += val * b
+			}
+		}
+	}
+	return MustNewMatrixF(res, S.Rows, B.Cols), nil
+}
+
+// DotT returns the dense product S * B^T without transposing B
+func (S *SparseMatrix) DotT(B *Matrix) (*Matrix, error) {
+	if S.Cols != B.Cols {
+		return nil, &ShapeError{Op: "SparseDotT", ARows: S.Rows, ACols: S.Cols, BRows: B.Cols, BCols: B.Rows}
+	}
+	return S.biasDotTOffset(B, 0), nil
+}
+
+// TDotSparse returns the dense product A^T * S without transposing A
+func (A *Matrix) TDotSparse(S *SparseMatrix) (*Matrix, error) {
+	if A.Rows != S.Rows {
+		return nil, &ShapeError{Op: "TDotSparse", ARows: A.Cols, ACols: A.Rows, BRows: S.Rows, BCols: S.Cols}
+	}
+	return S.tDotBiasOffset(A, 0), nil
+}
+
+func (S *SparseMatrix) dims() (rows, cols int) {
+	return S.Rows, S.Cols
+}
+
+func (S *SparseMatrix) biasDotT(W *Matrix) *Matrix {
+	if S.Cols+1 != W.Cols {
+		panic(&ShapeError{Op: "biasDotT", ARows: S.Rows, ACols: S.Cols + 1, BRows: W.Cols, BCols: W.Rows})
+	}
+	return S.biasDotTOffset(W, 1)
+}
+
+func (S *SparseMatrix) tDotBias(D *Matrix) *Matrix {
+	if S.Rows != D.Rows {
+		panic(&ShapeError{Op: "tDotBias", ARows: D.Cols, ACols: D.Rows, BRows: S.Rows, BCols: S.Cols + 1})
+	}
+	return S.tDotBiasOffset(D, 1)
+}
+
+// biasDotTOffset calculates S * W^T where the first offset columns of W are multiplied with an
+// implicit column of ones, which is how the bias is handled without copying S
+func (S *SparseMatrix) biasDotTOffset(W *Matrix, offset int) *Matrix {
+	res := make([]float64, S.Rows*W.Rows)
+	for r := 0; r < S.Rows; r++ {
+		start, end := S.Indptr[r], S.Indptr[r+1]
+		for h := 0; h < W.Rows; h++ {
+			wRow := W.Data[h*W.Cols : (h+1)*W.Cols]
+			var sum float64
+			if offset == 1 {
+				sum = wRow[0]
+			}
+			for i := start; i < end; i++ {
+				sum += S.Data[i] * wRow[S.Indices[i]+offset]
+			}
+			res[r*W.Rows+h] = sum
+		}
+	}
+	return MustNewMatrixF(res, S.Rows, W.Rows)
+}
+
+// tDotBiasOffset calculates D^T * S, if offset is 1 the result has an extra first column that is
+// the product of D^T and a column of ones
+func (S *SparseMatrix) tDotBiasOffset(D *Matrix, offset int) *Matrix {
+	cols := S.Cols + offset
+	res := make([]float64, D.Cols*cols)
+	for r := 0; r < S.Rows; r++ {
+		dRow := D.Data[r*D.Cols : (r+1)*D.Cols]
+		for h, d := range dRow {
+			if d == 0 {
+				continue
+			}
+			out := res[h*cols : (h+1)*cols]
+			if offset == 1 {
+				out[0] += d
+			}
+			for i := S.Indptr[r]; i < S.Indptr[r+1]; i++ {
+				out[S.Indices[i]+offset] += d * S.Data[i]
+			}
+		}
+	}
+	return MustNewMatrixF(res, D.Cols, cols)
+}
+
+// selectRows returns a new matrix with the rows at idx in that order
+func (S *SparseMatrix) selectRows(idx []int) *SparseMatrix {
+	res := &SparseMatrix{
+		Rows:   len(idx),
+		Cols:   S.Cols,
+		Indptr: make([]int, 1, len(idx)+1),
+	}
+	for _, r := range idx {
+		res.Indices = append(res.Indices, S.Indices[S.Indptr[r]:S.Indptr[r+1]]...)
+		res.Data = append(res.Data, S.Data[S.Indptr[r]:S.Indptr[r+1]]...)
+		res.Indptr = append(res.Indptr, len(res.Data))
+	}
+	return res
+}
+
+// ToSparseVectors converts the output of a dense loader into sparse examples
+func ToSparseVectors(dense [][]float64) []SparseVector {
+	res := make([]SparseVector, len(dense))
+	for i := range dense {
+		res[i] = ToSparseVector(dense[i])
+	}
+	return res
+}
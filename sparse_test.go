@@ -0,0 +1,231 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSparseMatrixRoundTrip(t *testing.T) {
+	A := MustNewMatrix([][]float64{
+		[]float64{0, 1, 0, 2},
+		[]float64{0, 0, 0, 0},
+		[]float64{3, 0, 0, 4},
+	})
+	S := NewSparseMatrixFromDense(A)
+	if S.NNZ() != 4 {
+		t.Errorf("expected 4 non zero values, got %d", S.NNZ())
+	}
+	if S.At(2, 3) != 4 || S.At(1, 1) != 0 {
+		t.Errorf("At() returned the wrong values")
+	}
+	if !S.Dense().Equals(A) {
+		t.Errorf("expected Dense() to return the original matrix")
+		S.Dense().Print()
+	}
+
+	fromVectors, err := NewSparseMatrix(ToSparseVectors([][]float64{
+		[]float64{0, 1, 0, 2},
+		[]float64{0, 0, 0, 0},
+		[]float64{3, 0, 0, 4},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fromVectors.Dense().Equals(A) {
+		t.Errorf("expected NewSparseMatrix to match the dense matrix")
+	}
+}
+
+func TestNewSparseMatrixInvalid(t *testing.T) {
+	tests := [][]SparseVector{
+		{{Len: 3, Indices: []int{0}, Values: []float64{1}}, {Len: 4}},
+		{{Len: 3, Indices: []int{3}, Values: []float64{1}}},
+		{{Len: 3, Indices: []int{2, 1}, Values: []float64{1, 1}}},
+		{{Len: 3, Indices: []int{1}, Values: []float64{1, 2}}},
+	}
+	for i, test := range tests {
+		if _, err := NewSparseMatrix(test); err == nil {
+			t.Errorf("#%d: expected an error", i)
+		}
+	}
+}
+
+func TestSparseMatrixDot(t *testing.T) {
+	for _, test := range matrixMulTestTable {
+		A := MustNewMatrix(test[0])
+		B := MustNewMatrix(test[1])
+		expected := MustNewMatrix(test[2])
+
+		actual, err := NewSparseMatrixFromDense(A).Dot(B)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !actual.Equals(expected) {
+			t.Errorf("Dot: actual is not the same as expected")
+			actual.Print()
+		}
+
+		actual, err = NewSparseMatrixFromDense(A).DotT(B.T())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !actual.Equals(expected) {
+			t.Errorf("DotT: actual is not the same as expected")
+			actual.Print()
+		}
+
+		actual, err = A.T().TDotSparse(NewSparseMatrixFromDense(B))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !actual.Equals(expected) {
+			t.Errorf("TDotSparse: actual is not the same as expected")
+			actual.Print()
+		}
+	}
+
+	if _, err := NewSparseMatrixFromDense(NewOnes(2, 3)).Dot(NewOnes(2, 3)); err == nil {
+		t.Errorf("expected an error for mismatched shapes")
+	}
+}
+
+func TestSparseCostFunctionMatchesDense(t *testing.T) {
+	x := MustNewMatrix([][]float64{
+		[]float64{0, 1, 0, 0, 2},
+		[]float64{0, 0, 0, 3, 0},
+		[]float64{1, 0, 0, 0, 0},
+	})
+	y := MustNewMatrix([][]float64{
+		[]float64{1, 0},
+		[]float64{0, 1},
+		[]float64{1, 0},
+	})
+
+	neuro := &NeuralNet{HiddenNeurons: 4}
+	neuro.W1 = NewRandomMatrix(4, 6)
+	neuro.W2 = NewRandomMatrix(2, 5)
+
+	jDense, gW1Dense, gW2Dense := neuro.costFunction(x, y, 0)
+	jSparse, gW1Sparse, gW2Sparse := neuro.costFunction(NewSparseMatrixFromDense(x), y, 0)
+
+	if math.Abs(jDense-jSparse) > 1e-12 {
+		t.Errorf("expected the same cost, got %f and %f", jDense, jSparse)
+	}
+	for i := range gW1Dense.Data {
+		if math.Abs(gW1Dense.Data[i]-gW1Sparse.Data[i]) > 1e-12 {
+			t.Fatalf("expected the same W1 gradient at %d, got %f and %f", i, gW1Dense.Data[i], gW1Sparse.Data[i])
+		}
+	}
+	for i := range gW2Dense.Data {
+		if math.Abs(gW2Dense.Data[i]-gW2Sparse.Data[i]) > 1e-12 {
+			t.Fatalf("expected the same W2 gradient at %d, got %f and %f", i, gW2Dense.Data[i], gW2Sparse.Data[i])
+		}
+	}
+}
+
+func TestSVMLightLoader(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.svm")
+	content := "# comment\n2 1:0.5 4:1\n-1 qid:3 2:2 # trailing\n\n2 3:1.5\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	x, y, err := svmLightLoader(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 3 || len(y) != 3 {
+		t.Fatalf("expected 3 examples, got %d", len(x))
+	}
+	if x[0].Len != 4 {
+		t.Errorf("expected 4 features, got %d", x[0].Len)
+	}
+	if x[0].Indices[1] != 3 || x[0].Values[1] != 1 {
+		t.Errorf("expected index 4 to be stored at 3, got %d", x[0].Indices[1])
+	}
+	// -1 sorts before 2
+	if y[0][1] != 1 || y[1][0] != 1 || y[2][1] != 1 {
+		t.Errorf("labels are not one-hot encoded in sorted order: %v", y)
+	}
+
+	if _, _, err := svmLightLoader(file, 2); err == nil {
+		t.Errorf("expected an error when the indices are larger than numFeatures")
+	}
+}
+
+func TestTrainSparse(t *testing.T) {
+	trX := ToSparseVectors([][]float64{
+		[]float64{0, 1, 0, 0},
+		[]float64{1, 0, 0, 0},
+	})
+	trY := [][]float64{
+		[]float64{1, 0},
+		[]float64{0, 1},
+	}
+	neuro := &NeuralNet{HiddenNeurons: 3, Alpha: 1e-1, numBatches: 1, numEpochs: 5}
+	if _, _, err := neuro.TrainSparse(trX, trY, trX, trY); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := neuro.PredictSparse(trX[0]); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	if _, err := neuro.PredictSparse(SparseVector{Len: 2}); err == nil {
+		t.Errorf("expected an error when predicting with the wrong number of features")
+	}
+}
+
+func TestSparsePreprocessing(t *testing.T) {
+	x, err := NewSparseMatrix(ToSparseVectors([][]float64{
+		[]float64{0, 2, 0, 0, -4},
+		[]float64{1, 0, 0, 0, 0},
+		[]float64{0, -1, 0, 3, 0},
+		[]float64{4, 0, 0, 0, 2},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &sparseDataset{x: x, y: [][]float64{{1, 0}, {0, 1}, {1, 0}, {0, 1}}}
+
+	// the same path as main, by default sparse data is only scaled so the batches stay sparse
+	prep, err := fitPreprocessing(set, allRows(set))
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, err := Preprocessed(Subset(set, allRows(set)), prep.Preprocess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, _, err := batchOf(view, []int{0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	S, ok := batch.(*SparseMatrix)
+	if !ok {
+		t.Fatalf("expected a sparse batch, got %T", batch)
+	}
+	if S.NNZ() != 4 || S.At(0, 1) != 1 || S.At(0, 4) != -1 || S.At(1, 3) != 1 {
+		t.Errorf("expected the non zero values to be scaled by the largest, got %v", S.Dense().Data)
+	}
+	neuro := &NeuralNet{HiddenNeurons: 3, Alpha: 1e-1, numBatches: 1, numEpochs: 5}
+	if _, _, err := neuro.TrainDataset(view, view); err != nil {
+		t.Fatal(err)
+	}
+
+	// centring makes the batches dense
+	defer func(names string) { *scalers = names }(*scalers)
+	*scalers = "standard"
+	if prep, err = fitPreprocessing(set, allRows(set)); err != nil {
+		t.Fatal(err)
+	}
+	if view, err = Preprocessed(set, prep.Preprocess); err != nil {
+		t.Fatal(err)
+	}
+	if batch, _, err = batchOf(view, []int{0, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := batch.(*Matrix); !ok {
+		t.Errorf("expected a dense batch after centring, got %T", batch)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"text/tabwriter"
+)
+
+// SplitOptions configures how examples are divided into training, validation and test sets
+type SplitOptions struct {
+	// Validation and Test are the fractions of the examples in those sets, the training set gets
+	// the rest
+	Validation, Test float64
+	// Stratify splits every class by the fractions so that each set has the same class balance
+	Stratify bool
+	// Seed makes the split reproducible
+	Seed int64
+}
+
+// Split holds the indices of the examples in each set
+type Split struct {
+	Train, Validation, Test []int
+}
+
+// SplitLabels splits examples by their class index in labels
+func SplitLabels(labels []int, opts SplitOptions) (*Split, error) {
+	if opts.Validation < 0 || opts.Test < 0 || opts.Validation+opts.Test >= 1 {
+		return nil, fmt.Errorf("split: validation %g and test %g must be positive and leave examples for training", opts.Validation, opts.Test)
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	groups := [][]int{make([]int, len(labels))}
+	for i := range labels {
+		groups[0][i] = i
+	}
+	if opts.Stratify {
+		groups = groupByClass(labels)
+	}
+
+	s := &Split{}
+	for _, group := range groups {
+		rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+		numVal := int(math.Round(float64(len(group)) * opts.Validation))
+		numTest := int(math.Round(float64(len(group)) * opts.Test))
+		if numVal+numTest > len(group) {
+			numTest = len(group) - numVal
+		}
+		s.Validation = append(s.Validation, group[:numVal]...)
+		s.Test = append(s.Test, group[numVal:numVal+numTest]...)
+		s.Train = append(s.Train, group[numVal+numTest:]...)
+	}
+	if len(s.Train) == 0 {
+		return nil, fmt.Errorf("split: there are no examples left for training")
+	}
+
+	// mix the classes within each set
+	for _, idx := range [][]int{s.Train, s.Validation, s.Test} {
+		rng.Shuffle(len(idx), func(i, j int) { idx[i], idx[j] = idx[j], idx[i] })
+	}
+	return s, nil
+}
+
+// SplitDataset splits the examples of set by their labels
+func SplitDataset(set Dataset, opts SplitOptions) (*Split, error) {
+	labels, err := datasetLabels(set)
+	if err != nil {
+		return nil, err
+	}
+	return SplitLabels(labels, opts)
+}
+
+// groupByClass returns the indices of the examples of each class in order of the classes
+func groupByClass(labels []int) [][]int {
+	var groups [][]int
+	for i, label := range labels {
+		for len(groups) <= label {
+			groups = append(groups, nil)
+		}
+		groups[label] = append(groups[label], i)
+	}
+	return groups
+}
+
+// labeled is implemented by datasets that know the class of an example without reading it
+type labeled interface {
+	Labels() []int
+}
+
+// datasetLabels returns the class index of every example
+func datasetLabels(set Dataset) ([]int, error) {
+	if l, ok := set.(labeled); ok {
+		return l.Labels(), nil
+	}
+	numFeatures, numClasses := set.Dims()
+	x := make([]float64, numFeatures)
+	y := make([]float64, numClasses)
+	labels := make([]int, set.Len())
+	for i := range labels {
+		if err := set.Example(i, x, y); err != nil {
+			return nil, err
+		}
+		labels[i] = argMax(y)
+	}
+	return labels, nil
+}
+
+// oneHotLabels returns the class index of each one-hot label
+func oneHotLabels(y [][]float64) []int {
+	labels := make([]int, len(y))
+	for i := range y {
+		labels[i] = argMax(y[i])
+	}
+	return labels
+}
+
+func argMax(x []float64) int {
+	best := 0
+	for i := range x {
+		if x[i] > x[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// rowsAt returns the rows of x at idx
+func rowsAt(x [][]float64, idx []int) [][]float64 {
+	res := make([][]float64, len(idx))
+	for i, row := range idx {
+		res[i] = x[row]
+	}
+	return res
+}
+
+// ClassCounts returns the number of examples of each class in idx
+func ClassCounts(labels []int, idx []int, numClasses int) []int {
+	counts := make([]int, numClasses)
+	for _, i := range idx {
+		counts[labels[i]]++
+	}
+	return counts
+}
+
+// Report returns a table with the number of examples of each class in each set
+func (s *Split) Report(labels []int, classNames []string) string {
+	numClasses := len(classNames)
+	for _, label := range labels {
+		if label >= numClasses {
+			numClasses = label + 1
+		}
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "set\ttotal\t")
+	for c := 0; c < numClasses; c++ {
+		name := fmt.Sprintf("%d", c)
+		if c < len(classNames) {
+			name = classNames[c]
+		}
+		fmt.Fprintf(w, "%s\t", name)
+	}
+	fmt.Fprintln(w)
+	for _, set := range []struct {
+		name string
+		idx  []int
+	}{{"train", s.Train}, {"validation", s.Validation}, {"test", s.Test}} {
+		fmt.Fprintf(w, "%s\t%d\t", set.name, len(set.idx))
+		for _, count := range ClassCounts(labels, set.idx, numClasses) {
+			fmt.Fprintf(w, "%d\t", count)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// Subset returns a view of the examples of set at idx
+func Subset(set Dataset, idx []int) Dataset {
+	return &subsetDataset{set: set, idx: idx}
+}
+
+type subsetDataset struct {
+	set Dataset
+	idx []int
+}
+
+func (s *subsetDataset) Len() int {
+	return len(s.idx)
+}
+
+func (s *subsetDataset) Dims() (int, int) {
+	return s.set.Dims()
+}
+
+func (s *subsetDataset) ClassNames() []string {
+	return s.set.ClassNames()
+}
+
+func (s *subsetDataset) FeatureNames() []string {
+	return s.set.FeatureNames()
+}
+
+func (s *subsetDataset) Example(i int, x, y []float64) error {
+	if i < 0 || i >= len(s.idx) {
+		return fmt.Errorf("dataset: example %d out of range [0, %d)", i, len(s.idx))
+	}
+	return s.set.Example(s.idx[i], x, y)
+}
+
+func (s *subsetDataset) batch(idx []int) (features, *Matrix, error) {
+	rows := make([]int, len(idx))
+	for i, row := range idx {
+		if row < 0 || row >= len(s.idx) {
+			return nil, nil, fmt.Errorf("dataset: example %d out of range [0, %d)", row, len(s.idx))
+		}
+		rows[i] = s.idx[row]
+	}
+	return batchOf(s.set, rows)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitLabelsStratified(t *testing.T) {
+	// 90 examples of class 0, 9 of class 1 and 1 of class 2
+	labels := make([]int, 100)
+	for i := 90; i < 99; i++ {
+		labels[i] = 1
+	}
+	labels[99] = 2
+	split, err := SplitLabels(labels, SplitOptions{Validation: 0.2, Test: 0.2, Stratify: true, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(split.Train)+len(split.Validation)+len(split.Test) != 100 {
+		t.Fatalf("expected every example to be in a set, got %d, %d and %d", len(split.Train), len(split.Validation), len(split.Test))
+	}
+	seen := make(map[int]bool)
+	for _, idx := range [][]int{split.Train, split.Validation, split.Test} {
+		for _, i := range idx {
+			if seen[i] {
+				t.Fatalf("example %d is in more than one set", i)
+			}
+			seen[i] = true
+		}
+	}
+
+	tests := []struct {
+		name     string
+		idx      []int
+		expected []int
+	}{
+		{"train", split.Train, []int{54, 5, 1}},
+		{"validation", split.Validation, []int{18, 2, 0}},
+		{"test", split.Test, []int{18, 2, 0}},
+	}
+	for _, test := range tests {
+		counts := ClassCounts(labels, test.idx, 3)
+		for c := range counts {
+			if counts[c] != test.expected[c] {
+				t.Errorf("%s: expected the class counts %v, got %v", test.name, test.expected, counts)
+				break
+			}
+		}
+	}
+}
+
+func TestSplitLabelsSeed(t *testing.T) {
+	labels := make([]int, 100)
+	opts := SplitOptions{Validation: 0.1, Test: 0.3, Seed: 42}
+	a, err := SplitLabels(labels, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := SplitLabels(labels, opts)
+	if len(a.Test) != 30 || len(a.Validation) != 10 {
+		t.Fatalf("expected 10 validation and 30 test examples, got %d and %d", len(a.Validation), len(a.Test))
+	}
+	for i := range a.Test {
+		if a.Test[i] != b.Test[i] {
+			t.Fatalf("expected the same seed to give the same split")
+		}
+	}
+
+	opts.Seed = 43
+	c, _ := SplitLabels(labels, opts)
+	same := true
+	for i := range a.Test {
+		same = same && a.Test[i] == c.Test[i]
+	}
+	if same {
+		t.Errorf("expected a different seed to give a different split")
+	}
+}
+
+func TestSplitLabelsInvalid(t *testing.T) {
+	for _, opts := range []SplitOptions{{Validation: 0.5, Test: 0.5}, {Test: -0.1}} {
+		if _, err := SplitLabels(make([]int, 100), opts); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+	}
+}
+
+func TestSplitReport(t *testing.T) {
+	labels := []int{0, 0, 1, 1}
+	split := &Split{Train: []int{0, 2}, Validation: []int{1}, Test: []int{3}}
+	report := split.Report(labels, []string{"cat", "dog"})
+	lines := strings.Split(strings.TrimSpace(report), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[0], "cat") || !strings.Contains(lines[0], "dog") {
+		t.Fatalf("unexpected report\n%s", report)
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "train 2 1 1" {
+		t.Errorf("unexpected training row %q", lines[1])
+	}
+}
+
+func TestSplitDatasetSubset(t *testing.T) {
+	set, err := loadWine("testdata/wine.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	split, err := SplitDataset(set, SplitOptions{Test: 0.25, Stratify: true, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := Subset(set, split.Test)
+	if test.Len() != len(split.Test) {
+		t.Fatalf("expected %d examples in the subset, got %d", len(split.Test), test.Len())
+	}
+	x := make([]float64, 13)
+	y := make([]float64, 3)
+	expectedX := make([]float64, 13)
+	if err := test.Example(1, x, y); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Example(split.Test[1], expectedX, y); err != nil {
+		t.Fatal(err)
+	}
+	if !equalSlices(x, expectedX) {
+		t.Errorf("expected example %d of the set, got %v", split.Test[1], x)
+	}
+	batchX, _, err := batchOf(test, []int{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := batchX.(*Matrix); m.Rows != 2 || m.Data[13] != expectedX[0] {
+		t.Errorf("unexpected batch %v", m.Data)
+	}
+	if err := test.Example(test.Len(), x, y); err == nil {
+		t.Errorf("expected an error for an out of range example")
+	}
+}
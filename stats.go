@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// RunningStats accumulates the mean and variance of every feature in a single pass with Welford's
+// algorithm, so the statistics of a dataset can be gathered batch by batch without holding all of
+// it in memory
+type RunningStats struct {
+	Count int
+	Means []float64
+	// m2 is the sum of the squared differences from the mean
+	m2 []float64
+}
+
+// NewRunningStats returns empty statistics for numFeatures features
+func NewRunningStats(numFeatures int) *RunningStats {
+	return &RunningStats{Means: make([]float64, numFeatures), m2: make([]float64, numFeatures)}
+}
+
+// minFeaturesPerWorker keeps the go routines busy enough to be worth starting
+const minFeaturesPerWorker = 64
+
+// AddBatch adds every example in x, the features are split over the CPU cores
+func (s *RunningStats) AddBatch(x [][]float64) error {
+	numFeatures := len(s.Means)
+	for _, row := range x {
+		if len(row) != numFeatures {
+			return fmt.Errorf("stats: expected %d features, got %d", numFeatures, len(row))
+		}
+	}
+
+	workers := runtime.NumCPU()
+	if limit := numFeatures / minFeaturesPerWorker; workers > limit {
+		workers = limit
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	perWorker := (numFeatures + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < numFeatures; start += perWorker {
+		end := start + perWorker
+		if end > numFeatures {
+			end = numFeatures
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			s.add(x, start, end)
+		}(start, end)
+	}
+	wg.Wait()
+	s.Count += len(x)
+	return nil
+}
+
+// add updates the features from start to end with the examples in x
+func (s *RunningStats) add(x [][]float64, start, end int) {
+	means, m2 := s.Means[start:end], s.m2[start:end]
+	for r, row := range x {
+		n := float64(s.Count + r + 1)
+		for j, val := range row[start:end] {
+			delta := val - means[j]
+			means[j] += delta / n
+			m2[j] += delta * (val - means[j])
+		}
+	}
+}
+
+// Add adds a single example
+func (s *RunningStats) Add(row []float64) error {
+	return s.AddBatch([][]float64{row})
+}
+
+// Merge combines the statistics of o into s, which lets separate parts of a dataset be gathered
+// at the same time
+func (s *RunningStats) Merge(o *RunningStats) error {
+	if len(o.Means) != len(s.Means) {
+		return fmt.Errorf("stats: expected %d features, got %d", len(s.Means), len(o.Means))
+	}
+	if o.Count == 0 {
+		return nil
+	}
+	n := float64(s.Count + o.Count)
+	for j := range s.Means {
+		delta := o.Means[j] - s.Means[j]
+		s.m2[j] += o.m2[j] + delta*delta*float64(s.Count)*float64(o.Count)/n
+		s.Means[j] += delta * float64(o.Count) / n
+	}
+	s.Count += o.Count
+	return nil
+}
+
+// StdDevs returns the sample standard deviation of every feature, a feature without any variance,
+// or with a single example, gets 1 so dividing by it is safe
+func (s *RunningStats) StdDevs() []float64 {
+	res := make([]float64, len(s.m2))
+	for j := range res {
+		res[j] = 1
+		if s.Count < 2 {
+			continue
+		}
+		if variance := s.m2[j] / float64(s.Count-1); variance > 0 {
+			res[j] = math.Sqrt(variance)
+		}
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomTestX returns examples where every feature has its own mean and spread, with enough
+// features to be split over several go routines
+func randomTestX(rows, cols int) [][]float64 {
+	rng := rand.New(rand.NewSource(1))
+	x := make([][]float64, rows)
+	for i := range x {
+		x[i] = make([]float64, cols)
+		for j := range x[i] {
+			x[i][j] = rng.NormFloat64()*float64(j+1) + float64(j)
+		}
+	}
+	return x
+}
+
+// twoPassStats is the textbook mean and sample standard deviation
+func twoPassStats(x [][]float64, col int) (mean, std float64) {
+	for _, row := range x {
+		mean += row[col]
+	}
+	mean /= float64(len(x))
+	for _, row := range x {
+		std += (row[col] - mean) * (row[col] - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(x)-1))
+}
+
+func TestRunningStatsAddBatch(t *testing.T) {
+	x := randomTestX(300, 200)
+	stats := NewRunningStats(200)
+	// in uneven batches like they would come from disk
+	for _, batch := range [][][]float64{x[:1], x[1:128], x[128:]} {
+		if err := stats.AddBatch(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats.Count != 300 {
+		t.Fatalf("expected 300 examples, got %d", stats.Count)
+	}
+	stdDevs := stats.StdDevs()
+	for col := 0; col < 200; col++ {
+		mean, std := twoPassStats(x, col)
+		if math.Abs(stats.Means[col]-mean) > 1e-9 || math.Abs(stdDevs[col]-std) > 1e-9 {
+			t.Fatalf("feature %d: expected %f ± %f, got %f ± %f", col, mean, std, stats.Means[col], stdDevs[col])
+		}
+	}
+	if err := stats.Add([]float64{1}); err == nil {
+		t.Errorf("expected an error for the wrong number of features")
+	}
+}
+
+func TestRunningStatsMerge(t *testing.T) {
+	x := randomTestX(100, 3)
+	all, a, b := NewRunningStats(3), NewRunningStats(3), NewRunningStats(3)
+	all.AddBatch(x)
+	a.AddBatch(x[:30])
+	b.AddBatch(x[30:])
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Merge(NewRunningStats(3)); err != nil {
+		t.Fatal(err)
+	}
+	merged, expected := a.StdDevs(), all.StdDevs()
+	for j := range expected {
+		if math.Abs(a.Means[j]-all.Means[j]) > 1e-9 || math.Abs(merged[j]-expected[j]) > 1e-9 {
+			t.Errorf("feature %d: expected %f ± %f, got %f ± %f", j, all.Means[j], expected[j], a.Means[j], merged[j])
+		}
+	}
+	if a.Count != 100 {
+		t.Errorf("expected 100 examples, got %d", a.Count)
+	}
+	if err := a.Merge(NewRunningStats(2)); err == nil {
+		t.Errorf("expected an error for a different number of features")
+	}
+}
+
+func TestRunningStatsNoVariance(t *testing.T) {
+	stats := NewRunningStats(2)
+	stats.Add([]float64{5, 1})
+	if std := stats.StdDevs(); std[0] != 1 || std[1] != 1 {
+		t.Errorf("expected 1 for a single example, got %v", std)
+	}
+	stats.Add([]float64{5, 3})
+	if std := stats.StdDevs(); std[0] != 1 || math.Abs(std[1]-math.Sqrt2) > 1e-12 {
+		t.Errorf("expected 1 and √2, got %v", std)
+	}
+}
+
+func BenchmarkRunningStatsAddBatch(b *testing.B) {
+	x := randomTestX(1000, 3072)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stats := NewRunningStats(3072)
+		stats.AddBatch(x)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterLoader("svmlight", LoaderFunc(loadSVMLight), ".svm", ".svmlight", ".libsvm")
+}
+
+// loadSVMLight loads an svmlight file as a sparse dataset
+func loadSVMLight(file string) (Dataset, error) {
+	x, labels, err := readSVMLight(file, 0)
+	if err != nil {
+		return nil, err
+	}
+	S, err := NewSparseMatrix(x)
+	if err != nil {
+		return nil, err
+	}
+	y, classes := oneHot(labels)
+	return &sparseDataset{x: S, y: y, classes: classes}, nil
+}
+
+// svmLightLoader reads the sparse "<label> <index>:<value> ..." text format used by svmlight and
+// libsvm, where indices start at 1 and everything after a # is a comment. If numFeatures is 0 the
+// number of features is the highest index in the file. The labels can be any numbers, they are
+// sorted and one-hot encoded in that order.
+func svmLightLoader(file string, numFeatures int) ([]SparseVector, [][]float64, error) {
+	data, labels, err := readSVMLight(file, numFeatures)
+	if err != nil {
+		return nil, nil, err
+	}
+	y, _ := oneHot(labels)
+	return data, y, nil
+}
+
+// readSVMLight returns the examples and their raw labels
+func readSVMLight(file string, numFeatures int) ([]SparseVector, []float64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var data []SparseVector
+	var labels []float64
+	maxIndex := 0
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		label, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %s", file, lineNo, err)
+		}
+
+		var row SparseVector
+		for _, field := range fields[1:] {
+			pair := strings.SplitN(field, ":", 2)
+			if len(pair) != 2 {
+				return nil, nil, fmt.Errorf("%s:%d: expected index:value, got %q", file, lineNo, field)
+			}
+			// svmlight ranking files have a query id which isn't a feature
+			if pair[0] == "qid" {
+				continue
+			}
+			index, err := strconv.Atoi(pair[0])
+			if err != nil || index < 1 {
+				return nil, nil, fmt.Errorf("%s:%d: invalid index %q", file, lineNo, pair[0])
+			}
+			if len(row.Indices) > 0 && index-1 <= row.Indices[len(row.Indices)-1] {
+				return nil, nil, fmt.Errorf("%s:%d: indices must be increasing, got %d", file, lineNo, index)
+			}
+			val, err := strconv.ParseFloat(pair[1], 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s:%d: %s", file, lineNo, err)
+			}
+			if val == 0 {
+				continue
+			}
+			row.Indices = append(row.Indices, index-1)
+			row.Values = append(row.Values, val)
+			if index > maxIndex {
+				maxIndex = index
+			}
+		}
+		data = append(data, row)
+		labels = append(labels, label)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if numFeatures == 0 {
+		numFeatures = maxIndex
+	} else if maxIndex > numFeatures {
+		return nil, nil, fmt.Errorf("%s: found index %d but expected at most %d features", file, maxIndex, numFeatures)
+	}
+	for i := range data {
+		data[i].Len = numFeatures
+	}
+
+	return data, labels, nil
+}
+
+// oneHot encodes each label as a vector with a 1 at the position of the label in the sorted set
+// of distinct labels, which is also returned as the class names
+func oneHot(labels []float64) ([][]float64, []string) {
+	var classes []float64
+	seen := make(map[float64]bool)
+	for _, label := range labels {
+		if !seen[label] {
+			seen[label] = true
+			classes = append(classes, label)
+		}
+	}
+	sort.Float64s(classes)
+
+	classIdx := make(map[float64]int, len(classes))
+	for i, class := range classes {
+		classIdx[class] = i
+	}
+
+	res := make([][]float64, len(labels))
+	for i, label := range labels {
+		res[i] = make([]float64, len(classes))
+		res[i][classIdx[label]] = 1
+	}
+
+	names := make([]string, len(classes))
+	for i, class := range classes {
+		names[i] = strconv.FormatFloat(class, 'g', -1, 64)
+	}
+	return res, names
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+)
+
+// Distribution is sampled by random search for continuous hyperparameters
+type Distribution interface {
+	Sample(rng *rand.Rand) float64
+}
+
+// Uniform samples evenly between Min and Max
+type Uniform struct {
+	Min, Max float64
+}
+
+func (u Uniform) Sample(rng *rand.Rand) float64 {
+	return u.Min + rng.Float64()*(u.Max-u.Min)
+}
+
+// LogUniform samples evenly between the logarithms of Min and Max, which suits learning rates and
+// regularisation where the order of magnitude matters more than the value
+type LogUniform struct {
+	Min, Max float64
+}
+
+func (u LogUniform) Sample(rng *rand.Rand) float64 {
+	return math.Exp(Uniform{math.Log(u.Min), math.Log(u.Max)}.Sample(rng))
+}
+
+// SearchSpace lists the values to try for each hyperparameter, an empty list keeps the value of the
+// tuner's base net. Grid search only uses the lists while random search samples the distributions
+// when they are set and picks from the lists otherwise.
+type SearchSpace struct {
+	HiddenNeurons []int
+	Alpha         []float64
+	Lambda        []float64
+	BatchSize     []int
+	Activation    []Activation
+
+	// HiddenNeuronsDist and BatchSizeDist are rounded to the nearest integer
+	HiddenNeuronsDist Distribution
+	AlphaDist         Distribution
+	LambdaDist        Distribution
+	BatchSizeDist     Distribution
+}
+
+// Trial is one combination of hyperparameters
+type Trial struct {
+	HiddenNeurons int
+	Alpha         float64
+	Lambda        float64
+	// BatchSize of zero uses every training example for each update
+	BatchSize  int
+	Activation Activation
+}
+
+func (t Trial) String() string {
+	return fmt.Sprintf("hidden=%d alpha=%g lambda=%g batch=%d activation=%s", t.HiddenNeurons, t.Alpha, t.Lambda, t.BatchSize, t.Activation)
+}
+
+// trialOf returns the hyperparameters of nn
+func trialOf(nn *NeuralNet) Trial {
+	return Trial{HiddenNeurons: nn.HiddenNeurons, Alpha: nn.Alpha, Lambda: nn.Lambda, BatchSize: nn.batchSize, Activation: nn.Activation}
+}
+
+// apply sets the hyperparameters on nn
+func (t Trial) apply(nn *NeuralNet) {
+	nn.HiddenNeurons = t.HiddenNeurons
+	nn.Alpha = t.Alpha
+	nn.Lambda = t.Lambda
+	nn.batchSize = t.BatchSize
+	nn.Activation = t.Activation
+}
+
+// GridTrials returns every combination of the values in space, hyperparameters without values are
+// taken from base
+func GridTrials(space SearchSpace, base Trial) []Trial {
+	trials := []Trial{base}
+	expand := func(n int, set func(t *Trial, i int)) {
+		if n == 0 {
+			return
+		}
+		var res []Trial
+		for _, trial := range trials {
+			for i := 0; i < n; i++ {
+				set(&trial, i)
+				res = append(res, trial)
+			}
+		}
+		trials = res
+	}
+	expand(len(space.HiddenNeurons), func(t *Trial, i int) { t.HiddenNeurons = space.HiddenNeurons[i] })
+	expand(len(space.Alpha), func(t *Trial, i int) { t.Alpha = space.Alpha[i] })
+	expand(len(space.Lambda), func(t *Trial, i int) { t.Lambda = space.Lambda[i] })
+	expand(len(space.BatchSize), func(t *Trial, i int) { t.BatchSize = space.BatchSize[i] })
+	expand(len(space.Activation), func(t *Trial, i int) { t.Activation = space.Activation[i] })
+	return trials
+}
+
+// RandomTrials returns n trials sampled from space, hyperparameters without values are taken from
+// base
+func RandomTrials(space SearchSpace, base Trial, n int, seed int64) []Trial {
+	rng := rand.New(rand.NewSource(seed))
+	sampleInt := func(values []int, dist Distribution, def int) int {
+		if dist != nil {
+			return int(math.Round(dist.Sample(rng)))
+		}
+		if len(values) == 0 {
+			return def
+		}
+		return values[rng.Intn(len(values))]
+	}
+	sampleFloat := func(values []float64, dist Distribution, def float64) float64 {
+		if dist != nil {
+			return dist.Sample(rng)
+		}
+		if len(values) == 0 {
+			return def
+		}
+		return values[rng.Intn(len(values))]
+	}
+
+	trials := make([]Trial, n)
+	for i := range trials {
+		trials[i] = Trial{
+			HiddenNeurons: sampleInt(space.HiddenNeurons, space.HiddenNeuronsDist, base.HiddenNeurons),
+			Alpha:         sampleFloat(space.Alpha, space.AlphaDist, base.Alpha),
+			Lambda:        sampleFloat(space.Lambda, space.LambdaDist, base.Lambda),
+			BatchSize:     sampleInt(space.BatchSize, space.BatchSizeDist, base.BatchSize),
+			Activation:    base.Activation,
+		}
+		if len(space.Activation) > 0 {
+			trials[i].Activation = space.Activation[rng.Intn(len(space.Activation))]
+		}
+	}
+	return trials
+}
+
+// TrialResult is the outcome of training a net with the hyperparameters of a trial
+type TrialResult struct {
+	Trial
+	Epochs                    int
+	TrainCost, ValidationCost float64
+	// Err is set when the trial failed to train, for example when the cost diverged
+	Err error
+
+	id  string
+	net *NeuralNet
+}
+
+// Tuner searches for the hyperparameters with the lowest validation cost
+type Tuner struct {
+	// NewNet returns a net with the settings that aren't searched, like the number of epochs. It
+	// must return a new net for each call since trials are trained at the same time.
+	NewNet            func() *NeuralNet
+	Train, Validation Dataset
+	// CPUs is the number of cores shared by the trials, each trial uses as many as the net has
+	// batches. Defaults to runtime.NumCPU().
+	CPUs int
+	// Log prints the result of every trial as it finishes
+	Log bool
+}
+
+// GridSearch trains a net for every combination of the values in space
+func (t *Tuner) GridSearch(space SearchSpace) ([]TrialResult, error) {
+	return t.Run(GridTrials(space, trialOf(t.NewNet())))
+}
+
+// RandomSearch trains a net for n samples of space
+func (t *Tuner) RandomSearch(space SearchSpace, n int, seed int64) ([]TrialResult, error) {
+	return t.Run(RandomTrials(space, trialOf(t.NewNet()), n, seed))
+}
+
+// Run trains a net for each trial and returns the results ordered by validation cost with the
+// failed trials last. It only returns an error if every trial failed.
+func (t *Tuner) Run(trials []Trial) ([]TrialResult, error) {
+	if len(trials) == 0 {
+		return nil, fmt.Errorf("tuner: there are no trials to run")
+	}
+	jobs := make([]trialJob, len(trials))
+	for i, trial := range trials {
+		jobs[i] = trialJob{trial: trial}
+	}
+	return t.finish(t.run(jobs))
+}
+
+// finish orders the results and only keeps the net of the best
+func (t *Tuner) finish(results []TrialResult) ([]TrialResult, error) {
+	sortResults(results)
+	if results[0].Err != nil {
+		return results, fmt.Errorf("tuner: every trial failed, the first with %s", results[0].Err)
+	}
+	// only the best net is worth keeping in memory
+	for i := 1; i < len(results); i++ {
+		results[i].net = nil
+	}
+	return results, nil
+}
+
+// trialJob trains a trial for a number of epochs, continuing from the weights of net after done
+// epochs when net is set. Zero epochs uses the number of epochs of the base net.
+type trialJob struct {
+	trial  Trial
+	epochs int
+	net    *NeuralNet
+	done   int
+}
+
+// run trains the jobs concurrently within the CPU budget
+func (t *Tuner) run(jobs []trialJob) []TrialResult {
+	cpus := t.CPUs
+	if cpus < 1 {
+		cpus = runtime.NumCPU()
+	}
+	perTrial := t.NewNet().numBatches
+	if perTrial < 1 {
+		perTrial = 1
+	}
+	parallel := cpus / perTrial
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]TrialResult, len(jobs))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			res := t.runTrial(jobs[i])
+			results[i] = res
+			if t.Log {
+				if res.Err != nil {
+					log.Printf("trial %s failed after %d epochs: %s", res.Trial, res.Epochs, res.Err)
+				} else {
+					log.Printf("trial %s after %d epochs: %f\t%f", res.Trial, res.Epochs, res.TrainCost, res.ValidationCost)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// runTrial trains a single net with the hyperparameters of the job's trial
+func (t *Tuner) runTrial(job trialJob) TrialResult {
+	nn := t.NewNet()
+	job.trial.apply(nn)
+	if job.epochs > 0 {
+		nn.numEpochs = job.epochs
+	}
+	if job.net != nil {
+		nn.W1, nn.W2 = job.net.W1, job.net.W2
+		nn.keepWeights = true
+	}
+	res := TrialResult{Trial: job.trial, Epochs: job.done + nn.numEpochs, net: nn}
+	if t.Validation.Len() == 0 {
+		// the trials are compared by their validation cost
+		res.Err = fmt.Errorf("tuner: there are no validation examples")
+		res.net = nil
+		return res
+	}
+	res.TrainCost, res.ValidationCost, res.Err = nn.TrainDataset(t.Train, t.Validation)
+	if res.Err == nil && !isFinite(res.ValidationCost) {
+		res.Err = &NonFiniteError{Epoch: nn.numEpochs, What: "validation cost"}
+	}
+	if res.Err != nil {
+		res.net = nil
+	}
+	return res
+}
+
+// sortResults orders results by validation cost with the failed trials last
+func sortResults(results []TrialResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].Err == nil) != (results[j].Err == nil) {
+			return results[i].Err == nil
+		}
+		return results[i].ValidationCost < results[j].ValidationCost
+	})
+}
+
+var resultColumns = []string{"rank", "hidden neurons", "alpha", "lambda", "batch size", "activation", "epochs", "train cost", "validation cost", "error"}
+
+// row returns the columns of a result as text
+func (r *TrialResult) row(rank int) []string {
+	errText := ""
+	if r.Err != nil {
+		errText = r.Err.Error()
+	}
+	return []string{
+		strconv.Itoa(rank),
+		strconv.Itoa(r.HiddenNeurons),
+		strconv.FormatFloat(r.Alpha, 'g', -1, 64),
+		strconv.FormatFloat(r.Lambda, 'g', -1, 64),
+		strconv.Itoa(r.BatchSize),
+		r.Activation.String(),
+		strconv.Itoa(r.Epochs),
+		strconv.FormatFloat(r.TrainCost, 'f', 6, 64),
+		strconv.FormatFloat(r.ValidationCost, 'f', 6, 64),
+		errText,
+	}
+}
+
+// ResultsTable returns a table of the results in the order given
+func ResultsTable(results []TrialResult) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "rank\thidden\talpha\tlambda\tbatch\tactivation\tepochs\ttrain cost\tvalidation cost\t")
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil {
+			fmt.Fprintf(w, "%d\t%d\t%g\t%g\t%d\t%s\t%d\tfailed\t\t\n", i+1, r.HiddenNeurons, r.Alpha, r.Lambda, r.BatchSize, r.Activation, r.Epochs)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%d\t%g\t%g\t%d\t%s\t%d\t%.4f\t%.4f\t\n", i+1, r.HiddenNeurons, r.Alpha, r.Lambda, r.BatchSize, r.Activation, r.Epochs, r.TrainCost, r.ValidationCost)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// SaveResults writes the results as results.csv and the net of the first result as best.bin into
+// dir, which is created if needed
+func SaveResults(dir string, results []TrialResult) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(resultColumns)
+	for i := range results {
+		w.Write(results[i].row(i + 1))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "results.csv"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+
+	if len(results) == 0 || results[0].net == nil {
+		return fmt.Errorf("tuner: there is no trained net to save")
+	}
+	data, err := results[0].net.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "best.bin"), data, 0644); err != nil {
+		return fmt.Errorf("tuner: %s", err)
+	}
+	return nil
+}
+
+// Best returns the trained net of the best trial, it's nil if every trial failed
+func Best(results []TrialResult) *NeuralNet {
+	if len(results) == 0 {
+		return nil
+	}
+	return results[0].net
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGridTrials(t *testing.T) {
+	base := Trial{HiddenNeurons: 5, Alpha: 0.1, Lambda: 0.01, BatchSize: 16}
+	trials := GridTrials(SearchSpace{
+		HiddenNeurons: []int{2, 4},
+		Alpha:         []float64{1, 0.1, 0.01},
+		Activation:    []Activation{Sigmoid, ReLU},
+	}, base)
+	if len(trials) != 12 {
+		t.Fatalf("expected 12 combinations, got %d", len(trials))
+	}
+	seen := make(map[Trial]bool)
+	for _, trial := range trials {
+		if trial.Lambda != base.Lambda || trial.BatchSize != base.BatchSize {
+			t.Errorf("expected the values that aren't searched to be kept, got %s", trial)
+		}
+		seen[trial] = true
+	}
+	if len(seen) != 12 {
+		t.Errorf("expected every combination once, got %d different", len(seen))
+	}
+	if trials := GridTrials(SearchSpace{}, base); len(trials) != 1 || trials[0] != base {
+		t.Errorf("expected only the base trial for an empty space, got %v", trials)
+	}
+}
+
+func TestRandomTrials(t *testing.T) {
+	space := SearchSpace{
+		HiddenNeurons: []int{8, 16},
+		AlphaDist:     LogUniform{1e-4, 1e-1},
+		LambdaDist:    Uniform{0, 2},
+		Activation:    []Activation{Tanh, ReLU},
+	}
+	base := Trial{BatchSize: 32}
+	trials := RandomTrials(space, base, 50, 1)
+	if len(trials) != 50 {
+		t.Fatalf("expected 50 trials, got %d", len(trials))
+	}
+	for _, trial := range trials {
+		if trial.HiddenNeurons != 8 && trial.HiddenNeurons != 16 {
+			t.Errorf("expected 8 or 16 hidden neurons, got %d", trial.HiddenNeurons)
+		}
+		if trial.Alpha < 1e-4 || trial.Alpha > 1e-1 || trial.Lambda < 0 || trial.Lambda > 2 {
+			t.Errorf("expected the sampled values in range, got %s", trial)
+		}
+		if trial.Activation == Sigmoid || trial.BatchSize != 32 {
+			t.Errorf("unexpected trial %s", trial)
+		}
+	}
+	again := RandomTrials(space, base, 50, 1)
+	for i := range trials {
+		if trials[i] != again[i] {
+			t.Fatalf("expected the same seed to give the same trials")
+		}
+	}
+}
+
+// separableTestSets returns two well separated classes for training and validation, shared by the
+// tests that train a small net
+func separableTestSets() (train, validation *SliceDataset) {
+	train, validation = &SliceDataset{}, &SliceDataset{}
+	for i := 0; i < 40; i++ {
+		set := train
+		if i%4 == 0 {
+			set = validation
+		}
+		val := float64(i%10) / 10
+		if i%2 == 0 {
+			set.X = append(set.X, []float64{-1 - val, -1})
+			set.Y = append(set.Y, []float64{1, 0})
+		} else {
+			set.X = append(set.X, []float64{1 + val, 1})
+			set.Y = append(set.Y, []float64{0, 1})
+		}
+	}
+	return train, validation
+}
+
+func TestTunerGridSearch(t *testing.T) {
+	train, validation := separableTestSets()
+	tuner := &Tuner{
+		NewNet: func() *NeuralNet {
+			return &NeuralNet{HiddenNeurons: 4, Lambda: 1e-3, numBatches: 1, numEpochs: 100}
+		},
+		Train:      train,
+		Validation: validation,
+		CPUs:       2,
+	}
+	results, err := tuner.GridSearch(SearchSpace{Alpha: []float64{1e-6, 0.5}, Activation: []Activation{Sigmoid, Tanh}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].ValidationCost < results[i-1].ValidationCost {
+			t.Errorf("expected the results ordered by validation cost")
+		}
+	}
+	if results[0].Alpha != 0.5 {
+		t.Errorf("expected the larger learning rate to win, got %s", results[0].Trial)
+	}
+	best := Best(results)
+	if best == nil || best.Alpha != 0.5 || best.Activation != results[0].Activation {
+		t.Fatalf("expected the net of the best trial")
+	}
+
+	if table := ResultsTable(results); strings.Count(table, "\n") != 5 || !strings.Contains(table, "tanh") {
+		t.Errorf("unexpected table\n%s", table)
+	}
+	dir := filepath.Join(t.TempDir(), "tuning")
+	if err := SaveResults(dir, results); err != nil {
+		t.Fatal(err)
+	}
+	csv, err := os.ReadFile(filepath.Join(dir, "results.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(csv)), "\n"); len(lines) != 5 || !strings.HasPrefix(lines[1], "1,4,0.5,") {
+		t.Errorf("unexpected results\n%s", csv)
+	}
+	if loaded := Load(filepath.Join(dir, "best.bin")); !loaded.W1.Equals(best.W1) {
+		t.Errorf("expected the best net to be saved")
+	}
+}
+
+func TestTunerFailedTrials(t *testing.T) {
+	train, validation := separableTestSets()
+	tuner := &Tuner{
+		NewNet: func() *NeuralNet {
+			return &NeuralNet{HiddenNeurons: 2, numBatches: 1, numEpochs: 5}
+		},
+		Train:      train,
+		Validation: validation,
+	}
+	results, err := tuner.Run([]Trial{{HiddenNeurons: 2, Alpha: 1e308}, {HiddenNeurons: 2, Alpha: 0.1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[1].Err == nil {
+		t.Errorf("expected the diverging trial to fail and be ordered last")
+	}
+	if !strings.Contains(ResultsTable(results), "failed") {
+		t.Errorf("expected the failed trial in the table")
+	}
+
+	if _, err := tuner.Run([]Trial{{HiddenNeurons: 2, Alpha: 1e308}}); err == nil {
+		t.Errorf("expected an error when every trial fails")
+	}
+	if _, err := tuner.Run(nil); err == nil {
+		t.Errorf("expected an error without trials")
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Whitening decorrelates the features and scales them to unit variance using the eigenvectors of
+// their covariance. PCA whitening projects onto the eigenvectors while ZCA rotates the result back
+// so the features stay close to the original ones, which suits images.
+type Whitening struct {
+	ZCA bool
+	// Epsilon is added to the variances so the directions with almost no variance aren't blown up,
+	// defaults to 1e-5
+	Epsilon float64
+	Means   []float64
+	// W has a row per whitened feature
+	W [][]float64
+}
+
+// Fit learns the whitening transform from the covariance of the training examples
+func (w *Whitening) Fit(x [][]float64) error {
+	if _, err := checkFitRows(x, "whitening"); err != nil {
+		return err
+	}
+	return w.FitDataset(unlabelled(x), 0)
+}
+
+// FitDataset is the same as Fit but reads the examples from the set batchSize at a time
+func (w *Whitening) FitDataset(set Dataset, batchSize int) error {
+	if set.Len() == 0 {
+		return fmt.Errorf("whitening: no examples to fit")
+	}
+	if w.Epsilon == 0 {
+		w.Epsilon = 1e-5
+	}
+	var cov *Matrix
+	var err error
+	if w.Means, cov, err = covariance(set, batchSize); err != nil {
+		return err
+	}
+	values, vectors, err := symmetricEigen(cov)
+	if err != nil {
+		return fmt.Errorf("whitening: %s", err)
+	}
+
+	n := len(values)
+	w.W = make([][]float64, n)
+	for i := range w.W {
+		w.W[i] = make([]float64, n)
+	}
+	for k, vec := range vectors {
+		scale := 1 / math.Sqrt(math.Max(values[k], 0)+w.Epsilon)
+		if !w.ZCA {
+			for j := range vec {
+				w.W[k][j] = vec[j] * scale
+			}
+			continue
+		}
+		for i := range vec {
+			for j := range vec {
+				w.W[i][j] += vec[i] * vec[j] * scale
+			}
+		}
+	}
+	return nil
+}
+
+// TransformRow returns a whitened copy of row
+func (w *Whitening) TransformRow(row []float64) ([]float64, error) {
+	if len(row) != len(w.Means) {
+		return nil, fmt.Errorf("whitening: expected %d features, got %d", len(w.Means), len(row))
+	}
+	centred := make([]float64, len(row))
+	for i, val := range row {
+		centred[i] = val - w.Means[i]
+	}
+	res := make([]float64, len(w.W))
+	for i, weights := range w.W {
+		for j, val := range centred {
+			res[i] += weights[j] * val
+		}
+	}
+	return res, nil
+}
+
+// Transform whitens every example
+func (w *Whitening) Transform(x [][]float64) ([][]float64, error) {
+	return transformRows(x, w.TransformRow)
+}
+
+// covariance returns the mean of every feature of the set and their covariance matrix. The set is
+// read twice, batchSize examples at a time, first for the means and then to add up Cᵀ·C for every
+// batch C of centred examples, so it doesn't have to fit in memory.
+func covariance(set Dataset, batchSize int) (means []float64, cov *Matrix, err error) {
+	numFeatures, _ := set.Dims()
+	stats := NewRunningStats(numFeatures)
+	if err := eachBatch(set, batchSize, stats.AddBatch); err != nil {
+		return nil, nil, err
+	}
+	means = stats.Means
+	cov = NewZeros(numFeatures, numFeatures)
+	err = eachBatch(set, batchSize, func(x [][]float64) error {
+		centred := make([]float64, 0, len(x)*numFeatures)
+		for _, row := range x {
+			for j, val := range row {
+				centred = append(centred, val-means[j])
+			}
+		}
+		C := MustNewMatrixF(centred, len(x), numFeatures)
+		cov = cov.MustAdd(C.T().MustDot(C))
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return means, cov.ScalarDiv(float64(stats.Count)), nil
+}
+
+// maxEigenIterations limits the QL iterations spent on a single eigenvalue
+const maxEigenIterations = 50
+
+// symmetricEigen returns the eigenvalues of the symmetric matrix a in descending order with the
+// matching eigenvectors as rows, the largest element of each eigenvector is positive. The matrix is
+// reduced to tridiagonal form with Householder reflections and then diagonalised with implicit QL
+// iterations, both scale with the cube of the number of features but need far fewer passes than
+// Jacobi rotations. It returns an error if an eigenvalue doesn't converge.
+func symmetricEigen(a *Matrix) (values []float64, vectors [][]float64, err error) {
+	if a.Rows != a.Cols {
+		return nil, nil, fmt.Errorf("eigen: expected a square matrix, got %d X %d", a.Rows, a.Cols)
+	}
+	n := a.Rows
+	// w holds the transpose of the accumulated transformations so every inner loop runs along a
+	// row, a starts out as its own transpose since it's symmetric
+	w := make([][]float64, n)
+	for i := range w {
+		w[i] = append([]float64(nil), a.Data[i*n:(i+1)*n]...)
+	}
+	d, e := make([]float64, n), make([]float64, n)
+	tridiagonalise(w, d, e)
+	if err := diagonalise(w, d, e); err != nil {
+		return nil, nil, err
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return d[order[i]] > d[order[j]] })
+	values = make([]float64, n)
+	vectors = make([][]float64, n)
+	for i, k := range order {
+		values[i], vectors[i] = d[k], w[k]
+		largest := 0
+		for j, val := range vectors[i] {
+			if math.Abs(val) > math.Abs(vectors[i][largest]) {
+				largest = j
+			}
+		}
+		if vectors[i][largest] < 0 {
+			for j := range vectors[i] {
+				vectors[i][j] = -vectors[i][j]
+			}
+		}
+	}
+	return values, vectors, nil
+}
+
+// tridiagonalise reduces the symmetric matrix in w to tridiagonal form with Householder
+// reflections, leaving the diagonal in d, the sub diagonal in e[1:] and the transpose of the
+// orthogonal transformation in w
+func tridiagonalise(w [][]float64, d, e []float64) {
+	n := len(w)
+	for j := 0; j < n; j++ {
+		d[j] = w[j][n-1]
+	}
+	for i := n - 1; i > 0; i-- {
+		var scale, h float64
+		for k := 0; k < i; k++ {
+			scale += math.Abs(d[k])
+		}
+		if scale == 0 {
+			e[i] = d[i-1]
+			for j := 0; j < i; j++ {
+				d[j] = w[j][i-1]
+				w[j][i] = 0
+				w[i][j] = 0
+			}
+			d[i] = h
+			continue
+		}
+
+		// the Householder vector
+		for k := 0; k < i; k++ {
+			d[k] /= scale
+			h += d[k] * d[k]
+		}
+		f := d[i-1]
+		g := math.Sqrt(h)
+		if f > 0 {
+			g = -g
+		}
+		e[i] = scale * g
+		h -= f * g
+		d[i-1] = f - g
+		for j := 0; j < i; j++ {
+			e[j] = 0
+		}
+
+		// apply the similarity transformation to the remaining columns
+		for j := 0; j < i; j++ {
+			f = d[j]
+			w[i][j] = f
+			row := w[j]
+			g = e[j] + row[j]*f
+			for k := j + 1; k < i; k++ {
+				g += row[k] * d[k]
+				e[k] += row[k] * f
+			}
+			e[j] = g
+		}
+		f = 0
+		for j := 0; j < i; j++ {
+			e[j] /= h
+			f += e[j] * d[j]
+		}
+		hh := f / (h + h)
+		for j := 0; j < i; j++ {
+			e[j] -= hh * d[j]
+		}
+		for j := 0; j < i; j++ {
+			f, g = d[j], e[j]
+			row := w[j]
+			for k := j; k < i; k++ {
+				row[k] -= f*e[k] + g*d[k]
+			}
+			d[j] = row[i-1]
+			row[i] = 0
+		}
+		d[i] = h
+	}
+
+	// accumulate the transformations
+	for i := 0; i < n-1; i++ {
+		w[i][n-1] = w[i][i]
+		w[i][i] = 1
+		h := d[i+1]
+		next := w[i+1]
+		if h != 0 {
+			for k := 0; k <= i; k++ {
+				d[k] = next[k] / h
+			}
+			for j := 0; j <= i; j++ {
+				row := w[j]
+				var g float64
+				for k := 0; k <= i; k++ {
+					g += next[k] * row[k]
+				}
+				for k := 0; k <= i; k++ {
+					row[k] -= g * d[k]
+				}
+			}
+		}
+		for k := 0; k <= i; k++ {
+			next[k] = 0
+		}
+	}
+	for j := 0; j < n; j++ {
+		d[j] = w[j][n-1]
+		w[j][n-1] = 0
+	}
+	w[n-1][n-1] = 1
+	e[0] = 0
+}
+
+// diagonalise finds the eigenvalues of the tridiagonal matrix in d and e with implicit QL
+// iterations, the eigenvalues are left in d and the rotations are applied to the rows of w
+func diagonalise(w [][]float64, d, e []float64) error {
+	n := len(d)
+	for i := 1; i < n; i++ {
+		e[i-1] = e[i]
+	}
+	e[n-1] = 0
+
+	var f, largest float64
+	eps := math.Pow(2, -52)
+	for l := 0; l < n; l++ {
+		// find a small sub diagonal element to split the matrix at
+		largest = math.Max(largest, math.Abs(d[l])+math.Abs(e[l]))
+		m := l
+		for m < n-1 && !(math.Abs(e[m]) <= eps*largest) {
+			m++
+		}
+		for iter := 0; m > l; iter++ {
+			if iter == maxEigenIterations {
+				return fmt.Errorf("eigen: eigenvalue %d did not converge after %d iterations", l, iter)
+			}
+			// the implicit shift
+			g := d[l]
+			p := (d[l+1] - g) / (2 * e[l])
+			r := math.Hypot(p, 1)
+			if p < 0 {
+				r = -r
+			}
+			d[l] = e[l] / (p + r)
+			d[l+1] = e[l] * (p + r)
+			dl1 := d[l+1]
+			h := g - d[l]
+			for i := l + 2; i < n; i++ {
+				d[i] -= h
+			}
+			f += h
+
+			p = d[m]
+			c, c2, c3 := 1.0, 1.0, 1.0
+			el1 := e[l+1]
+			var s, s2 float64
+			for i := m - 1; i >= l; i-- {
+				c3, c2, s2 = c2, c, s
+				g = c * e[i]
+				h = c * p
+				r = math.Hypot(p, e[i])
+				e[i+1] = s * r
+				s = e[i] / r
+				c = p / r
+				p = c*d[i] - s*g
+				d[i+1] = h + s*(c*g+s*d[i])
+				wi, wi1 := w[i], w[i+1]
+				for k := range wi {
+					h = wi1[k]
+					wi1[k] = s*wi[k] + c*h
+					wi[k] = c*wi[k] - s*h
+				}
+			}
+			p = -s * s2 * c3 * el1 * e[l] / dl1
+			e[l] = s * p
+			d[l] = c * p
+			if math.Abs(e[l]) <= eps*largest {
+				break
+			}
+		}
+		d[l] += f
+		e[l] = 0
+	}
+	return nil
+}
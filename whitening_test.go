@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestSymmetricEigen(t *testing.T) {
+	a := [][]float64{{4, 1, 0}, {1, 3, 1}, {0, 1, 2}}
+	values, vectors, err := symmetricEigen(MustNewMatrix(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range values {
+		if k > 0 && values[k] > values[k-1] {
+			t.Errorf("expected the eigenvalues in descending order, got %v", values)
+		}
+		// a v = lambda v
+		for i := range a {
+			var av float64
+			for j := range a {
+				av += a[i][j] * vectors[k][j]
+			}
+			if math.Abs(av-values[k]*vectors[k][i]) > 1e-9 {
+				t.Errorf("eigenvector %d doesn't match its eigenvalue %f", k, values[k])
+			}
+		}
+	}
+	if sum := values[0] + values[1] + values[2]; math.Abs(sum-9) > 1e-9 {
+		t.Errorf("expected the eigenvalues to sum to the trace, got %f", sum)
+	}
+
+	if _, _, err := symmetricEigen(MustNewMatrix([][]float64{{1, math.NaN()}, {math.NaN(), 1}})); err == nil {
+		t.Errorf("expected an error when the eigenvalues don't converge")
+	}
+}
+
+func TestCovarianceBatches(t *testing.T) {
+	set := unlabelled(correlatedTestX())
+	means, expected, err := covariance(set, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second feature is twice the first plus a little noise
+	if math.Abs(means[0]-10) > 0.5 || math.Abs(expected.At(0, 1)-2*expected.At(0, 0)) > 0.5 {
+		t.Errorf("unexpected means %v and covariance %v", means, expected.Data)
+	}
+	_, actual, err := covariance(set, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range expected.Data {
+		if math.Abs(actual.Data[i]-expected.Data[i]) > 1e-9 {
+			t.Errorf("expected the same covariance in batches of 7, got %v and %v", actual.Data, expected.Data)
+			break
+		}
+	}
+}
+
+// correlatedTestX returns examples where the second feature mostly follows the first
+func correlatedTestX() [][]float64 {
+	rng := rand.New(rand.NewSource(1))
+	x := make([][]float64, 500)
+	for i := range x {
+		a := rng.NormFloat64() * 3
+		x[i] = []float64{a + 10, 2*a + rng.NormFloat64()*0.5, rng.NormFloat64()}
+	}
+	return x
+}
+
+func TestWhitening(t *testing.T) {
+	for _, zca := range []bool{false, true} {
+		w := &Whitening{ZCA: zca}
+		if err := w.Fit(correlatedTestX()); err != nil {
+			t.Fatal(err)
+		}
+		x, err := w.Transform(correlatedTestX())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the whitened features are uncorrelated with unit variance, apart from the epsilon
+		means, cov, err := covariance(unlabelled(x), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range means {
+			if math.Abs(means[i]) > 1e-9 {
+				t.Errorf("zca %v: expected a zero mean, got %f", zca, means[i])
+			}
+			for j := range means {
+				expected := 0.0
+				if i == j {
+					expected = 1
+				}
+				if math.Abs(cov.At(i, j)-expected) > 1e-3 {
+					t.Errorf("zca %v: expected the covariance %f at %d, %d, got %f", zca, expected, i, j, cov.At(i, j))
+				}
+			}
+		}
+		if zca && (w.W[0][0] < 0 || w.W[1][1] < 0 || w.W[2][2] < 0) {
+			t.Errorf("expected zca to keep the features close to the originals, got %v", w.W)
+		}
+	}
+}
+
+func TestSaveLoadScaler(t *testing.T) {
+	scaler, err := NewScalerPipeline("robust", "zca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := correlatedTestX()
+	if err := scaler.Fit(x); err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := scaler.TransformRow(x[0])
+	nn := &NeuralNet{HiddenNeurons: 1, W1: NewOnes(1, 4), W2: NewOnes(1, 2), Scaler: scaler}
+	for _, name := range []string{"net.json", "net.bin"} {
+		fileName := filepath.Join(t.TempDir(), name)
+		Save(fileName, nn)
+		loaded := Load(fileName)
+		if loaded.Scaler == nil || len(loaded.Scaler.Steps) != 2 || !loaded.Scaler.Steps[1].(*Whitening).ZCA {
+			t.Fatalf("%s: the scaler wasn't saved", name)
+		}
+		actual, err := loaded.Preprocess(x[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalSlices(actual, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, actual)
+		}
+	}
+}
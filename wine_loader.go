@@ -1,49 +1,43 @@
 package main
 
-import (
-	"encoding/csv"
-	"io"
-	"os"
-	"strconv"
-)
+// wineSchema describes testdata/wine.data, the class is in the first column followed by the 13
+// attributes listed in testdata/wine.names
+var wineSchema = CSVSchema{
+	Names: []string{
+		"Class",
+		"Alcohol",
+		"Malic acid",
+		"Ash",
+		"Alcalinity of ash",
+		"Magnesium",
+		"Total phenols",
+		"Flavanoids",
+		"Nonflavanoid phenols",
+		"Proanthocyanins",
+		"Color intensity",
+		"Hue",
+		"OD280/OD315 of diluted wines",
+		"Proline",
+	},
+	LabelColumn: "Class",
+	Classes:     []string{"1", "2", "3"},
+	// missing attributes are NaN so they can be filled in by an Imputer
+	Missing: MissingNaN,
+}
+
+func init() {
+	RegisterLoader("wine", csvLoader{wineSchema}, ".data")
+}
+
+// loadWine loads the UCI wine recognition data
+func loadWine(file string) (Dataset, error) {
+	return csvLoader{wineSchema}.Load(file)
+}
 
 func wineLoader(file string) ([][]float64, [][]float64, error) {
-	f, err := os.Open(file)
+	set, err := LoadCSV(file, wineSchema)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer f.Close()
-
-	csvr := csv.NewReader(f)
-
-	var data [][]float64
-	var classes [][]float64
-	for {
-		row, err := csvr.Read()
-		if err != nil {
-			if err == io.EOF {
-				err = nil
-			}
-			return data, classes, err
-		}
-
-		class := make([]float64, 3)
-		var classVal float64
-		if classVal, err = strconv.ParseFloat(row[0], 64); err != nil {
-			return data, classes, err
-		}
-		class[int(classVal)-1] = 1
-
-		classes = append(classes, class)
-		values := make([]float64, len(row))
-		for i := 1; i < len(row)-1; i++ {
-			var val float64
-			if val, err = strconv.ParseFloat(row[i], 64); err != nil {
-				return data, classes, err
-			}
-			values[i] = val
-
-		}
-		data = append(data, values)
-	}
+	return set.X, set.Y, nil
 }
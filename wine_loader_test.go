@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWineLoader(t *testing.T) {
+	x, y, err := wineLoader("testdata/wine.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(x) != 178 || len(y) != 178 {
+		t.Fatalf("expected 178 examples, got %d", len(x))
+	}
+	if len(x[0]) != 13 {
+		t.Errorf("expected 13 features, got %d", len(x[0]))
+	}
+
+	// 1,14.23,1.71,2.43,15.6,127,2.8,3.06,.28,2.29,5.64,1.04,3.92,1065
+	if x[0][0] != 14.23 {
+		t.Errorf("expected the first feature to be alcohol (14.23), got %f", x[0][0])
+	}
+	if x[0][12] != 1065 {
+		t.Errorf("expected the last feature to be proline (1065), got %f", x[0][12])
+	}
+
+	counts := make([]int, 3)
+	for i := range y {
+		for j := range y[i] {
+			if y[i][j] == 1 {
+				counts[j]++
+			}
+		}
+	}
+	expected := []int{59, 71, 48}
+	for i := range counts {
+		if counts[i] != expected[i] {
+			t.Errorf("expected class %d to have %d examples, got %d", i+1, expected[i], counts[i])
+		}
+	}
+}
+
+func TestWineFeatureNames(t *testing.T) {
+	set, err := loadWine("testdata/wine.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := set.FeatureNames()
+	if len(names) != 13 || names[0] != "Alcohol" || names[12] != "Proline" {
+		t.Errorf("unexpected feature names %v", names)
+	}
+}
+
+func TestWineLoaderMissingValues(t *testing.T) {
+	file := writeTestFile(t, "wine.data", "1,14.23,?,2.43,15.6,127,2.8,3.06,.28,2.29,5.64,1.04,3.92,1065\n"+
+		"2,12.37,.94,,10.6,88,1.98,.57,.28,.42,1.95,1.05,1.82,520\n")
+	x, _, err := wineLoader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(x[0][1]) || !math.IsNaN(x[1][2]) {
+		t.Errorf("expected the missing attributes to be NaN, got %v", x)
+	}
+}